@@ -3,14 +3,35 @@ package ast
 import (
 	"bytes"
 	"compiler-book/token"
+	"path/filepath"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is where the node's token starts in the source.
+	Pos() token.TokenMetadata
+	// Source returns what the node keeps of the source it was parsed from.
+	Source() *Syntax
 }
 
+// Syntax is what a node keeps of the source it was parsed from: its exact
+// span and the comments around it. Nodes made by macros have none.
+//
+// Comments are only kept when the parser reads them, see
+// lexer.NewWithComments.
+type Syntax struct {
+	Start token.TokenMetadata // the first character of the node
+	End   token.TokenMetadata // just after the last character
+
+	Leading  []token.Token // the comments on the lines right before the node
+	Trailing *token.Token  // a comment after the node, on its last line
+	Inner    []token.Token // the comments inside that belong to no child, e.g. at the end of a block
+}
+
+func (s *Syntax) Source() *Syntax { return s }
+
 type Statement interface {
 	Node
 	statementNode()
@@ -22,6 +43,7 @@ type Expression interface {
 }
 
 type Program struct {
+	Syntax
 	Statements []Statement
 }
 
@@ -33,6 +55,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.TokenMetadata {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.TokenMetadata{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -45,18 +74,20 @@ func (p *Program) String() string {
 
 // BNF: let <identifier> = <expression>;
 type LetStatement struct {
+	Syntax
 	Token token.Token // the token.LET token
 	Name  *Identifier
 	Value Expression
 }
 
-func (ls *LetStatement) statementNode()       {}
-func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) statementNode()           {}
+func (ls *LetStatement) TokenLiteral() string     { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.TokenMetadata { return ls.Token.Metadata }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	out.WriteString(declaration(ls.Name))
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -70,12 +101,14 @@ func (ls *LetStatement) String() string {
 
 // BNF: return <expression>;
 type ReturnStatement struct {
+	Syntax
 	Token       token.Token // the 'return' token
 	ReturnValue Expression
 }
 
-func (rs *ReturnStatement) statementNode()       {}
-func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) statementNode()           {}
+func (rs *ReturnStatement) TokenLiteral() string     { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.TokenMetadata { return rs.Token.Metadata }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -91,32 +124,38 @@ func (rs *ReturnStatement) String() string {
 }
 
 type IntegerLiteral struct {
+	Syntax
 	Token token.Token
 	Value int64
 }
 
-func (il *IntegerLiteral) expressionNode()      {}
-func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
-func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) expressionNode()          {}
+func (il *IntegerLiteral) TokenLiteral() string     { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.TokenMetadata { return il.Token.Metadata }
+func (il *IntegerLiteral) String() string           { return il.Token.Literal }
 
 type FloatLiteral struct {
+	Syntax
 	Token token.Token
 	Value float64
 }
 
-func (fl *FloatLiteral) expressionNode()      {}
-func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
-func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) expressionNode()          {}
+func (fl *FloatLiteral) TokenLiteral() string     { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.TokenMetadata { return fl.Token.Metadata }
+func (fl *FloatLiteral) String() string           { return fl.Token.Literal }
 
 // BNF: <operator> <expression>;
 type PrefixExpression struct {
+	Syntax
 	Token    token.Token // The prefix token, e.g. !
 	Operator string
 	Right    Expression
 }
 
-func (pe *PrefixExpression) expressionNode()      {}
-func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) expressionNode()          {}
+func (pe *PrefixExpression) TokenLiteral() string     { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.TokenMetadata { return pe.Token.Metadata }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -129,12 +168,14 @@ func (pe *PrefixExpression) String() string {
 }
 
 type PostfixExpression struct {
+	Syntax
 	Token    token.Token // The postfix token, e.g. ++
 	Operator string
 }
 
-func (pe *PostfixExpression) expressionNode()      {}
-func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) expressionNode()          {}
+func (pe *PostfixExpression) TokenLiteral() string     { return pe.Token.Literal }
+func (pe *PostfixExpression) Pos() token.TokenMetadata { return pe.Token.Metadata }
 func (pe *PostfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -147,14 +188,16 @@ func (pe *PostfixExpression) String() string {
 }
 
 type InfixExpression struct {
+	Syntax
 	Token    token.Token // The operator token, e.g. +
 	Left     Expression
 	Operator string
 	Right    Expression
 }
 
-func (ie *InfixExpression) expressionNode()      {}
-func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) expressionNode()          {}
+func (ie *InfixExpression) TokenLiteral() string     { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.TokenMetadata { return ie.Token.Metadata }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -169,12 +212,14 @@ func (ie *InfixExpression) String() string {
 
 // BNF: <expression>;
 type ExpressionStatement struct {
+	Syntax
 	Token      token.Token // the first token of the expression
 	Expression Expression
 }
 
-func (es *ExpressionStatement) statementNode()       {}
-func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) statementNode()           {}
+func (es *ExpressionStatement) TokenLiteral() string     { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.TokenMetadata { return es.Token.Metadata }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -184,14 +229,16 @@ func (es *ExpressionStatement) String() string {
 
 // BNF: if (<condition>) <consequence> else <alternative>
 type IfExpression struct {
+	Syntax
 	Token       token.Token // the 'if' token
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
 }
 
-func (ie *IfExpression) expressionNode()      {}
-func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) expressionNode()          {}
+func (ie *IfExpression) TokenLiteral() string     { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.TokenMetadata { return ie.Token.Metadata }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -209,12 +256,14 @@ func (ie *IfExpression) String() string {
 }
 
 type BlockStatement struct {
+	Syntax
 	Token      token.Token // the { token
 	Statements []Statement
 }
 
-func (bs *BlockStatement) statementNode()       {}
-func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) statementNode()           {}
+func (bs *BlockStatement) TokenLiteral() string     { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.TokenMetadata { return bs.Token.Metadata }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -227,18 +276,22 @@ func (bs *BlockStatement) String() string {
 
 // BNF: for (<init>; <condition>; <post>) <body>
 type ForExpression struct {
+	Syntax
 	Token     token.Token // the 'for' token
+	Label     *Identifier // nil when the loop has no label
 	Init      Statement
 	Condition Expression
 	Post      Expression
 	Body      *BlockStatement
 }
 
-func (fe *ForExpression) expressionNode()      {}
-func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) expressionNode()          {}
+func (fe *ForExpression) TokenLiteral() string     { return fe.Token.Literal }
+func (fe *ForExpression) Pos() token.TokenMetadata { return fe.Token.Metadata }
 func (fe *ForExpression) String() string {
 	var out bytes.Buffer
 
+	out.WriteString(loopLabel(fe.Label))
 	out.WriteString("for ")
 	out.WriteString("(")
 	out.WriteString(fe.Init.String())
@@ -254,45 +307,154 @@ func (fe *ForExpression) String() string {
 	return out.String()
 }
 
+// BNF: for (<identifier> [, <identifier>] in <expression>) <block>
+type ForInExpression struct {
+	Syntax
+	Token    token.Token // the 'for' token
+	Label    *Identifier // nil when the loop has no label
+	Key      *Identifier // nil when the loop only names the values
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForInExpression) expressionNode()          {}
+func (fe *ForInExpression) TokenLiteral() string     { return fe.Token.Literal }
+func (fe *ForInExpression) Pos() token.TokenMetadata { return fe.Token.Metadata }
+func (fe *ForInExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(loopLabel(fe.Label))
+	out.WriteString("for (")
+	if fe.Key != nil {
+		out.WriteString(fe.Key.String())
+		out.WriteString(", ")
+	}
+	out.WriteString(fe.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") { ")
+	out.WriteString(fe.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// BNF: while (<expression>) <block>
+type WhileExpression struct {
+	Syntax
+	Token     token.Token // the 'while' token
+	Label     *Identifier // nil when the loop has no label
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode()          {}
+func (we *WhileExpression) TokenLiteral() string     { return we.Token.Literal }
+func (we *WhileExpression) Pos() token.TokenMetadata { return we.Token.Metadata }
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(loopLabel(we.Label))
+	out.WriteString("while (")
+	out.WriteString(we.Condition.String())
+	out.WriteString(") { ")
+	out.WriteString(we.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// loopLabel returns how the label of a loop is written before it.
+func loopLabel(label *Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value + ": "
+}
+
+// BNF: break [<identifier>];
+type BreakStatement struct {
+	Syntax
+	Token token.Token // the 'break' token
+	Label *Identifier // the loop to leave, nil for the innermost one
+}
+
+func (bs *BreakStatement) statementNode()           {}
+func (bs *BreakStatement) TokenLiteral() string     { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.TokenMetadata { return bs.Token.Metadata }
+func (bs *BreakStatement) String() string           { return jump(bs.Token, bs.Label) }
+
+// BNF: continue [<identifier>];
+type ContinueStatement struct {
+	Syntax
+	Token token.Token // the 'continue' token
+	Label *Identifier // the loop to continue, nil for the innermost one
+}
+
+func (cs *ContinueStatement) statementNode()           {}
+func (cs *ContinueStatement) TokenLiteral() string     { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.TokenMetadata { return cs.Token.Metadata }
+func (cs *ContinueStatement) String() string           { return jump(cs.Token, cs.Label) }
+
+func jump(tok token.Token, label *Identifier) string {
+	if label == nil {
+		return tok.Literal + ";"
+	}
+	return tok.Literal + " " + label.Value + ";"
+}
+
 type StringLiteral struct {
+	Syntax
 	Token token.Token
 	Value string
 }
 
-func (sl *StringLiteral) expressionNode()      {}
-func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) expressionNode()          {}
+func (sl *StringLiteral) TokenLiteral() string     { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.TokenMetadata { return sl.Token.Metadata }
+func (sl *StringLiteral) String() string           { return sl.Token.Literal }
 
 type RuneLiteral struct {
+	Syntax
 	Token token.Token
 	Value rune
 }
 
-func (rl *RuneLiteral) expressionNode()      {}
-func (rl *RuneLiteral) TokenLiteral() string { return rl.Token.Literal }
-func (rl *RuneLiteral) String() string       { return rl.Token.Literal }
+func (rl *RuneLiteral) expressionNode()          {}
+func (rl *RuneLiteral) TokenLiteral() string     { return rl.Token.Literal }
+func (rl *RuneLiteral) Pos() token.TokenMetadata { return rl.Token.Metadata }
+func (rl *RuneLiteral) String() string           { return rl.Token.Literal }
 
-// BNF: fn <parameters> <block statement>
+// BNF: fn <parameters> [: <type>] <block statement>
 type FunctionLiteral struct {
+	Syntax
 	Token      token.Token // the 'fn' token
+	Name       string      // the name it is bound to by a let statement, if any
 	Parameters []*Identifier
+	ReturnType Type // nil if the result is not annotated
 	Body       *BlockStatement
 }
 
-func (fl *FunctionLiteral) expressionNode()      {}
-func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) expressionNode()          {}
+func (fl *FunctionLiteral) TokenLiteral() string     { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.TokenMetadata { return fl.Token.Metadata }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, declaration(p))
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") ")
+	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String())
+	}
+	out.WriteString(" ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -300,13 +462,16 @@ func (fl *FunctionLiteral) String() string {
 
 // BNF: <expression>(<comma separated expressions>)
 type CallExpression struct {
+	Syntax
 	Token     token.Token // the '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Tail      bool // in tail position of a function body, marked by the evaluator
 }
 
-func (ce *CallExpression) expressionNode()      {}
-func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) expressionNode()          {}
+func (ce *CallExpression) TokenLiteral() string     { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.TokenMetadata { return ce.Token.Metadata }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -325,31 +490,38 @@ func (ce *CallExpression) String() string {
 
 // BNF: <identifier>, is can be an expression
 type Identifier struct { // TODO: separate expression and statement
+	Syntax
 	Token token.Token // the token.IDENT token
 	Value string
+	Type  Type // the annotation of a declared name, nil if none
 }
 
-func (i *Identifier) expressionNode()      {}
-func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
-func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) expressionNode()          {}
+func (i *Identifier) TokenLiteral() string     { return i.Token.Literal }
+func (i *Identifier) Pos() token.TokenMetadata { return i.Token.Metadata }
+func (i *Identifier) String() string           { return i.Value }
 
 type Boolean struct {
+	Syntax
 	Token token.Token
 	Value bool
 }
 
-func (b *Boolean) expressionNode()      {}
-func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
-func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) expressionNode()          {}
+func (b *Boolean) TokenLiteral() string     { return b.Token.Literal }
+func (b *Boolean) Pos() token.TokenMetadata { return b.Token.Metadata }
+func (b *Boolean) String() string           { return b.Token.Literal }
 
 // BNF: [<comma separated expressions>]
 type ArrayLiteral struct {
+	Syntax
 	Token    token.Token // the '[' token
 	Elements []Expression
 }
 
-func (al *ArrayLiteral) expressionNode()      {}
-func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) expressionNode()          {}
+func (al *ArrayLiteral) TokenLiteral() string     { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.TokenMetadata { return al.Token.Metadata }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -367,13 +539,15 @@ func (al *ArrayLiteral) String() string {
 
 // BNF: <expression>[<expression>]
 type IndexExpression struct {
+	Syntax
 	Token token.Token // The [ token
 	Left  Expression
 	Index Expression
 }
 
-func (ie *IndexExpression) expressionNode()      {}
-func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) expressionNode()          {}
+func (ie *IndexExpression) TokenLiteral() string     { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.TokenMetadata { return ie.Token.Metadata }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -386,20 +560,53 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// BNF: <expression>[<expression>? : <expression>?]
+type SliceExpression struct {
+	Syntax
+	Token token.Token // The [ token
+	Left  Expression
+	Low   Expression // nil from the start
+	High  Expression // nil up to the end
+}
+
+func (se *SliceExpression) expressionNode()          {}
+func (se *SliceExpression) TokenLiteral() string     { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.TokenMetadata { return se.Token.Metadata }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // BNF: {<expression> : <expression>, <expression> : <expression>, ... }
 type HashLiteral struct {
-	Token token.Token // the '{' token
+	Syntax
+	Token token.Token  // the '{' token
+	Keys  []Expression // the keys of Pairs, in the order of the source
 	Pairs map[Expression]Expression
 }
 
-func (hl *HashLiteral) expressionNode()      {}
-func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) expressionNode()          {}
+func (hl *HashLiteral) TokenLiteral() string     { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.TokenMetadata { return hl.Token.Metadata }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -410,13 +617,15 @@ func (hl *HashLiteral) String() string {
 }
 
 type MacroLiteral struct {
+	Syntax
 	Token      token.Token // The 'magic' token
 	Parameters []*Identifier
 	Body       *BlockStatement
 }
 
-func (ml *MacroLiteral) expressionNode()      {}
-func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) expressionNode()          {}
+func (ml *MacroLiteral) TokenLiteral() string     { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.TokenMetadata { return ml.Token.Metadata }
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 
@@ -436,13 +645,15 @@ func (ml *MacroLiteral) String() string {
 
 // BNF: <expression> <operator> <expression>
 type AssignExpression struct {
+	Syntax
 	Token token.Token // The '=' token
 	Left  Expression
 	Value Expression
 }
 
-func (ae *AssignExpression) expressionNode()      {}
-func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) expressionNode()          {}
+func (ae *AssignExpression) TokenLiteral() string     { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.TokenMetadata { return ae.Token.Metadata }
 func (ae *AssignExpression) String() string {
 	var out bytes.Buffer
 
@@ -456,3 +667,146 @@ func (ae *AssignExpression) String() string {
 
 	return out.String()
 }
+
+// BNF: import <string> [as <identifier>];
+type ImportStatement struct {
+	Syntax
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Alias *Identifier // nil unless the module is imported with 'as'
+}
+
+func (is *ImportStatement) statementNode()           {}
+func (is *ImportStatement) TokenLiteral() string     { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.TokenMetadata { return is.Token.Metadata }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString("\"" + is.Path.String() + "\"")
+
+	if is.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(is.Alias.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// Name returns the name the module is bound to: the alias if there is one,
+// otherwise the file name without its extension.
+func (is *ImportStatement) Name() string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+
+	base := filepath.Base(is.Path.Value)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// BNF: <expression>.<identifier>
+type MemberExpression struct {
+	Syntax
+	Token    token.Token // The . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()          {}
+func (me *MemberExpression) TokenLiteral() string     { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.TokenMetadata { return me.Token.Metadata }
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// BNF: throw <expression>;
+type ThrowStatement struct {
+	Syntax
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()           {}
+func (ts *ThrowStatement) TokenLiteral() string     { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.TokenMetadata { return ts.Token.Metadata }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// BNF: try <block> [catch (<identifier>) <block>] [finally <block>]
+type TryExpression struct {
+	Syntax
+	Token     token.Token // the 'try' token
+	Body      *BlockStatement
+	Parameter *Identifier // the name the caught error is bound to
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()          {}
+func (te *TryExpression) TokenLiteral() string     { return te.Token.Literal }
+func (te *TryExpression) Pos() token.TokenMetadata { return te.Token.Metadata }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.Parameter.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// BadExpression stands for an expression with a syntax error, so the rest
+// of the program can still be parsed.
+type BadExpression struct {
+	Syntax
+	Token token.Token // the token the error was found at
+}
+
+func (be *BadExpression) expressionNode()          {}
+func (be *BadExpression) TokenLiteral() string     { return be.Token.Literal }
+func (be *BadExpression) Pos() token.TokenMetadata { return be.Token.Metadata }
+func (be *BadExpression) String() string           { return "<bad expression>" }
+
+// BadStatement stands for a statement with a syntax error, which was
+// skipped up to its end.
+type BadStatement struct {
+	Syntax
+	Token token.Token // the first token of the statement
+}
+
+func (bs *BadStatement) statementNode()           {}
+func (bs *BadStatement) TokenLiteral() string     { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.TokenMetadata { return bs.Token.Metadata }
+func (bs *BadStatement) String() string           { return "<bad statement>" }
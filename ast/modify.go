@@ -15,17 +15,43 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Post, _ = Modify(node.Post, modifier).(Expression)
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *WhileExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForInExpression:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 	case *IndexExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *SliceExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		if node.Low != nil {
+			node.Low, _ = Modify(node.Low, modifier).(Expression)
+		}
+		if node.High != nil {
+			node.High, _ = Modify(node.High, modifier).(Expression)
+		}
+	case *MemberExpression:
+		node.Object, _ = Modify(node.Object, modifier).(Expression)
 	case *IfExpression:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+	case *TryExpression:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+	case *ThrowStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	case *LetStatement:
 		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	case *ReturnStatement:
@@ -40,13 +66,15 @@ func Modify(node Node, modifier ModifierFunc) Node {
 			node.Elements[i], _ = Modify(elem, modifier).(Expression)
 		}
 	case *HashLiteral:
+		newKeys := make([]Expression, len(node.Keys))
 		newPairs := make(map[Expression]Expression)
-		for key, value := range node.Pairs {
+		for i, key := range node.Keys {
 			newKey, _ := Modify(key, modifier).(Expression)
-			newValue, _ := Modify(value, modifier).(Expression)
+			newValue, _ := Modify(node.Pairs[key], modifier).(Expression)
+			newKeys[i] = newKey
 			newPairs[newKey] = newValue
 		}
-		node.Pairs = newPairs
+		node.Keys, node.Pairs = newKeys, newPairs
 	case *CallExpression:
 		node.Function, _ = Modify(node.Function, modifier).(Expression)
 		for i, arg := range node.Arguments {
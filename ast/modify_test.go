@@ -129,15 +129,21 @@ func TestModify(t *testing.T) {
 		}
 	}
 
+	keys := []Expression{one(), one()}
 	hashLiteral := &HashLiteral{
+		Keys: keys,
 		Pairs: map[Expression]Expression{
-			one(): one(),
-			one(): one(),
+			keys[0]: one(),
+			keys[1]: one(),
 		},
 	}
 
 	Modify(hashLiteral, turnOneIntoTwo)
 
+	if len(hashLiteral.Keys) != 2 || len(hashLiteral.Pairs) != 2 {
+		t.Fatalf("wrong number of pairs. got=%d keys, %d pairs", len(hashLiteral.Keys), len(hashLiteral.Pairs))
+	}
+
 	for key, val := range hashLiteral.Pairs {
 		key, _ := key.(*IntegerLiteral)
 		if key.Value != 2 {
@@ -0,0 +1,86 @@
+package ast
+
+import (
+	"compiler-book/token"
+	"strings"
+)
+
+// Type is a type annotation, like the int in let x: int = 1. Annotations
+// are only read by the type checker, programs run the same without them.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// BNF: <identifier>, e.g. int, string or any
+type NamedType struct {
+	Syntax
+	Token token.Token // the token.IDENT token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()                {}
+func (nt *NamedType) TokenLiteral() string     { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.TokenMetadata { return nt.Token.Metadata }
+func (nt *NamedType) String() string           { return nt.Name }
+
+// BNF: [<type>]
+type ArrayType struct {
+	Syntax
+	Token   token.Token // the '[' token
+	Element Type
+}
+
+func (at *ArrayType) typeNode()                {}
+func (at *ArrayType) TokenLiteral() string     { return at.Token.Literal }
+func (at *ArrayType) Pos() token.TokenMetadata { return at.Token.Metadata }
+func (at *ArrayType) String() string           { return "[" + at.Element.String() + "]" }
+
+// BNF: {<type>: <type>}
+type HashType struct {
+	Syntax
+	Token token.Token // the '{' token
+	Key   Type
+	Value Type
+}
+
+func (ht *HashType) typeNode()                {}
+func (ht *HashType) TokenLiteral() string     { return ht.Token.Literal }
+func (ht *HashType) Pos() token.TokenMetadata { return ht.Token.Metadata }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// BNF: fn(<comma separated types>) [: <type>]
+type FunctionType struct {
+	Syntax
+	Token      token.Token // the 'fn' token
+	Parameters []Type
+	Return     Type // nil if the result is not annotated
+}
+
+func (ft *FunctionType) typeNode()                {}
+func (ft *FunctionType) TokenLiteral() string     { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.TokenMetadata { return ft.Token.Metadata }
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+
+	out := ft.TokenLiteral() + "(" + strings.Join(params, ", ") + ")"
+	if ft.Return != nil {
+		out += ": " + ft.Return.String()
+	}
+
+	return out
+}
+
+// declaration is how a declared name is written, with its annotation.
+func declaration(name *Identifier) string {
+	if name.Type == nil {
+		return name.Value
+	}
+
+	return name.Value + ": " + name.Type.String()
+}
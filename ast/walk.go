@@ -0,0 +1,119 @@
+package ast
+
+import "reflect"
+
+// Walk traverses the AST in depth-first order, calling visit for every node
+// before its children. Children are skipped when visit returns false.
+// Unlike Modify, Walk never changes the tree.
+func Walk(node Node, visit func(Node) bool) {
+	if node == nil || isNilNode(node) || !visit(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, statement := range node.Statements {
+			Walk(statement, visit)
+		}
+	case *LetStatement:
+		Walk(node.Name, visit)
+		Walk(node.Value, visit)
+	case *ReturnStatement:
+		Walk(node.ReturnValue, visit)
+	case *ExpressionStatement:
+		Walk(node.Expression, visit)
+	case *BlockStatement:
+		for _, statement := range node.Statements {
+			Walk(statement, visit)
+		}
+	case *PrefixExpression:
+		Walk(node.Right, visit)
+	case *InfixExpression:
+		Walk(node.Left, visit)
+		Walk(node.Right, visit)
+	case *AssignExpression:
+		Walk(node.Left, visit)
+		Walk(node.Value, visit)
+	case *IfExpression:
+		Walk(node.Condition, visit)
+		Walk(node.Consequence, visit)
+		Walk(node.Alternative, visit)
+	case *TryExpression:
+		Walk(node.Body, visit)
+		Walk(node.Parameter, visit)
+		Walk(node.Catch, visit)
+		Walk(node.Finally, visit)
+	case *ThrowStatement:
+		Walk(node.Value, visit)
+	case *ForExpression:
+		Walk(node.Init, visit)
+		Walk(node.Condition, visit)
+		Walk(node.Post, visit)
+		Walk(node.Body, visit)
+	case *WhileExpression:
+		Walk(node.Condition, visit)
+		Walk(node.Body, visit)
+	case *ForInExpression:
+		Walk(node.Key, visit)
+		Walk(node.Value, visit)
+		Walk(node.Iterable, visit)
+		Walk(node.Body, visit)
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Walk(param, visit)
+		}
+		Walk(node.ReturnType, visit)
+		Walk(node.Body, visit)
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Walk(param, visit)
+		}
+		Walk(node.Body, visit)
+	case *CallExpression:
+		Walk(node.Function, visit)
+		for _, arg := range node.Arguments {
+			Walk(arg, visit)
+		}
+	case *ArrayLiteral:
+		for _, element := range node.Elements {
+			Walk(element, visit)
+		}
+	case *IndexExpression:
+		Walk(node.Left, visit)
+		Walk(node.Index, visit)
+	case *SliceExpression:
+		Walk(node.Left, visit)
+		Walk(node.Low, visit)
+		Walk(node.High, visit)
+	case *MemberExpression:
+		Walk(node.Object, visit)
+		Walk(node.Property, visit)
+	case *ImportStatement:
+		Walk(node.Path, visit)
+		Walk(node.Alias, visit)
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			Walk(key, visit)
+			Walk(node.Pairs[key], visit)
+		}
+	case *Identifier:
+		Walk(node.Type, visit)
+	case *ArrayType:
+		Walk(node.Element, visit)
+	case *HashType:
+		Walk(node.Key, visit)
+		Walk(node.Value, visit)
+	case *FunctionType:
+		for _, param := range node.Parameters {
+			Walk(param, visit)
+		}
+		Walk(node.Return, visit)
+	}
+}
+
+// isNilNode reports typed nil pointers stored in a Node, which the parser
+// leaves behind for sub-expressions it failed to parse.
+func isNilNode(node Node) bool {
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
@@ -0,0 +1,52 @@
+package ast
+
+import "testing"
+
+func TestWalk(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{Expression: &AssignExpression{
+				Left:  &Identifier{Value: "x"},
+				Value: &InfixExpression{Left: one(), Operator: "+", Right: one()},
+			}},
+			&ExpressionStatement{Expression: &FunctionLiteral{
+				Parameters: []*Identifier{{Value: "y"}},
+				Body: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: one()},
+				}},
+			}},
+		},
+	}
+
+	integers := 0
+	identifiers := 0
+	Walk(program, func(node Node) bool {
+		switch node.(type) {
+		case *IntegerLiteral:
+			integers++
+		case *Identifier:
+			identifiers++
+		}
+		return true
+	})
+
+	if integers != 3 || identifiers != 2 {
+		t.Errorf("wrong number of visited nodes. integers=%d, identifiers=%d",
+			integers, identifiers)
+	}
+
+	skipped := 0
+	Walk(program, func(node Node) bool {
+		if _, ok := node.(*IntegerLiteral); ok {
+			skipped++
+		}
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+
+	if skipped != 2 {
+		t.Errorf("function body should be skipped. got=%d integers", skipped)
+	}
+}
@@ -0,0 +1,956 @@
+// Package checker finds type errors in Slang programs before they run.
+//
+// Types come from the optional annotations on let statements, parameters
+// and function results, and are inferred everywhere else. What the checker
+// cannot know, like an unannotated parameter, has the type any, which is
+// compatible with every type, so unannotated code is checked leniently.
+package checker
+
+import (
+	"compiler-book/ast"
+	"fmt"
+)
+
+// Error is a type error, found at a position of the source.
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Check returns the type errors of program, in the order of the source.
+func Check(program *ast.Program) []*Error {
+	c := &checker{
+		scope:      newScope(nil),
+		signatures: make(map[*ast.FunctionLiteral]*Function),
+		elements:   make(map[ast.Expression]Type),
+	}
+	for name := range builtins {
+		c.scope.define(name, &Builtin{Name: name}, true)
+	}
+	for name := range signatures {
+		c.scope.define(name, &Builtin{Name: name}, true)
+	}
+	for name := range collections {
+		c.scope.define(name, &Builtin{Name: name}, true)
+	}
+	// namespaces are not checked, like imported modules
+	c.scope.define("math", Any, true)
+	c.scope.define("json", Any, true)
+
+	c.scope = newScope(c.scope)
+	c.statements(program.Statements)
+
+	return c.errors
+}
+
+// binding is what the checker knows of a name.
+type binding struct {
+	typ       Type
+	annotated bool // the type was given, so values of other types can't be assigned
+}
+
+type scope struct {
+	names map[string]*binding
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{names: make(map[string]*binding), outer: outer}
+}
+
+func (s *scope) define(name string, t Type, annotated bool) {
+	s.names[name] = &binding{typ: t, annotated: annotated}
+}
+
+func (s *scope) lookup(name string) (*binding, bool) {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// function is the function literal being checked.
+type function struct {
+	result  Type   // the annotated result, nil if it is inferred
+	returns []Type // the types of the values returned
+}
+
+type checker struct {
+	errors     []*Error
+	scope      *scope
+	function   *function // nil at the top level
+	signatures map[*ast.FunctionLiteral]*Function
+	elements   map[ast.Expression]Type // the types of the elements of literals
+}
+
+func (c *checker) errorf(node ast.Node, format string, a ...interface{}) {
+	pos := node.Pos()
+	c.errors = append(c.errors, &Error{Message: fmt.Sprintf(format, a...), Line: pos.Line, Column: pos.Column})
+}
+
+func (c *checker) open() {
+	c.scope = newScope(c.scope)
+}
+
+func (c *checker) close() {
+	c.scope = c.scope.outer
+}
+
+// statements checks statements and returns the type of the value they
+// produce, and whether the last one leaves the function.
+func (c *checker) statements(statements []ast.Statement) (Type, bool) {
+	var result Type = Null
+	var left bool
+
+	for _, statement := range statements {
+		result, left = Null, false
+
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			c.let(statement)
+		case *ast.ReturnStatement:
+			c.ret(statement)
+			left = true
+		case *ast.ThrowStatement:
+			c.expression(statement.Value)
+			left = true
+		case *ast.ImportStatement:
+			c.scope.define(statement.Name(), Any, false)
+		case *ast.ExpressionStatement:
+			if e, ok := statement.Expression.(*ast.IfExpression); ok {
+				result, left = c.ifExpression(e)
+			} else {
+				result = c.expression(statement.Expression)
+			}
+		}
+	}
+
+	return result, left
+}
+
+// block checks a block in a scope of its own.
+func (c *checker) block(block *ast.BlockStatement) (Type, bool) {
+	if block == nil {
+		return Null, false
+	}
+
+	c.open()
+	defer c.close()
+
+	return c.statements(block.Statements)
+}
+
+func (c *checker) let(let *ast.LetStatement) {
+	annotated := let.Name.Type != nil
+	declared := c.resolve(let.Name.Type)
+
+	// a function can call itself, it is bound before its body is checked
+	if literal, ok := let.Value.(*ast.FunctionLiteral); ok {
+		if annotated {
+			c.scope.define(let.Name.Value, declared, true)
+		} else {
+			c.scope.define(let.Name.Value, c.signature(literal), false)
+		}
+	}
+
+	value := c.expression(let.Value)
+
+	if !annotated {
+		c.scope.define(let.Name.Value, value, false)
+		return
+	}
+
+	if !compatible(declared, value) {
+		c.errorf(let.Value, "cannot assign %s to %s of type %s", value, let.Name.Value, declared)
+	} else {
+		c.literal(declared, let.Value)
+	}
+	c.scope.define(let.Name.Value, declared, true)
+}
+
+func (c *checker) ret(ret *ast.ReturnStatement) {
+	value := c.expression(ret.ReturnValue)
+
+	if c.function == nil {
+		return
+	}
+
+	if c.function.result != nil {
+		if !compatible(c.function.result, value) {
+			c.errorf(ret.ReturnValue, "cannot return %s from a function returning %s", value, c.function.result)
+		} else {
+			c.literal(c.function.result, ret.ReturnValue)
+		}
+	}
+	c.function.returns = append(c.function.returns, value)
+}
+
+// signature returns the type of a function literal from its annotations.
+func (c *checker) signature(literal *ast.FunctionLiteral) *Function {
+	if signature, ok := c.signatures[literal]; ok {
+		return signature
+	}
+
+	signature := &Function{Result: c.resolve(literal.ReturnType)}
+	for _, param := range literal.Parameters {
+		signature.Parameters = append(signature.Parameters, c.resolve(param.Type))
+	}
+
+	c.signatures[literal] = signature
+	return signature
+}
+
+// functionLiteral checks the body of a function and returns its type. An
+// unannotated result is inferred from the values the function returns.
+func (c *checker) functionLiteral(literal *ast.FunctionLiteral) Type {
+	signature := c.signature(literal)
+
+	outer := c.function
+	c.function = &function{}
+	if literal.ReturnType != nil {
+		c.function.result = signature.Result
+	}
+	defer func() { c.function = outer }()
+
+	c.open()
+	defer c.close()
+
+	for i, param := range literal.Parameters {
+		c.scope.define(param.Value, signature.Parameters[i], param.Type != nil)
+	}
+
+	value, left := c.statements(literal.Body.Statements)
+	if !left {
+		if c.function.result != nil && !compatible(c.function.result, value) {
+			c.errorf(last(literal.Body), "cannot return %s from a function returning %s", value, c.function.result)
+		}
+		c.function.returns = append(c.function.returns, value)
+	}
+
+	if c.function.result == nil {
+		signature.Result = join(c.function.returns...)
+	}
+
+	return signature
+}
+
+// ifExpression returns the type of the value of an if expression, and
+// whether both of its branches leave the function.
+func (c *checker) ifExpression(e *ast.IfExpression) (Type, bool) {
+	c.open()
+	defer c.close()
+
+	c.expression(e.Condition)
+	consequence, left := c.block(e.Consequence)
+	alternative, alternativeLeft := c.block(e.Alternative)
+
+	switch {
+	case left && alternativeLeft:
+		return Null, true
+	case left:
+		return alternative, false
+	case alternativeLeft:
+		return consequence, false
+	default:
+		return join(consequence, alternative), false
+	}
+}
+
+// last returns the last statement of a block, or the block if it is empty.
+func last(block *ast.BlockStatement) ast.Node {
+	if len(block.Statements) == 0 {
+		return block
+	}
+
+	return block.Statements[len(block.Statements)-1]
+}
+
+func (c *checker) expression(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.RuneLiteral:
+		return Rune
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		if b, ok := c.scope.lookup(e.Value); ok {
+			return b.typ
+		}
+		// undefined names are found when the program runs
+		return Any
+	case *ast.ArrayLiteral:
+		elements := make([]Type, len(e.Elements))
+		for i, element := range e.Elements {
+			elements[i] = c.expression(element)
+			c.elements[element] = elements[i]
+		}
+
+		if len(elements) == 0 {
+			return &Array{Element: Any}
+		}
+		return &Array{Element: join(elements...)}
+	case *ast.HashLiteral:
+		return c.hashLiteral(e)
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.PostfixExpression:
+		return c.postfix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.AssignExpression:
+		c.assign(e)
+		return Null
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.SliceExpression:
+		return c.slice(e)
+	case *ast.MemberExpression:
+		return c.member(e)
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.FunctionLiteral:
+		return c.functionLiteral(e)
+	case *ast.IfExpression:
+		t, _ := c.ifExpression(e)
+		return t
+	case *ast.ForExpression:
+		c.open()
+		defer c.close()
+
+		c.statements([]ast.Statement{e.Init})
+		c.expression(e.Condition)
+		c.expression(e.Post)
+		c.block(e.Body)
+		return Any
+	case *ast.WhileExpression:
+		c.expression(e.Condition)
+		c.block(e.Body)
+		return Any
+	case *ast.ForInExpression:
+		key, value := c.loopItems(e)
+
+		c.open()
+		defer c.close()
+
+		if e.Key != nil {
+			c.scope.define(e.Key.Value, key, false)
+		}
+		c.scope.define(e.Value.Value, value, false)
+		c.block(e.Body)
+		return Any
+	case *ast.TryExpression:
+		body, _ := c.block(e.Body)
+		if e.Catch == nil {
+			c.block(e.Finally)
+			return body
+		}
+
+		c.open()
+		c.scope.define(e.Parameter.Value, &Hash{Key: String, Value: Any}, false)
+		catch, _ := c.block(e.Catch)
+		c.close()
+
+		c.block(e.Finally)
+		return join(body, catch)
+	default:
+		// macros and syntax errors
+		return Any
+	}
+}
+
+func (c *checker) hashLiteral(hash *ast.HashLiteral) Type {
+	if len(hash.Pairs) == 0 {
+		return &Hash{Key: Any, Value: Any}
+	}
+
+	var keys, values []Type
+	for _, key := range hash.Keys {
+		value := hash.Pairs[key]
+
+		t := c.expression(key)
+		if !hashable(t) {
+			c.errorf(key, "unusable as hash key: %s", t)
+		}
+
+		keys = append(keys, t)
+		values = append(values, c.expression(value))
+		c.elements[key], c.elements[value] = t, values[len(values)-1]
+	}
+
+	return &Hash{Key: join(keys...), Value: join(values...)}
+}
+
+func (c *checker) prefix(prefix *ast.PrefixExpression) Type {
+	right := c.expression(prefix.Right)
+
+	switch prefix.Operator {
+	case "!":
+		return Bool
+	case "-":
+		switch right {
+		case Int, Float, Any:
+			return right
+		}
+	}
+
+	c.errorf(prefix, "unknown operator: %s%s", prefix.Operator, right)
+	return Any
+}
+
+func (c *checker) postfix(postfix *ast.PostfixExpression) Type {
+	t := Type(Any)
+	if b, ok := c.scope.lookup(postfix.Token.Literal); ok {
+		t = b.typ
+	}
+
+	switch t {
+	case Int, Float, Any:
+		return t
+	}
+
+	c.errorf(postfix, "unknown operator: %s%s", postfix.Operator, t)
+	return Any
+}
+
+// infix follows the rules of the evaluator: both operands of arithmetic
+// have the same type, while any two values can be compared for equality.
+func (c *checker) infix(infix *ast.InfixExpression) Type {
+	left, right := c.expression(infix.Left), c.expression(infix.Right)
+	operator := infix.Operator
+
+	switch operator {
+	case "&&", "||", "==", "!=":
+		return Bool
+	}
+
+	comparison := operator == "<" || operator == ">"
+
+	if left == Any || right == Any {
+		if comparison {
+			return Bool
+		}
+		return Any
+	}
+
+	if !same(left, right) {
+		c.errorf(infix, "type mismatch: %s %s %s", left, operator, right)
+		return Any
+	}
+
+	switch {
+	case left == Int || left == Float:
+		if comparison {
+			return Bool
+		}
+		return left
+	case (left == String || left == Rune) && comparison:
+		return Bool
+	case left == String && operator == "+":
+		return String
+	case left == Rune && (operator == "+" || operator == "-"):
+		return Rune
+	}
+
+	c.errorf(infix, "unknown operator: %s %s %s", left, operator, right)
+	return Any
+}
+
+func (c *checker) index(index *ast.IndexExpression) Type {
+	left, key := c.expression(index.Left), c.expression(index.Index)
+
+	switch left := left.(type) {
+	case *Array:
+		if compatible(Int, key) {
+			return left.Element
+		}
+		c.errorf(index.Index, "index must be an integer, got %s", key)
+	case Basic:
+		if left == String {
+			if compatible(Int, key) {
+				return Rune
+			}
+			c.errorf(index.Index, "index must be an integer, got %s", key)
+		} else if left != Any {
+			c.errorf(index, "index operator not supported: %s", left)
+		}
+	case *Hash:
+		if compatible(left.Key, key) {
+			return left.Value
+		}
+		c.errorf(index.Index, "cannot use %s as a key of %s", key, left)
+	default:
+		if left != Any {
+			c.errorf(index, "index operator not supported: %s", left)
+		}
+	}
+
+	return Any
+}
+
+// loopItems checks what a for-in loop goes over, and returns the types of
+// its two variables. Alone, the variable of a loop over a hash gets the
+// keys.
+func (c *checker) loopItems(loop *ast.ForInExpression) (Type, Type) {
+	iterable := c.expression(loop.Iterable)
+
+	switch iterable := iterable.(type) {
+	case *Array:
+		return Int, iterable.Element
+	case *Hash:
+		if loop.Key == nil {
+			return Any, iterable.Key
+		}
+		return iterable.Key, iterable.Value
+	case Basic:
+		switch iterable {
+		case String:
+			return Int, Rune
+		case Any:
+			return Any, Any
+		}
+	}
+
+	c.errorf(loop.Iterable, "cannot loop over %s", iterable)
+	return Any, Any
+}
+
+// slice checks s[low:high], which is of the type of s.
+func (c *checker) slice(slice *ast.SliceExpression) Type {
+	left := c.expression(slice.Left)
+
+	for _, bound := range []ast.Expression{slice.Low, slice.High} {
+		if bound == nil {
+			continue
+		}
+
+		if t := c.expression(bound); !compatible(Int, t) {
+			c.errorf(bound, "slice index must be an integer, got %s", t)
+		}
+	}
+
+	switch left.(type) {
+	case *Array:
+		return left
+	default:
+		if left == String || left == Any {
+			return left
+		}
+		c.errorf(slice, "slice operator not supported: %s", left)
+		return Any
+	}
+}
+
+// member checks h.name, which is the same as h["name"].
+func (c *checker) member(member *ast.MemberExpression) Type {
+	switch object := c.expression(member.Object).(type) {
+	case *Hash:
+		if compatible(object.Key, String) {
+			return object.Value
+		}
+		c.errorf(member.Property, "cannot use string as a key of %s", object)
+	default:
+		if object != Any {
+			c.errorf(member, "index operator not supported: %s", object)
+		}
+	}
+
+	return Any
+}
+
+// assign checks that the value fits where it is assigned. A name without
+// an annotation may be assigned any value, it then has the type any.
+func (c *checker) assign(assign *ast.AssignExpression) {
+	value := c.expression(assign.Value)
+
+	switch left := assign.Left.(type) {
+	case *ast.Identifier:
+		b, ok := c.scope.lookup(left.Value)
+		if !ok {
+			return
+		}
+		if compatible(b.typ, value) {
+			if b.annotated {
+				c.literal(b.typ, assign.Value)
+			}
+			return
+		}
+
+		if b.annotated {
+			c.errorf(assign.Value, "cannot assign %s to %s of type %s", value, left.Value, b.typ)
+		} else {
+			b.typ = Any
+		}
+	case *ast.IndexExpression, *ast.MemberExpression:
+		target := c.expression(left)
+		if !compatible(target, value) {
+			c.errorf(assign.Value, "cannot assign %s to an element of type %s", value, target)
+		} else {
+			c.literal(target, assign.Value)
+		}
+	}
+}
+
+// literal checks the elements of an array or hash literal one by one
+// against the expected type. The literal itself is only as precise as its
+// elements allow: [1, "b"] is an [any], which is compatible with every array.
+func (c *checker) literal(expected Type, value ast.Expression) {
+	switch value := value.(type) {
+	case *ast.ArrayLiteral:
+		if array, ok := expected.(*Array); ok {
+			for _, element := range value.Elements {
+				c.element(array.Element, element, expected)
+			}
+		}
+	case *ast.HashLiteral:
+		if hash, ok := expected.(*Hash); ok {
+			for _, key := range value.Keys {
+				c.element(hash.Key, key, expected)
+				c.element(hash.Value, value.Pairs[key], expected)
+			}
+		}
+	}
+}
+
+// element checks an element of a literal of type container.
+func (c *checker) element(expected Type, element ast.Expression, container Type) {
+	t := c.elements[element]
+	if !compatible(expected, t) {
+		c.errorf(element, "cannot use %s as %s in %s", t, expected, container)
+		return
+	}
+	c.literal(expected, element)
+}
+
+func (c *checker) call(call *ast.CallExpression) Type {
+	// quote is a special form, its argument is not evaluated
+	if call.Function.TokenLiteral() == "quote" {
+		return Any
+	}
+
+	callee := c.expression(call.Function)
+
+	args := make([]Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	switch callee := callee.(type) {
+	case *Function:
+		return c.arguments(call, callee, args)
+	case *Builtin:
+		return c.builtin(call, callee.Name, args)
+	default:
+		if callee != Any {
+			c.errorf(call.Function, "cannot call %s", callee)
+		}
+		return Any
+	}
+}
+
+// arguments checks the arguments of a call to a function of type callee.
+func (c *checker) arguments(call *ast.CallExpression, callee *Function, args []Type) Type {
+	if len(args) != len(callee.Parameters) {
+		c.errorf(call.Function, "wrong number of arguments to %s. got=%d, want=%d",
+			call.Function, len(args), len(callee.Parameters))
+		return callee.Result
+	}
+
+	for i, param := range callee.Parameters {
+		if !compatible(param, args[i]) {
+			c.errorf(call.Arguments[i], "cannot use %s as %s in argument %d to %s",
+				args[i], param, i+1, call.Function)
+		} else {
+			c.literal(param, call.Arguments[i])
+		}
+	}
+	return callee.Result
+}
+
+func fn(result Type, params ...Type) *Function {
+	return &Function{Parameters: params, Result: result}
+}
+
+// signatures are the types of the builtins that take values of one type.
+var signatures = map[string]*Function{
+	"split":      fn(&Array{Element: String}, String, String),
+	"join":       fn(String, &Array{Element: String}, String),
+	"startsWith": fn(Bool, String, String),
+	"endsWith":   fn(Bool, String, String),
+	"index":      fn(Int, String, String),
+	"replace":    fn(String, String, String, String),
+	"trim":       fn(String, String),
+	"upper":      fn(String, String),
+	"lower":      fn(String, String),
+	"repeat":     fn(String, String, Int),
+	"int":        fn(Int, Any),
+	"float":      fn(Float, Any),
+	"readFile":   fn(String, String),
+	"writeFile":  fn(Null, String, String),
+	"appendFile": fn(Null, String, String),
+	"listDir":    fn(&Array{Element: String}, String),
+	"exists":     fn(Bool, String),
+	"env":        fn(Any, String), // null when the variable is not set
+	"args":       fn(&Array{Element: String}),
+	"readLine":   fn(Any), // null at the end of the input
+}
+
+// builtins are checked by builtin, the others by their signatures.
+var builtins = map[string]bool{
+	"len": true, "print": true, "printf": true, "eprint": true, "push": true, "pop": true, "first": true, "rest": true,
+	"substr": true, "exit": true,
+}
+
+func (c *checker) builtin(call *ast.CallExpression, name string, args []Type) Type {
+	if signature, ok := signatures[name]; ok {
+		return c.arguments(call, signature, args)
+	}
+
+	if _, ok := collections[name]; ok {
+		return c.collection(call, name, args)
+	}
+
+	if name == "substr" {
+		if len(args) != 2 && len(args) != 3 {
+			c.errorf(call.Function, "wrong number of arguments to %s. got=%d, want=2 or 3", name, len(args))
+			return String
+		}
+		params := []Type{String, Int, Int}
+		return c.arguments(call, fn(String, params[:len(args)]...), args)
+	}
+
+	if name == "exit" {
+		if len(args) > 1 {
+			c.errorf(call.Function, "wrong number of arguments to %s. got=%d, want=0 or 1", name, len(args))
+			return Null
+		}
+		return c.arguments(call, fn(Null, []Type{Int}[:len(args)]...), args)
+	}
+
+	want := map[string]int{"len": 1, "push": 2, "pop": 1, "first": 1, "rest": 1}[name]
+	if want != 0 && len(args) != want {
+		c.errorf(call.Function, "wrong number of arguments to %s. got=%d, want=%d", name, len(args), want)
+		return Any
+	}
+
+	// the argument that builtins other than print take first
+	unsupported := func(i int) {
+		c.errorf(call.Arguments[i], "argument to `%s` not supported, got %s", name, args[i])
+	}
+
+	switch name {
+	case "len":
+		if _, ok := args[0].(*Array); !ok && args[0] != String && args[0] != Any {
+			unsupported(0)
+		}
+		return Int
+	case "print", "eprint":
+		return Null
+	case "printf":
+		if len(args) == 0 {
+			c.errorf(call.Function, "wrong number of arguments to %s. got=0, want=1", name)
+		} else if !compatible(String, args[0]) {
+			unsupported(0)
+		}
+		return Null
+	}
+
+	array, ok := args[0].(*Array)
+	if !ok {
+		if args[0] != Any {
+			unsupported(0)
+		}
+		return Any
+	}
+
+	switch name {
+	case "push":
+		if !compatible(array.Element, args[1]) {
+			c.errorf(call.Arguments[1], "cannot push %s to %s", args[1], array)
+		} else {
+			c.literal(array.Element, call.Arguments[1])
+		}
+		return Null
+	case "rest":
+		return array
+	default:
+		return array.Element
+	}
+}
+
+// collections are the builtins on arrays and hashes, with the least and
+// the most arguments they take, -1 when there is no most.
+var collections = map[string][2]int{
+	"map": {2, 2}, "filter": {2, 2}, "reduce": {3, 3}, "sort": {1, 2}, "range": {1, 3},
+	"reverse": {1, 1}, "zip": {2, 2}, "enumerate": {1, 1}, "slice": {2, 3}, "concat": {0, -1},
+	"indexOf": {2, 2}, "contains": {2, 2},
+	"keys": {1, 1}, "values": {1, 1}, "items": {1, 1}, "has": {2, 2}, "delete": {2, 2}, "merge": {0, -1},
+}
+
+func (c *checker) collection(call *ast.CallExpression, name string, args []Type) Type {
+	least, most := collections[name][0], collections[name][1]
+	if len(args) < least || most != -1 && len(args) > most {
+		want := fmt.Sprint(least)
+		switch {
+		case most == least+1:
+			want = fmt.Sprintf("%d or %d", least, most)
+		case most > least:
+			want = fmt.Sprintf("%d to %d", least, most)
+		}
+
+		c.errorf(call.Function, "wrong number of arguments to %s. got=%d, want=%s", name, len(args), want)
+		return Any
+	}
+
+	// array returns the argument i when it is an array, nil when it may be
+	// one
+	array := func(i int) *Array {
+		array, ok := args[i].(*Array)
+		if !ok && args[i] != Any {
+			c.errorf(call.Arguments[i], "argument to `%s` not supported, got %s", name, args[i])
+		}
+		return array
+	}
+
+	hash := func(i int) *Hash {
+		hash, ok := args[i].(*Hash)
+		if !ok && args[i] != Any {
+			c.errorf(call.Arguments[i], "argument to `%s` not supported, got %s", name, args[i])
+		}
+		return hash
+	}
+
+	// function returns the result of the argument i, a function
+	function := func(i int) Type {
+		switch f := args[i].(type) {
+		case *Function:
+			return f.Result
+		case *Builtin:
+			return Any
+		}
+
+		if args[i] != Any {
+			c.errorf(call.Arguments[i], "cannot use %s as a function in argument %d to %s", args[i], i+1, name)
+		}
+		return Any
+	}
+
+	integers := func(from int) {
+		for i := from; i < len(args); i++ {
+			if !compatible(Int, args[i]) {
+				c.errorf(call.Arguments[i], "cannot use %s as int in argument %d to %s", args[i], i+1, name)
+			}
+		}
+	}
+
+	pairs := func(a, b Type) Type {
+		return &Array{Element: &Array{Element: join(a, b)}}
+	}
+
+	switch name {
+	case "map":
+		array(0)
+		return &Array{Element: function(1)}
+	case "filter", "sort":
+		if len(args) == 2 {
+			function(1)
+		}
+		if a := array(0); a != nil {
+			return a
+		}
+		return Any
+	case "reduce":
+		array(0)
+		if result := function(2); result != Any {
+			return result
+		}
+		return args[1]
+	case "range":
+		integers(0)
+		return &Array{Element: Int}
+	case "reverse":
+		if a := array(0); a != nil {
+			return a
+		}
+		return Any
+	case "zip":
+		a, b := array(0), array(1)
+		if a == nil || b == nil {
+			return pairs(Any, Any)
+		}
+		return pairs(a.Element, b.Element)
+	case "enumerate":
+		if a := array(0); a != nil {
+			return pairs(Int, a.Element)
+		}
+		return pairs(Int, Any)
+	case "slice":
+		integers(1)
+		if _, ok := args[0].(*Array); ok || args[0] == String || args[0] == Any {
+			return args[0]
+		}
+		c.errorf(call.Arguments[0], "argument to `%s` not supported, got %s", name, args[0])
+		return Any
+	case "concat", "merge":
+		for i := range args {
+			if name == "concat" {
+				array(i)
+			} else {
+				hash(i)
+			}
+		}
+		if result := join(args...); len(args) > 0 && result != Any {
+			return result
+		}
+		if name == "concat" {
+			return &Array{Element: Any}
+		}
+		return &Hash{Key: Any, Value: Any}
+	case "indexOf":
+		array(0)
+		return Int
+	case "contains":
+		if args[0] == String {
+			if !compatible(String, args[1]) {
+				c.errorf(call.Arguments[1], "cannot use %s as string in argument 2 to %s", args[1], name)
+			}
+		} else {
+			array(0)
+		}
+		return Bool
+	case "has":
+		hash(0)
+		return Bool
+	case "keys":
+		if h := hash(0); h != nil {
+			return &Array{Element: h.Key}
+		}
+		return &Array{Element: Any}
+	case "values":
+		if h := hash(0); h != nil {
+			return &Array{Element: h.Value}
+		}
+		return &Array{Element: Any}
+	case "items":
+		if h := hash(0); h != nil {
+			return pairs(h.Key, h.Value)
+		}
+		return pairs(Any, Any)
+	default: // delete
+		if h := hash(0); h != nil {
+			return h.Value
+		}
+		return Any
+	}
+}
@@ -0,0 +1,232 @@
+package checker
+
+import (
+	"bytes"
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// unannotated code is checked leniently
+		{`let add = fn(a, b) { a + b }; add(1, 2); add("a", "b")`, nil},
+		{`let x = 1; x = "one"; x - "two"`, nil},
+		{`let id = fn(x) { x }; id(1) + id("a")`, nil},
+
+		{`"a" - 1`, []string{`1:5: type mismatch: string - int`}},
+		{`let s = "a"; let n = 1; s + n`, []string{`1:27: type mismatch: string + int`}},
+		{`true + false; "a" * "b"; 'a' + 'b'`, []string{
+			`1:6: unknown operator: bool + bool`,
+			`1:19: unknown operator: string * string`,
+		}},
+		{`-"a"; !"a"; let s = "s"; s++`, []string{`1:1: unknown operator: -string`, `1:26: unknown operator: ++string`}},
+		{`1 == "a"; 1.5 < 2.5; 1 && "a"`, nil},
+		{`let b: bool = "a" < "b"; let c: bool = 'a' == 'b'; let d: bool = "a" != "b"; 'a' > 'b'`, nil},
+		{`"a" < 1; 'a' * 'b'`, []string{`1:5: type mismatch: string < int`, `1:14: unknown operator: rune * rune`}},
+		{`let x: int = 7 % 2 ** 3; let y: float = 1.5 % 2.0; x ** 0.5`, []string{`1:54: type mismatch: int ** float`}},
+		{`let x: int = int(1.5); let y: float = float(1); let z: int = float(2)`, []string{
+			`1:67: cannot assign float to z of type int`,
+		}},
+
+		// annotations
+		{`let x: int = "a"`, []string{`1:14: cannot assign string to x of type int`}},
+		{`let x: int = 1; x = 2; x = 1.5`, []string{`1:28: cannot assign float to x of type int`}},
+		{`let xs: [int] = [1, 2]; let h: {string: [int]} = {"a": xs}; let f: fn(int): int = fn(x: int): int { x }`, nil},
+		{`let xs: [string] = [1, 2]`, []string{`1:20: cannot assign [int] to xs of type [string]`}},
+		{`let h: {[null]: int} = {}; let y: integer = 1`, []string{
+			`1:9: unusable as hash key: [null]`,
+			`1:35: unknown type integer`,
+		}},
+
+		// functions and closures
+		{`let f = fn(a: int, b: string) { b }; f("a", "b"); f(1)`, []string{
+			`1:40: cannot use string as int in argument 1 to f`,
+			`1:51: wrong number of arguments to f. got=1, want=2`,
+		}},
+		{`let f = fn(): int { "a" }; let g = fn(x): string { if (x) { return 1 } "b" }`, []string{
+			`1:21: cannot return string from a function returning int`,
+			`1:68: cannot return int from a function returning string`,
+		}},
+		{`let f = fn(n: int): int { if (n < 2) { return n } else { return f(n - 1) } }`, nil},
+		{`let f = fn() { 1 }; f() - "a"`, []string{`1:25: type mismatch: int - string`}},
+		{`let adder = fn(x: int) { fn(y: int) { x + y } }; adder(1)(2) + "a"`, []string{`1:62: type mismatch: int + string`}},
+		{`let apply = fn(f: fn(int): int) { f(1) }; apply(fn(x: string) { x }); apply(len)`, []string{
+			`1:49: cannot use fn(string): string as fn(int): int in argument 1 to apply`,
+		}},
+		{`let n = 1; n(2)`, []string{`1:12: cannot call int`}},
+
+		// arrays and hashes
+		{`let xs = [1, 2]; xs[0] - "a"; xs["a"]; push(xs, "c"); first(xs) + 1; rest(xs)[0]`, []string{
+			`1:24: type mismatch: int - string`,
+			`1:34: index must be an integer, got string`,
+			`1:49: cannot push string to [int]`,
+		}},
+		{`let h = {"a": 1}; h["b"] - 1; h.a - "x"; h[1]; h["a"] = "b"`, []string{
+			`1:35: type mismatch: int - string`,
+			`1:44: cannot use int as a key of {string: int}`,
+			`1:57: cannot assign string to an element of type int`,
+		}},
+		{`let mixed = [1, "a"]; mixed[0] - "b"; {[fn() { 1 }]: 2}`, []string{`1:40: unusable as hash key: [fn(): int]`}},
+		{`let arr: [int] = [1, "b"]; let f = fn(xs: [int]) { xs }; f([1, true]); f([1, 2])`, []string{
+			`1:22: cannot use string as int in [int]`,
+			`1:64: cannot use bool as int in [int]`,
+		}},
+		{`let grid: [[int]] = [[1], [2, 3.5]]; let h: {string: int} = {"a": 1, "b": "c"}; h = {1: 2, "d": 3}`, []string{
+			`1:31: cannot use float as int in [int]`,
+			`1:75: cannot use string as int in {string: int}`,
+			`1:86: cannot use int as string in {string: int}`,
+		}},
+		{`let g = fn(): [string] { return ["a", 1] }; let ys: [int] = []; push(ys, [1]); let zs: [[int]] = []; push(zs, [1, "a"])`, []string{
+			`1:39: cannot use int as string in [string]`,
+			`1:74: cannot push [int] to [int]`,
+			`1:115: cannot use string as int in [int]`,
+		}},
+		{`let h = {[1, 2]: "a"}; h[[1, 2]] - 1; h[[1.5]]; {'b': 1}['b'] + 1`, []string{
+			`1:34: type mismatch: string - int`,
+			`1:41: cannot use [float] as a key of {[int]: string}`,
+		}},
+		{`1[0]; "s".x; len(1); len("s") + 1`, []string{
+			`1:2: index operator not supported: int`,
+			`1:10: index operator not supported: string`,
+			`1:18: argument to ` + "`len`" + ` not supported, got int`,
+		}},
+
+		// strings
+		{`let s = "héllo"; s[0] + 'a'; s[1:] + "!"; s["a"]; s[1:"b"]; 1[:2]`, []string{
+			`1:45: index must be an integer, got string`,
+			`1:55: slice index must be an integer, got string`,
+			`1:62: slice operator not supported: int`,
+		}},
+		{`split("a,b", ",")[0] + 1; join(split("a b", " "), 1); upper("a") + lower("B"); substr("abc", 1) + substr("abc", 0, "x")`, []string{
+			`1:22: type mismatch: string + int`,
+			`1:51: cannot use int as string in argument 2 to join`,
+			`1:116: cannot use string as int in argument 3 to substr`,
+		}},
+		{`contains("a") && index("a", "b") > 0; substr("a")`, []string{
+			`1:1: wrong number of arguments to contains. got=1, want=2`,
+			`1:39: wrong number of arguments to substr. got=1, want=2 or 3`,
+		}},
+
+		// collections
+		{`map([1, 2], fn(x: int): string { "a" })[0] - 1; filter(["a"], fn(x) { true })[0] - 1; range(3)[0] + 1`, []string{
+			`1:44: type mismatch: string - int`,
+			`1:82: type mismatch: string - int`,
+		}},
+		{`reduce([1], 0, fn(a: int, x: int): int { a + x }) + "a"; keys({"a": 1})[0] + 1; values({"a": 1})[0] + 1`, []string{
+			`1:51: type mismatch: int + string`,
+			`1:76: type mismatch: string + int`,
+		}},
+		{`map(1, fn(x) { x }); sort([1], 2); range("a"); contains("a", 1); has([1], 1); slice(1, 2)`, []string{
+			`1:5: argument to ` + "`map`" + ` not supported, got int`,
+			`1:32: cannot use int as a function in argument 2 to sort`,
+			`1:42: cannot use string as int in argument 1 to range`,
+			`1:62: cannot use int as string in argument 2 to contains`,
+			`1:70: argument to ` + "`has`" + ` not supported, got [int]`,
+			`1:85: argument to ` + "`slice`" + ` not supported, got int`,
+		}},
+		{`sort(); range(1, 2, 3, 4); concat([1], ["a"])[0]; merge()`, []string{
+			`1:1: wrong number of arguments to sort. got=0, want=1 or 2`,
+			`1:9: wrong number of arguments to range. got=4, want=1 to 3`,
+		}},
+
+		// system
+		{`readFile("a") + 1; writeFile("a", 1); len(listDir(".")) + len(args()); exit(); exit("a"); exit(1, 2)`, []string{
+			`1:15: type mismatch: string + int`,
+			`1:35: cannot use int as string in argument 2 to writeFile`,
+			`1:85: cannot use string as int in argument 1 to exit`,
+			`1:91: wrong number of arguments to exit. got=2, want=0 or 1`,
+		}},
+
+		// scopes
+		{`let x = "a"; let f = fn(x: int) { x + 1 }; if (true) { let x = 1; x + 1 }; x + "b"`, nil},
+		{`try { throw "a" } catch (e) { e.message + "!" }`, nil},
+		{`import "lib.sl" as lib; lib.f(1) + 1`, nil},
+		{`for (i, x in ["a"]) { i + x }; for (i, ch in "ab") { ch + 1 }; for (x in 1) { x }`, []string{
+			`1:25: type mismatch: int + string`,
+			`1:57: type mismatch: rune + int`,
+			`1:74: cannot loop over int`,
+		}},
+		{`let h: {string: int} = {}; for (k in h) { k + "!" }; for (k, v in h) { v + 1 }`, nil},
+		{`let i = 0; while (i < "a") { let s = "s"; s - 1 }`, []string{
+			`1:21: type mismatch: int < string`,
+			`1:45: type mismatch: string - int`,
+		}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q has syntax errors: %v", tt.input, p.Errors()[0])
+		}
+
+		var errors []string
+		for _, err := range Check(program) {
+			errors = append(errors, err.Error())
+		}
+
+		if strings.Join(errors, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant:\n%s\ngot:\n%s", tt.input,
+				strings.Join(tt.expected, "\n"), strings.Join(errors, "\n"))
+		}
+	}
+}
+
+func TestSamples(t *testing.T) {
+	files, err := filepath.Glob("../samples/*.sl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		program := parser.New(lexer.New(string(src))).ParseProgram()
+		if errors := Check(program); len(errors) != 0 {
+			t.Errorf("%s has type errors: %v", file, errors)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.sl")
+	bad := filepath.Join(dir, "nested", "bad.sl")
+
+	os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+	os.WriteFile(good, []byte("let x: int = 1;\n"), 0o644)
+	os.WriteFile(bad, []byte("let x: int = 1;\nx + \"a\"\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("let = 1"), 0o644)
+
+	run := func(stdin string, args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String() + stderr.String()
+	}
+
+	if code, out := run("", dir); code != 1 || out != bad+":2:3: type mismatch: int + string\n" {
+		t.Errorf("wrong check of the directory. got=%d %q", code, out)
+	}
+
+	if code, out := run("", good); code != 0 || out != "" {
+		t.Errorf("wrong check of a good file. got=%d %q", code, out)
+	}
+
+	if code, out := run("let = 1"); code != 1 || out != "<stdin>:1:5: expected next token to be IDENT, got = instead\n" {
+		t.Errorf("wrong check of a syntax error. got=%d %q", code, out)
+	}
+
+	if code, _ := run("", "--unknown"); code != 2 {
+		t.Errorf("wrong code for a wrong flag. got=%d", code)
+	}
+}
@@ -0,0 +1,99 @@
+package checker
+
+import (
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const usage = `usage: slang check [path ...]
+
+Reports the type errors of Slang programs without running them.
+Directories are searched for .sl files. Without paths, the program is
+read from the standard input.
+`
+
+// Run runs slang check with args and returns its exit code: 1 if a program
+// has syntax or type errors, 2 for wrong arguments.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	code := 0
+	check := func(name string, src []byte) {
+		if !checkSource(name, src, stdout) {
+			code = 1
+		}
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		check("<stdin>", src)
+		return code
+	}
+
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// the files given are checked whatever their extension
+			if entry.IsDir() || path != root && filepath.Ext(path) != ".sl" {
+				return nil
+			}
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			check(path, src)
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// checkSource prints the errors of the program in src, named name, and
+// reports whether it has none.
+func checkSource(name string, src []byte, out io.Writer) bool {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, e := range p.Errors() {
+			fmt.Fprintf(out, "%s:%d:%d: %s\n", name, e.Line, e.Column, e.Message)
+		}
+		return false
+	}
+
+	errors := Check(program)
+	for _, e := range errors {
+		fmt.Fprintf(out, "%s:%s\n", name, e)
+	}
+
+	return len(errors) == 0
+}
@@ -0,0 +1,187 @@
+package checker
+
+import (
+	"compiler-book/ast"
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of a value. Types are structural, two types are
+// the same when they are written the same.
+type Type interface {
+	String() string
+}
+
+// Basic is the type of the values that hold no other values, or any.
+type Basic string
+
+const (
+	Int    Basic = "int"
+	Float  Basic = "float"
+	String Basic = "string"
+	Rune   Basic = "rune"
+	Bool   Basic = "bool"
+	Null   Basic = "null"
+
+	// Any is the type of the values the checker knows nothing about, like
+	// parameters without annotations. It is compatible with every type.
+	Any Basic = "any"
+)
+
+func (b Basic) String() string { return string(b) }
+
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+type Function struct {
+	Parameters []Type
+	Result     Type
+}
+
+func (f *Function) String() string {
+	params := make([]string, len(f.Parameters))
+	for i, param := range f.Parameters {
+		params[i] = param.String()
+	}
+
+	return "fn(" + strings.Join(params, ", ") + "): " + f.Result.String()
+}
+
+// Builtin is the type of a builtin function, whose calls are checked one
+// by one since builtins take values of many types.
+type Builtin struct {
+	Name string
+}
+
+func (b *Builtin) String() string { return "builtin " + b.Name }
+
+// same reports whether a and b are the same type.
+func same(a, b Type) bool {
+	return a.String() == b.String()
+}
+
+// compatible reports whether a value of one type can be used where the
+// other is expected. Any is compatible with every type, also inside
+// arrays, hashes and functions.
+func compatible(a, b Type) bool {
+	if a == Any || b == Any {
+		return true
+	}
+
+	switch a := a.(type) {
+	case Basic:
+		return a == b
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && compatible(a.Element, b.Element)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && compatible(a.Key, b.Key) && compatible(a.Value, b.Value)
+	case *Function:
+		switch b := b.(type) {
+		case *Builtin:
+			return true
+		case *Function:
+			if len(a.Parameters) != len(b.Parameters) {
+				return false
+			}
+
+			for i := range a.Parameters {
+				if !compatible(a.Parameters[i], b.Parameters[i]) {
+					return false
+				}
+			}
+			return compatible(a.Result, b.Result)
+		}
+	case *Builtin:
+		switch b.(type) {
+		case *Function, *Builtin:
+			return true
+		}
+	}
+
+	return false
+}
+
+// join returns the type of a value that has one of types, Any unless they
+// are all the same.
+func join(types ...Type) Type {
+	if len(types) == 0 {
+		return Null
+	}
+
+	for _, t := range types[1:] {
+		if !same(t, types[0]) {
+			return Any
+		}
+	}
+
+	return types[0]
+}
+
+// hashable reports whether values of t can be hash keys. Arrays can when
+// their elements can.
+func hashable(t Type) bool {
+	if array, ok := t.(*Array); ok {
+		return hashable(array.Element)
+	}
+
+	switch t {
+	case Int, Float, String, Rune, Bool, Any:
+		return true
+	default:
+		return false
+	}
+}
+
+var named = map[string]Type{
+	"int":    Int,
+	"float":  Float,
+	"string": String,
+	"rune":   Rune,
+	"bool":   Bool,
+	"null":   Null,
+	"any":    Any,
+}
+
+// resolve returns the type an annotation stands for, Any when there is no
+// annotation.
+func (c *checker) resolve(annotation ast.Type) Type {
+	switch annotation := annotation.(type) {
+	case nil:
+		return Any
+	case *ast.NamedType:
+		t, ok := named[annotation.Name]
+		if !ok {
+			c.errorf(annotation, "unknown type %s", annotation.Name)
+			return Any
+		}
+		return t
+	case *ast.ArrayType:
+		return &Array{Element: c.resolve(annotation.Element)}
+	case *ast.HashType:
+		key := c.resolve(annotation.Key)
+		if !hashable(key) {
+			c.errorf(annotation.Key, "unusable as hash key: %s", key)
+		}
+		return &Hash{Key: key, Value: c.resolve(annotation.Value)}
+	case *ast.FunctionType:
+		function := &Function{Result: c.resolve(annotation.Return)}
+		for _, param := range annotation.Parameters {
+			function.Parameters = append(function.Parameters, c.resolve(param))
+		}
+		return function
+	default:
+		panic(fmt.Sprintf("unknown annotation %T", annotation))
+	}
+}
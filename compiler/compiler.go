@@ -0,0 +1,840 @@
+package compiler
+
+import (
+	"compiler-book/ast"
+	"compiler-book/code"
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"fmt"
+)
+
+// Builtins are the names of the builtins programs find, in the order of
+// their OpGetBuiltin operands: the ones of object first, then the other
+// standard builtins of the evaluator, sorted.
+var Builtins = builtinNames()
+
+func builtinNames() []string {
+	names := make([]string, 0, len(object.Builtins))
+	shared := make(map[string]bool)
+	for _, def := range object.Builtins {
+		names = append(names, def.Name)
+		shared[def.Name] = true
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		if !shared[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+	mainLocals  int // the local slots of the top-level for-in loops
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+type CompilationScope struct {
+	instructions code.Instructions
+	loops        []*loop // the loops being compiled, innermost last
+}
+
+// loop is a loop being compiled, with the jumps of its break and continue
+// statements, which go to places that are not known yet.
+type loop struct {
+	label     string
+	breaks    []int
+	continues []int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string // name of every global slot, for error messages
+	NumLocals    int      // the local slots of the main program
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{instructions: code.Instructions{}}
+
+	symbolTable := NewSymbolTable()
+
+	for i, name := range Builtins {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState keeps globals and constants across compilations, which is
+// what the REPL needs to remember definitions from previous lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// NewSymbolTableWithBuiltins returns the global table a fresh compiler
+// starts from.
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	return New().symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		if err := c.compileBlock(node.Statements); err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		return c.compileBlock(node.Statements)
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+
+		c.emit(code.OpReturnValue)
+	// Expressions
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: lexer.Unescape(node.Value)}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.RuneLiteral:
+		r := &object.Rune{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(r))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+	case *ast.PostfixExpression:
+		c.loadSymbol(c.resolve(node.TokenLiteral()))
+
+		switch node.Operator {
+		case "++":
+			c.emit(code.OpIncrement)
+		case "--":
+			c.emit(code.OpDecrement)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ForExpression:
+		return c.compileForExpression(node)
+	case *ast.WhileExpression:
+		return c.compileWhileExpression(node)
+	case *ast.ForInExpression:
+		return c.compileForInExpression(node)
+	case *ast.BreakStatement:
+		return c.compileJump(node.Label, node.TokenLiteral(), func(l *loop, pos int) { l.breaks = append(l.breaks, pos) })
+	case *ast.ContinueStatement:
+		return c.compileJump(node.Label, node.TokenLiteral(), func(l *loop, pos int) { l.continues = append(l.continues, pos) })
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+
+		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		// the bounds left out are not on the stack
+		bounds := 0
+		for i, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				continue
+			}
+			if err := c.Compile(bound); err != nil {
+				return err
+			}
+			bounds |= 1 << i
+		}
+
+		c.emit(code.OpSlice, bounds)
+	case *ast.MemberExpression:
+		// h.name is the same as h["name"]
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Property.Value}))
+		c.emit(code.OpIndex)
+	case *ast.ImportStatement:
+		return fmt.Errorf("import is only supported by the eval engine")
+	case *ast.TryExpression, *ast.ThrowStatement:
+		return fmt.Errorf("exceptions are only supported by the eval engine")
+	case *ast.BadExpression, *ast.BadStatement:
+		return fmt.Errorf("cannot compile a syntax error at line %d, column %d", node.Pos().Line, node.Pos().Column)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		// quote is a special form of the evaluator, macros are expanded
+		// before compiling so only stray quote calls end up here
+		if node.Function.TokenLiteral() == "quote" {
+			return fmt.Errorf("quote is not supported outside of macros")
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.MacroLiteral:
+		// macro definitions are removed by DefineMacros, like in Eval any
+		// other macro literal evaluates to null
+		c.emit(code.OpNull)
+	default:
+		return fmt.Errorf("unsupported node %T", node)
+	}
+
+	return nil
+}
+
+// compileBlock compiles stmts so they leave exactly one value on the stack:
+// the value of the last statement, or null when it is not an expression.
+func (c *Compiler) compileBlock(stmts []ast.Statement) error {
+	if len(stmts) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	for i, s := range stmts {
+		last := i == len(stmts)-1
+
+		if stmt, ok := s.(*ast.ExpressionStatement); ok {
+			if err := c.Compile(stmt.Expression); err != nil {
+				return err
+			}
+
+			if !last {
+				c.emit(code.OpPop)
+			}
+			continue
+		}
+
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+
+		if last {
+			c.emit(code.OpNull)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	// functions may refer to themselves, every other value is evaluated
+	// before the name is bound, e.g. `let x = x + 1` reads the outer x
+	if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol := c.define(node.Name.Value)
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.storeSymbol(symbol, code.OpSetGlobal)
+		return nil
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	c.storeSymbol(c.define(node.Name.Value), code.OpSetGlobal)
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	// Eval evaluates the right operand first, keep the same order for
+	// side effects and errors
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	switch node.Operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "**":
+		c.emit(code.OpPow)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	case "&&":
+		c.emit(code.OpAnd)
+	case "||":
+		c.emit(code.OpOr)
+	default:
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		symbol := c.resolve(left.Value)
+		if symbol.Scope == BuiltinScope {
+			return fmt.Errorf("identifier not found: %s", left.Value)
+		}
+
+		c.storeSymbol(symbol, code.OpAssignGlobal)
+		c.emit(code.OpNull)
+	case *ast.IndexExpression:
+		if err := c.Compile(left.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(left.Index); err != nil {
+			return err
+		}
+
+		c.emit(code.OpSetIndex)
+	case *ast.MemberExpression:
+		if err := c.Compile(left.Object); err != nil {
+			return err
+		}
+
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: left.Property.Value}))
+		c.emit(code.OpSetIndex)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit an `OpJumpNotTruthy` with a bogus value
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlock(node.Consequence.Statements); err != nil {
+		return err
+	}
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlock(node.Alternative.Statements); err != nil {
+			return err
+		}
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileForExpression keeps the value of the last iteration on the stack,
+// starting with null for loops whose body never runs.
+func (c *Compiler) compileForExpression(node *ast.ForExpression) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	if err := c.Compile(node.Init); err != nil {
+		return err
+	}
+
+	c.emit(code.OpNull)
+	l := c.enterLoop(node.Label)
+
+	loopStart := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.emit(code.OpPop) // the value of the previous iteration
+
+	if err := c.compileBlock(node.Body.Statements); err != nil {
+		return err
+	}
+
+	next := len(c.currentInstructions())
+
+	if err := c.Compile(node.Post); err != nil {
+		return err
+	}
+
+	c.emit(code.OpPop)
+	c.emit(code.OpJump, loopStart)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	c.leaveLoop(l, next)
+
+	return nil
+}
+
+// compileWhileExpression keeps the value of the last iteration on the stack,
+// like compileForExpression.
+func (c *Compiler) compileWhileExpression(node *ast.WhileExpression) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	c.emit(code.OpNull)
+	l := c.enterLoop(node.Label)
+
+	loopStart := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.emit(code.OpPop) // the value of the previous iteration
+
+	if err := c.compileBlock(node.Body.Statements); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	c.leaveLoop(l, loopStart)
+
+	return nil
+}
+
+// iteratorName names the slot holding the iterator of a for-in loop. It is
+// no identifier, so programs cannot use it.
+const iteratorName = "<iterator>"
+
+// compileForInExpression keeps the value of the last iteration on the
+// stack, like compileForExpression. The variables are defined inside the
+// loop, so captured ones get a new cell in every iteration and closures
+// made by the body keep the item they were made for, as in Eval.
+func (c *Compiler) compileForInExpression(node *ast.ForInExpression) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	variables := 1
+	if node.Key != nil {
+		variables = 2
+	}
+	c.emit(code.OpIterator, variables)
+
+	table := NewLoopSymbolTable(c.symbolTable, capturedNames(node.Body))
+	c.symbolTable = table
+	defer func() {
+		c.symbolTable = table.Outer
+		if !table.isBlock() && table.numDefinitions > c.mainLocals {
+			c.mainLocals = table.numDefinitions
+		}
+	}()
+
+	iterator := c.define(iteratorName)
+	c.storeSymbol(iterator, code.OpSetGlobal)
+
+	c.emit(code.OpNull)
+	l := c.enterLoop(node.Label)
+
+	loopStart := len(c.currentInstructions())
+
+	c.loadSymbol(iterator)
+	nextPos := c.emit(code.OpNext, 9999)
+
+	c.storeSymbol(c.define(node.Value.Value), code.OpSetGlobal)
+	if node.Key != nil {
+		c.storeSymbol(c.define(node.Key.Value), code.OpSetGlobal)
+	}
+
+	c.emit(code.OpPop) // the value of the previous iteration
+
+	if err := c.compileBlock(node.Body.Statements); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	c.leaveLoop(l, loopStart)
+
+	return nil
+}
+
+// enterLoop starts compiling a loop, once the stack holds its value.
+func (c *Compiler) enterLoop(label *ast.Identifier) *loop {
+	l := &loop{}
+	if label != nil {
+		l.label = label.Value
+	}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+
+	c.emit(code.OpLoop)
+	return l
+}
+
+// leaveLoop ends the loop being compiled, whose next iteration starts at
+// next. Its break statements jump here.
+func (c *Compiler) leaveLoop(l *loop, next int) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.continues {
+		c.changeOperand(pos, next)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpEndLoop)
+}
+
+// compileJump compiles a break or a continue of the loop with label, or of
+// the innermost loop without one. add records the jump in that loop.
+func (c *Compiler) compileJump(label *ast.Identifier, keyword string, add func(l *loop, pos int)) error {
+	loops := c.scopes[c.scopeIndex].loops
+
+	for out := 0; out < len(loops); out++ {
+		l := loops[len(loops)-1-out]
+		if label != nil && l.label != label.Value {
+			continue
+		}
+
+		c.emit(code.OpUnwind, out)
+		add(l, c.emit(code.OpJump, 9999))
+		return nil
+	}
+
+	if label != nil {
+		return fmt.Errorf("%s is not in a loop labeled %s", keyword, label.Value)
+	}
+	return fmt.Errorf("%s is not in a loop", keyword)
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	c.symbolTable.captured = capturedNames(node.Body)
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	for _, p := range node.Parameters {
+		if symbol, _ := c.symbolTable.Resolve(p.Value); symbol.Boxed {
+			c.emit(code.OpBoxLocal, symbol.Index)
+		}
+	}
+
+	if err := c.compileBlock(node.Body.Statements); err != nil {
+		return err
+	}
+
+	c.emit(code.OpReturnValue)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		if err := c.loadCell(s); err != nil {
+			return err
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Parameters:    node.Parameters,
+		Body:          node.Body,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+// capturedNames returns every name used by function literals nested in
+// body. Locals with one of these names are boxed so closures share them.
+func capturedNames(body *ast.BlockStatement) map[string]bool {
+	names := map[string]bool{}
+
+	ast.Walk(body, func(node ast.Node) bool {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+
+		ast.Walk(fn.Body, func(inner ast.Node) bool {
+			switch inner := inner.(type) {
+			case *ast.Identifier:
+				names[inner.Value] = true
+			case *ast.PostfixExpression:
+				names[inner.TokenLiteral()] = true
+			}
+			return true
+		})
+
+		return false
+	})
+
+	return names
+}
+
+// resolve looks name up, reserving a global slot for names that are not
+// defined yet. Like Eval, using a name that is never bound is a runtime
+// error, which lets functions refer to globals defined after them.
+func (c *Compiler) resolve(name string) Symbol {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		symbol = c.symbolTable.Global().Define(name)
+	}
+	return symbol
+}
+
+// define binds name in the current table, creating the cell of boxed
+// locals the first time they are defined.
+func (c *Compiler) define(name string) Symbol {
+	existing, ok := c.symbolTable.store[name]
+	symbol := c.symbolTable.Define(name)
+
+	if symbol.Boxed && (!ok || existing != symbol) {
+		c.emit(code.OpNewCell, symbol.Index)
+	}
+
+	return symbol
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Boxed {
+			c.emit(code.OpGetBoxed, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+// storeSymbol pops the top of the stack into s. Globals use globalOp, so
+// `let` (OpSetGlobal) can bind new names while `=` (OpAssignGlobal) cannot.
+func (c *Compiler) storeSymbol(s Symbol, globalOp code.Opcode) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(globalOp, s.Index)
+	case LocalScope:
+		if s.Boxed {
+			c.emit(code.OpSetBoxed, s.Index)
+		} else {
+			c.emit(code.OpSetLocal, s.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// loadCell pushes the cell of a captured variable, as seen from the scope
+// creating the closure.
+func (c *Compiler) loadCell(s Symbol) error {
+	switch {
+	case s.Scope == LocalScope && s.Boxed:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Scope == FreeScope:
+		c.emit(code.OpLoadFree, s.Index)
+	default:
+		return fmt.Errorf("cannot capture %s from %s scope", s.Name, s.Scope)
+	}
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	return c.addInstruction(ins)
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{instructions: code.Instructions{}}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+		NumLocals:    c.mainLocals,
+	}
+}
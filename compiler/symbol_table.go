@@ -0,0 +1,160 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Boxed bool // the local lives in a cell because a closure captures it
+}
+
+// SymbolTable maps names to storage slots. Functions get their own table,
+// `if` and `for` get a block table which shares the slots of the function
+// (or global scope) it belongs to, mirroring the enclosed environments the
+// evaluator creates for them.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	owner    *SymbolTable    // function or global table holding the slots
+	captured map[string]bool // names referenced by nested function literals
+	blocks   []*SymbolTable  // block tables allocating slots from this table
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol)}
+	s.owner = s
+	return s
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.owner = outer.owner
+	s.owner.blocks = append(s.owner.blocks, s)
+	return s
+}
+
+// NewLoopSymbolTable returns the table of a for-in loop, whose variables
+// are captured by closures with the value of one iteration. In a function
+// it is a block table; at the top level it holds local slots of its own, in
+// the frame of the main program, since closures capture locals only.
+func NewLoopSymbolTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	if outer.scope() != GlobalScope {
+		return NewBlockSymbolTable(outer)
+	}
+
+	s := NewEnclosedSymbolTable(outer)
+	s.captured = captured
+	return s
+}
+
+func (s *SymbolTable) isBlock() bool { return s.owner != s }
+
+func (s *SymbolTable) scope() SymbolScope {
+	if s.owner.Outer == nil {
+		return GlobalScope
+	}
+	return LocalScope
+}
+
+// Define binds name in this table. Defining a name twice in the same table
+// reuses its slot, like `let` does on an existing environment entry.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := s.scope()
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Scope: scope, Index: s.owner.numDefinitions}
+	if scope == LocalScope {
+		symbol.Boxed = s.owner.captured[name]
+	}
+
+	s.store[name] = symbol
+	s.owner.numDefinitions++
+	return symbol
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || s.isBlock() {
+		return symbol, ok
+	}
+
+	if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Global returns the outermost table, where unresolved names are reserved
+// as globals so they can still be defined later on.
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Names returns the name bound to every slot of this table.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefinitions)
+
+	var collect func(*SymbolTable)
+	collect = func(t *SymbolTable) {
+		for name, symbol := range t.store {
+			if symbol.Scope == GlobalScope && symbol.Index < len(names) {
+				names[symbol.Index] = name
+			}
+		}
+	}
+
+	collect(s)
+	for _, block := range s.blocks {
+		collect(block)
+	}
+
+	return names
+}
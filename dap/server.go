@@ -0,0 +1,511 @@
+package dap
+
+import (
+	"bufio"
+	"compiler-book/ast"
+	"compiler-book/debugger"
+	"compiler-book/evaluator"
+	"compiler-book/framing"
+	"compiler-book/object"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// THREAD_ID is the id of the only thread of a Slang program.
+const THREAD_ID = 1
+
+// Server is a debug adapter that runs a Slang program under the debugger,
+// so editors can debug it through the Debug Adapter Protocol.
+type Server struct {
+	reader *bufio.Reader
+
+	mu  sync.Mutex // the program sends events while requests are answered
+	out io.Writer
+	seq int
+
+	debugger *debugger.Debugger
+	program  *ast.Program
+	done     chan struct{} // closed once the program ends
+
+	state       sync.Mutex // guards the fields below
+	running     bool
+	terminating bool
+	stop        *debugger.Stop       // where the program is paused, if it is
+	references  []any                // what variablesReference n - 1 expands
+	resume      chan debugger.Action // tells the paused program how to go on
+}
+
+// NewServer creates a server that reads requests from in and writes
+// responses and events to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		reader: bufio.NewReader(in),
+		out:    out,
+		done:   make(chan struct{}),
+		resume: make(chan debugger.Action),
+	}
+}
+
+// Start serves the protocol on in and out, which are normally the standard
+// input and output.
+func Start(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Serve()
+}
+
+// Serve answers requests until the client disconnects or closes the input.
+// A program still running by then is ended.
+func (s *Server) Serve() error {
+	for {
+		content, err := framing.ReadMessage(s.reader)
+		if err != nil {
+			s.terminate()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var request Request
+		if err := json.Unmarshal(content, &request); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+
+		if request.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(&request)
+		s.respond(&request, body, err)
+
+		switch {
+		case err != nil:
+		case request.Command == "launch":
+			// the client sends the breakpoints and configurationDone now
+			s.sendEvent("initialized", nil)
+		case request.Command == "disconnect":
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(request *Request) (any, error) {
+	switch request.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := json.Unmarshal(request.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := json.Unmarshal(request.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args)
+	case "configurationDone":
+		return nil, s.run()
+	case "threads":
+		return map[string][]Thread{"threads": {{ID: THREAD_ID, Name: "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args ScopesArguments
+		if err := json.Unmarshal(request.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args)
+	case "variables":
+		var args VariablesArguments
+		if err := json.Unmarshal(request.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args)
+	case "evaluate":
+		var args EvaluateArguments
+		if err := json.Unmarshal(request.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return map[string]bool{"allThreadsContinued": true}, s.resumeWith(debugger.Continue)
+	case "next":
+		return nil, s.resumeWith(debugger.StepOver)
+	case "stepIn":
+		return nil, s.resumeWith(debugger.StepIn)
+	case "stepOut":
+		return nil, s.resumeWith(debugger.StepOut)
+	case "pause":
+		if s.debugger == nil {
+			return nil, errors.New("no program was launched")
+		}
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request %q", request.Command)
+	}
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	if s.debugger != nil {
+		return errors.New("a program was already launched")
+	}
+
+	filename, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	program, err := debugger.LoadProgram(filename)
+	if err != nil {
+		return err
+	}
+
+	// what the program prints is sent to the client as output events, its
+	// standard input is the protocol so it reads nothing
+	ev := evaluator.New()
+	ev.SetFilename(filename)
+	ev.SetStreams(evaluator.Streams{
+		Stdout: &output{server: s, category: "stdout"},
+		Stderr: &output{server: s, category: "stderr"},
+	})
+
+	s.program = program
+	s.debugger = debugger.New(ev, args.StopOnEntry, s.onStop)
+	return nil
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) (any, error) {
+	if s.debugger == nil {
+		return nil, errors.New("no program was launched")
+	}
+
+	s.debugger.ClearBreakpoints(args.Source.Path)
+
+	breakpoints := make([]Breakpoint, len(args.Breakpoints))
+	for i, breakpoint := range args.Breakpoints {
+		s.debugger.SetBreakpoint(args.Source.Path, breakpoint.Line)
+		breakpoints[i] = Breakpoint{Verified: true, Line: breakpoint.Line}
+	}
+
+	return map[string][]Breakpoint{"breakpoints": breakpoints}, nil
+}
+
+// run starts the program, which reports its end with the exited and
+// terminated events.
+func (s *Server) run() error {
+	if s.debugger == nil {
+		return errors.New("no program was launched")
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	if s.running {
+		return errors.New("the program is already running")
+	}
+	s.running = true
+
+	go func() {
+		defer close(s.done)
+
+		result, quit := s.debugger.Run(s.program, object.NewEnvironment())
+		if !quit {
+			exitCode := 0
+			if err, ok := result.(*object.Error); ok {
+				s.sendEvent("output", map[string]string{"category": "stderr", "output": describeError(err)})
+				exitCode = 1
+			}
+			s.sendEvent("exited", map[string]int{"exitCode": exitCode})
+		}
+
+		s.sendEvent("terminated", nil)
+	}()
+
+	return nil
+}
+
+// terminate ends the program and waits for it to stop.
+func (s *Server) terminate() {
+	s.state.Lock()
+	if !s.running || s.terminating {
+		s.state.Unlock()
+		return
+	}
+	s.terminating = true
+	paused := s.stop != nil
+	s.state.Unlock()
+
+	if paused {
+		s.resumeWith(debugger.Quit)
+	} else {
+		// the next statement stops and onStop ends the program
+		s.debugger.Pause()
+	}
+
+	<-s.done
+}
+
+func (s *Server) onStop(stop *debugger.Stop) debugger.Action {
+	s.state.Lock()
+	if s.terminating {
+		s.state.Unlock()
+		return debugger.Quit
+	}
+	s.stop = stop
+	s.state.Unlock()
+
+	s.sendEvent("stopped", map[string]any{
+		"reason":            string(stop.Reason),
+		"threadId":          THREAD_ID,
+		"allThreadsStopped": true,
+	})
+
+	return <-s.resume
+}
+
+// resumeWith lets the paused program go on. The variable references of the
+// pause are no longer valid afterwards.
+func (s *Server) resumeWith(action debugger.Action) error {
+	s.state.Lock()
+	if s.stop == nil {
+		s.state.Unlock()
+		return errors.New("the program is not paused")
+	}
+	s.stop = nil
+	s.references = nil
+	s.state.Unlock()
+
+	s.resume <- action
+	return nil
+}
+
+// paused returns where the program is paused.
+func (s *Server) paused() (*debugger.Stop, error) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	if s.stop == nil {
+		return nil, errors.New("the program is not paused")
+	}
+
+	return s.stop, nil
+}
+
+// frame returns the frame with id, the innermost one for 0.
+func (s *Server) frame(id int) (debugger.Frame, error) {
+	stop, err := s.paused()
+	if err != nil {
+		return debugger.Frame{}, err
+	}
+
+	if id == 0 {
+		id = 1
+	}
+
+	if id < 1 || id > len(stop.Frames) {
+		return debugger.Frame{}, fmt.Errorf("no frame %d", id)
+	}
+
+	return stop.Frames[id-1], nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	stop, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]StackFrame, len(stop.Frames))
+	for i, frame := range stop.Frames {
+		frames[i] = StackFrame{
+			ID:     i + 1,
+			Name:   frame.Function,
+			Line:   frame.Line,
+			Column: frame.Column,
+		}
+
+		if frame.File != "" {
+			frames[i].Source = &Source{Name: filepath.Base(frame.File), Path: frame.File}
+		}
+	}
+
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// scopes lists every scope of a frame, from the innermost one.
+func (s *Server) scopes(args ScopesArguments) (any, error) {
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Locals"
+		if env.Outer() == nil {
+			name = "Globals"
+		}
+
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.reference(env)})
+	}
+
+	return map[string][]Scope{"scopes": scopes}, nil
+}
+
+// variables lists the variables of a scope or the elements of an array or
+// a hash.
+func (s *Server) variables(args VariablesArguments) (any, error) {
+	s.state.Lock()
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.references) {
+		s.state.Unlock()
+		return nil, fmt.Errorf("no variables for reference %d", args.VariablesReference)
+	}
+	container := s.references[args.VariablesReference-1]
+	s.state.Unlock()
+
+	variables := []Variable{}
+	switch container := container.(type) {
+	case *object.Environment:
+		for _, name := range container.Names() {
+			value, _ := container.Get(name)
+			variables = append(variables, s.variable(name, value))
+		}
+	case *object.Array:
+		for i, element := range container.Elements {
+			variables = append(variables, s.variable(fmt.Sprintf("[%d]", i), element))
+		}
+	case *object.Hash:
+		for _, pair := range container.Pairs() {
+			variables = append(variables, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+	}
+
+	return map[string][]Variable{"variables": variables}, nil
+}
+
+func (s *Server) variable(name string, value object.Object) Variable {
+	return Variable{
+		Name:               name,
+		Value:              debugger.Describe(value),
+		Type:               strings.ToLower(string(value.Type())),
+		VariablesReference: s.expand(value),
+	}
+}
+
+// expand returns a reference to the elements of arrays and hashes, or 0
+// for the values that have none.
+func (s *Server) expand(value object.Object) int {
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			return s.reference(value)
+		}
+	case *object.Hash:
+		if value.Len() > 0 {
+			return s.reference(value)
+		}
+	}
+
+	return 0
+}
+
+func (s *Server) reference(container any) int {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.references = append(s.references, container)
+	return len(s.references)
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (any, error) {
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.debugger.Evaluate(args.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		return nil, fmt.Errorf("%s: %s", err.Kind, err.Message)
+	}
+
+	body := map[string]any{"result": "", "variablesReference": 0}
+	if result != nil {
+		body["result"] = debugger.Describe(result)
+		body["type"] = strings.ToLower(string(result.Type()))
+		body["variablesReference"] = s.expand(result)
+	}
+
+	return body, nil
+}
+
+// output sends what is written to it as output events of a category.
+type output struct {
+	server   *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.server.sendEvent("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+func describeError(err *object.Error) string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%s: %s\n", err.Kind, err.Message)
+	for _, frame := range err.Trace {
+		fmt.Fprintf(&out, "  at %s (%s:%d:%d)\n", frame.Function,
+			filepath.Base(frame.File), frame.Line, frame.Column)
+	}
+
+	return out.String()
+}
+
+func (s *Server) respond(request *Request, body any, err error) {
+	response := &Response{
+		Message:    Message{Type: "response"},
+		RequestSeq: request.Seq,
+		Success:    err == nil,
+		Command:    request.Command,
+		Body:       body,
+	}
+
+	if err != nil {
+		response.ErrMessage = err.Error()
+		response.Body = nil
+	}
+
+	s.send(response, &response.Message)
+}
+
+func (s *Server) sendEvent(event string, body any) {
+	e := &Event{Message: Message{Type: "event"}, Event: event, Body: body}
+	s.send(e, &e.Message)
+}
+
+func (s *Server) send(message any, header *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	header.Seq = s.seq
+	framing.WriteMessage(s.out, message)
+}
@@ -0,0 +1,409 @@
+package dap
+
+import (
+	"bufio"
+	"compiler-book/framing"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let numbers = [1, 2];
+let total = 0;
+for (let i = 0; i < 2; i++) {
+  total = add(total, numbers[i]);
+}
+total`
+
+// client drives a server through the protocol, like an editor would.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	seq      int
+	messages chan map[string]any
+	served   chan error
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, in := io.Pipe()
+	out, serverOut := io.Pipe()
+
+	c := &client{t: t, in: in, messages: make(chan map[string]any, 100), served: make(chan error, 1)}
+
+	go func() {
+		c.served <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.messages)
+
+		reader := bufio.NewReader(out)
+		for {
+			content, err := framing.ReadMessage(reader)
+			if err != nil {
+				return
+			}
+
+			var message map[string]any
+			if err := json.Unmarshal(content, &message); err != nil {
+				t.Errorf("invalid message %s: %s", content, err)
+				return
+			}
+			c.messages <- message
+		}
+	}()
+
+	t.Cleanup(func() { in.Close() })
+	return c
+}
+
+// request sends a request and returns its response, failing the test if
+// it was not successful.
+func (c *client) request(command string, arguments any) map[string]any {
+	c.t.Helper()
+
+	response := c.send(command, arguments)
+	if response["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, response["message"])
+	}
+
+	body, _ := response["body"].(map[string]any)
+	return body
+}
+
+func (c *client) send(command string, arguments any) map[string]any {
+	c.t.Helper()
+
+	c.seq++
+	request := map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments}
+	if err := framing.WriteMessage(c.in, request); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return c.expect("response", command)
+}
+
+// expect waits for a message of type named name (a command or an event),
+// skipping the others.
+func (c *client) expect(typ, name string) map[string]any {
+	c.t.Helper()
+
+	key := "event"
+	if typ == "response" {
+		key = "command"
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case message, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("the server closed before the %s %s", typ, name)
+			}
+			if message["type"] == typ && message[key] == name {
+				return message
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for the %s %s", typ, name)
+		}
+	}
+}
+
+func (c *client) expectStop(reason string, line int) {
+	c.t.Helper()
+
+	event := c.expect("event", "stopped")
+	body := event["body"].(map[string]any)
+	if body["reason"] != reason {
+		c.t.Fatalf("wrong stop reason. want=%s, got=%v", reason, body["reason"])
+	}
+
+	frames := c.request("stackTrace", map[string]any{"threadId": THREAD_ID})["stackFrames"].([]any)
+	top := frames[0].(map[string]any)
+	if top["line"] != float64(line) {
+		c.t.Fatalf("stopped at the wrong line. want=%d, got=%v", line, top["line"])
+	}
+}
+
+// launch starts program with breakpoints on lines of its file.
+func (c *client) launch(program string, stopOnEntry bool, lines ...int) string {
+	c.t.Helper()
+
+	file := filepath.Join(c.t.TempDir(), "main.sl")
+	if err := os.WriteFile(file, []byte(program), 0o644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	capabilities := c.request("initialize", map[string]any{"adapterID": "slang"})
+	if capabilities["supportsConfigurationDoneRequest"] != true {
+		c.t.Fatalf("wrong capabilities. got=%v", capabilities)
+	}
+
+	c.request("launch", map[string]any{"program": file, "stopOnEntry": stopOnEntry})
+	c.expect("event", "initialized")
+
+	breakpoints := []map[string]int{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, map[string]int{"line": line})
+	}
+	c.request("setBreakpoints", map[string]any{"source": map[string]string{"path": file}, "breakpoints": breakpoints})
+
+	c.request("configurationDone", nil)
+	return file
+}
+
+func TestBreakpointsAndStackTrace(t *testing.T) {
+	c := newClient(t)
+	file := c.launch(program, false, 2)
+
+	c.expectStop("breakpoint", 2)
+
+	threads := c.request("threads", nil)["threads"].([]any)
+	if len(threads) != 1 {
+		t.Fatalf("wrong threads. got=%v", threads)
+	}
+
+	frames := c.request("stackTrace", map[string]any{"threadId": THREAD_ID})["stackFrames"].([]any)
+	expected := []struct {
+		name string
+		line float64
+	}{{"add", 2}, {"<main>", 8}}
+
+	if len(frames) != len(expected) {
+		t.Fatalf("wrong number of frames. got=%v", frames)
+	}
+
+	for i, tt := range expected {
+		frame := frames[i].(map[string]any)
+		if frame["name"] != tt.name || frame["line"] != tt.line {
+			t.Errorf("wrong frame %d. want=%s:%v, got=%v:%v", i, tt.name, tt.line, frame["name"], frame["line"])
+		}
+
+		source := frame["source"].(map[string]any)
+		if source["path"] != file {
+			t.Errorf("wrong source. got=%v", source["path"])
+		}
+	}
+
+	c.request("continue", map[string]any{"threadId": THREAD_ID})
+	c.expectStop("breakpoint", 2)
+
+	c.request("setBreakpoints", map[string]any{"source": map[string]string{"path": file}, "breakpoints": []any{}})
+	c.request("continue", map[string]any{"threadId": THREAD_ID})
+
+	exited := c.expect("event", "exited")
+	if code := exited["body"].(map[string]any)["exitCode"]; code != float64(0) {
+		t.Errorf("wrong exit code. got=%v", code)
+	}
+	c.expect("event", "terminated")
+
+	c.request("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("serve failed: %s", err)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	c := newClient(t)
+	c.launch(program, false, 3)
+
+	c.expectStop("breakpoint", 3)
+
+	scopes := c.request("scopes", map[string]any{"frameId": 1})["scopes"].([]any)
+	if len(scopes) != 2 {
+		t.Fatalf("wrong scopes. got=%v", scopes)
+	}
+
+	locals := variables(c, scopes[0])
+	if got := strings.Join(locals, ", "); got != "a = 0, b = 1, sum = 1" {
+		t.Errorf("wrong locals. got=%s", got)
+	}
+
+	globals := variables(c, scopes[1])
+	if got := strings.Join(globals, ", "); got != "add = fn add(a, b), numbers = [1, 2], total = 0" {
+		t.Errorf("wrong globals. got=%s", got)
+	}
+
+	// the elements of arrays are variables too
+	var numbers float64
+	for _, variable := range c.request("variables", map[string]any{"variablesReference": scopes[1].(map[string]any)["variablesReference"]})["variables"].([]any) {
+		if variable := variable.(map[string]any); variable["name"] == "numbers" {
+			numbers = variable["variablesReference"].(float64)
+		}
+	}
+
+	elements := c.request("variables", map[string]any{"variablesReference": numbers})["variables"].([]any)
+	if len(elements) != 2 || elements[1].(map[string]any)["name"] != "[1]" || elements[1].(map[string]any)["value"] != "2" {
+		t.Errorf("wrong elements. got=%v", elements)
+	}
+
+	// the frame of the caller has its own scopes
+	scopes = c.request("scopes", map[string]any{"frameId": 2})["scopes"].([]any)
+	if got := strings.Join(variables(c, scopes[0]), ", "); got != "i = 0" {
+		t.Errorf("wrong locals of the caller. got=%s", got)
+	}
+
+	c.request("disconnect", nil)
+}
+
+func variables(c *client, scope any) []string {
+	c.t.Helper()
+
+	reference := scope.(map[string]any)["variablesReference"]
+	result := []string{}
+	for _, variable := range c.request("variables", map[string]any{"variablesReference": reference})["variables"].([]any) {
+		variable := variable.(map[string]any)
+		result = append(result, variable["name"].(string)+" = "+variable["value"].(string))
+	}
+
+	return result
+}
+
+func TestStepping(t *testing.T) {
+	c := newClient(t)
+	c.launch(program, true)
+
+	c.expectStop("entry", 1)
+
+	steps := []struct {
+		command string
+		line    int
+	}{
+		{"next", 5},
+		{"next", 6},
+		{"next", 7},
+		{"next", 8},
+		{"stepIn", 2},
+		{"next", 3},
+		{"stepOut", 8},
+	}
+
+	for _, step := range steps {
+		c.request(step.command, map[string]any{"threadId": THREAD_ID})
+		c.expectStop("step", step.line)
+	}
+
+	c.request("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("serve failed: %s", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := newClient(t)
+	c.launch(program, false, 3)
+
+	c.expectStop("breakpoint", 3)
+
+	tests := []struct {
+		expression string
+		frameID    int
+		expected   string
+	}{
+		{"sum * 10", 1, "10"},
+		{"add(total, 5)", 1, "5"},
+		{"i", 2, "0"},
+		{"numbers", 0, "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		body := c.request("evaluate", map[string]any{"expression": tt.expression, "frameId": tt.frameID})
+		if body["result"] != tt.expected {
+			t.Errorf("wrong result for %s. want=%s, got=%v", tt.expression, tt.expected, body["result"])
+		}
+	}
+
+	response := c.send("evaluate", map[string]any{"expression": "missing", "frameId": 1})
+	if response["success"] != false || response["message"] != "NameError: identifier not found: missing" {
+		t.Errorf("wrong response for an error. got=%v", response)
+	}
+
+	c.request("disconnect", nil)
+}
+
+func TestPause(t *testing.T) {
+	c := newClient(t)
+	c.launch("for (let i = 0; i < 1000000000; i++) {\n  i;\n}", false)
+
+	c.request("pause", map[string]any{"threadId": THREAD_ID})
+
+	event := c.expect("event", "stopped")
+	if reason := event["body"].(map[string]any)["reason"]; reason != "pause" {
+		t.Fatalf("wrong stop reason. got=%v", reason)
+	}
+
+	c.request("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("serve failed: %s", err)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	c := newClient(t)
+	c.launch("let f = fn() {\n  1 + true\n};\nf();", false)
+
+	output := c.expect("event", "output")["body"].(map[string]any)
+	if output["category"] != "stderr" || !strings.HasPrefix(output["output"].(string), "TypeError: type mismatch: INTEGER + BOOLEAN\n  at f (main.sl:2:5)") {
+		t.Errorf("wrong output. got=%v", output)
+	}
+
+	exited := c.expect("event", "exited")
+	if code := exited["body"].(map[string]any)["exitCode"]; code != float64(1) {
+		t.Errorf("wrong exit code. got=%v", code)
+	}
+}
+
+func TestProgramOutput(t *testing.T) {
+	c := newClient(t)
+	c.launch(`print("hello", 1);`, false)
+
+	output := c.expect("event", "output")["body"].(map[string]any)
+	if output["category"] != "stdout" || output["output"] != "hello 1 \n" {
+		t.Errorf("wrong output. got=%v", output)
+	}
+
+	exited := c.expect("event", "exited")
+	if code := exited["body"].(map[string]any)["exitCode"]; code != float64(0) {
+		t.Errorf("wrong exit code. got=%v", code)
+	}
+}
+
+func TestFailedRequests(t *testing.T) {
+	c := newClient(t)
+
+	tests := []struct {
+		command   string
+		arguments any
+		message   string
+	}{
+		{"stackTrace", map[string]any{"threadId": THREAD_ID}, "the program is not paused"},
+		{"configurationDone", nil, "no program was launched"},
+		{"launch", map[string]any{"program": "missing.sl"}, "no such file or directory"},
+		{"readMemory", nil, `unsupported request "readMemory"`},
+	}
+
+	for _, tt := range tests {
+		response := c.send(tt.command, tt.arguments)
+		if response["success"] != false || !strings.Contains(response["message"].(string), tt.message) {
+			t.Errorf("wrong response for %s. got=%v", tt.command, response)
+		}
+	}
+
+	// the server keeps going after failures and ends with the input
+	c.request("initialize", nil)
+	c.in.Close()
+	if err := <-c.served; err != nil {
+		t.Errorf("serve failed: %s", err)
+	}
+}
@@ -0,0 +1,268 @@
+package debugger
+
+import (
+	"bufio"
+	"compiler-book/ast"
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const PROMPT = "(sdb) "
+
+const help = `commands:
+  break [file:]line   set a breakpoint (b)
+  clear [file:]line   remove a breakpoint
+  continue            run until the next breakpoint (c)
+  step                step into function calls (s)
+  next                step over function calls (n)
+  out                 step out of the current function (o)
+  stack               show the call stack (bt)
+  frame n             select the nth frame of the stack (f)
+  vars                show the variables of the selected frame (v)
+  print expression    evaluate an expression in the selected frame (p)
+  list                show the source around the selected frame (l)
+  quit                end the program (q)
+`
+
+// cli is a command line interface for the debugger.
+type cli struct {
+	debugger *Debugger
+	scanner  *bufio.Scanner
+	out      io.Writer
+	sources  map[string][]string
+}
+
+// Start runs filename under the debugger, reading commands from in. The
+// program stops before its first statement, so breakpoints can be set.
+func Start(filename string, in io.Reader, out io.Writer) {
+	program, err := LoadProgram(filename)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+
+	c := &cli{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+		sources: make(map[string][]string),
+	}
+
+	ev := evaluator.New()
+	ev.SetFilename(filename)
+	c.debugger = New(ev, true, c.onStop)
+
+	result, quit := c.debugger.Run(program, object.NewEnvironment())
+	if quit {
+		return
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(out, "program failed: %s: %s\n", err.Kind, err.Message)
+		for _, frame := range err.Trace {
+			fmt.Fprintf(out, "  at %s (%s:%d:%d)\n", frame.Function,
+				filepath.Base(frame.File), frame.Line, frame.Column)
+		}
+		return
+	}
+
+	fmt.Fprintln(out, "program exited")
+}
+
+// LoadProgram parses filename and expands its macros.
+func LoadProgram(filename string) (*ast.Program, error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()[0]
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, _ := evaluator.ExpandMacros(program, macroEnv).(*ast.Program)
+
+	return expanded, nil
+}
+
+func (c *cli) onStop(stop *Stop) Action {
+	selected := 0
+	c.printLocation(stop.Frames[selected], stop.Reason)
+
+	for {
+		fmt.Fprint(c.out, PROMPT)
+		if !c.scanner.Scan() {
+			fmt.Fprintln(c.out)
+			return Continue
+		}
+
+		command, arg, _ := strings.Cut(strings.TrimSpace(c.scanner.Text()), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch command {
+		case "c", "continue":
+			return Continue
+		case "s", "step":
+			return StepIn
+		case "n", "next":
+			return StepOver
+		case "o", "out":
+			return StepOut
+		case "q", "quit":
+			return Quit
+		case "b", "break", "clear":
+			file, line, err := parseLocation(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+				continue
+			}
+
+			if command == "clear" {
+				c.debugger.ClearBreakpoint(file, line)
+				fmt.Fprintf(c.out, "breakpoint cleared at line %d\n", line)
+			} else {
+				c.debugger.SetBreakpoint(file, line)
+				fmt.Fprintf(c.out, "breakpoint set at line %d\n", line)
+			}
+		case "bt", "stack":
+			for i, frame := range stop.Frames {
+				marker := " "
+				if i == selected {
+					marker = "*"
+				}
+				fmt.Fprintf(c.out, "%s %d %s at %s:%d\n", marker, i, frame.Function,
+					filepath.Base(frame.File), frame.Line)
+			}
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(stop.Frames) {
+				fmt.Fprintf(c.out, "no frame %q\n", arg)
+				continue
+			}
+
+			selected = n
+			c.printLocation(stop.Frames[selected], "")
+		case "v", "vars":
+			c.printVariables(stop.Frames[selected].Env)
+		case "p", "print":
+			result, err := c.debugger.Evaluate(arg, stop.Frames[selected])
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+				continue
+			}
+
+			if result != nil {
+				fmt.Fprintln(c.out, result.Inspect())
+			}
+		case "l", "list":
+			c.printSource(stop.Frames[selected], 5)
+		case "h", "help":
+			fmt.Fprint(c.out, help)
+		case "":
+		default:
+			fmt.Fprintf(c.out, "unknown command %q, try help\n", command)
+		}
+	}
+}
+
+// parseLocation parses a breakpoint location, a line or file:line.
+func parseLocation(location string) (string, int, error) {
+	file := ""
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		file, location = location[:i], location[i+1:]
+	}
+
+	line, err := strconv.Atoi(location)
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("invalid line %q", location)
+	}
+
+	return file, line, nil
+}
+
+func (c *cli) printLocation(frame Frame, reason Reason) {
+	if reason != "" {
+		fmt.Fprintf(c.out, "stopped (%s) ", reason)
+	}
+
+	fmt.Fprintf(c.out, "in %s at %s:%d\n", frame.Function, filepath.Base(frame.File), frame.Line)
+	c.printSource(frame, 0)
+}
+
+// printSource prints the line frame is paused on, with context lines
+// around it.
+func (c *cli) printSource(frame Frame, context int) {
+	lines := c.source(frame.File)
+
+	for n := frame.Line - context; n <= frame.Line+context; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+
+		marker := " "
+		if n == frame.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s %4d  %s\n", marker, n, lines[n-1])
+	}
+}
+
+func (c *cli) source(file string) []string {
+	lines, ok := c.sources[file]
+	if !ok && file != "" {
+		if source, err := os.ReadFile(file); err == nil {
+			lines = strings.Split(string(source), "\n")
+		}
+		c.sources[file] = lines
+	}
+
+	return lines
+}
+
+// printVariables prints every scope of env, from the innermost one.
+func (c *cli) printVariables(env *object.Environment) {
+	for scope := env; scope != nil; scope = scope.Outer() {
+		if scope.Outer() == nil {
+			fmt.Fprintln(c.out, "globals:")
+		} else {
+			fmt.Fprintln(c.out, "locals:")
+		}
+
+		for _, name := range scope.Names() {
+			value, _ := scope.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, Describe(value))
+		}
+	}
+}
+
+// Describe shortens the inspection of values that span several lines,
+// like functions, to fit in a list of variables.
+func Describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Function:
+		params := make([]string, len(obj.Parameters))
+		for i, param := range obj.Parameters {
+			params[i] = param.Value
+		}
+		name := ""
+		if obj.Name != "" {
+			name = " " + obj.Name
+		}
+		return fmt.Sprintf("fn%s(%s)", name, strings.Join(params, ", "))
+	case *object.Macro:
+		return "magic"
+	default:
+		return obj.Inspect()
+	}
+}
@@ -0,0 +1,264 @@
+package debugger
+
+import (
+	"compiler-book/ast"
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"errors"
+	"path/filepath"
+	"sync"
+)
+
+// Action tells a paused program how to go on.
+type Action int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue Action = iota
+	// StepIn stops at the next statement, entering function calls.
+	StepIn
+	// StepOver stops at the next statement of the current function.
+	StepOver
+	// StepOut stops at the next statement after the current function returns.
+	StepOut
+	// Quit ends the program.
+	Quit
+)
+
+// Reason tells why the program stopped.
+type Reason string
+
+const (
+	ENTRY      Reason = "entry"
+	BREAKPOINT Reason = "breakpoint"
+	STEP       Reason = "step"
+	PAUSE      Reason = "pause"
+)
+
+// Frame is a function call of a paused program.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+	Env      *object.Environment // the scope of the statement about to run
+}
+
+// Stop describes where the program paused.
+type Stop struct {
+	Reason Reason
+	Frames []Frame // innermost first
+}
+
+// Debugger is an evaluator.Hook that pauses the program on breakpoints and
+// while stepping. Every pause is reported to the onStop function, which
+// returns how to go on once the user decides.
+type Debugger struct {
+	evaluator *evaluator.Evaluator
+	onStop    func(stop *Stop) Action
+
+	mu          sync.Mutex // breakpoints are set while the program runs
+	breakpoints map[string]map[int]bool
+	pausing     bool // stop at the next statement
+
+	stack      []Frame // innermost last
+	entry      bool    // stop at the first statement
+	action     Action
+	stepDepth  int  // the stack depth the last step started at
+	evaluating bool // evaluating an expression for the user
+}
+
+// quitSignal unwinds the evaluator when the user quits.
+type quitSignal struct{}
+
+// New attaches a debugger to ev. The program starts paused when stopOnEntry
+// is set.
+func New(ev *evaluator.Evaluator, stopOnEntry bool, onStop func(stop *Stop) Action) *Debugger {
+	d := &Debugger{
+		evaluator:   ev,
+		onStop:      onStop,
+		breakpoints: make(map[string]map[int]bool),
+		stack:       []Frame{{Function: "<main>", File: ev.Filename()}},
+		entry:       stopOnEntry,
+		action:      Continue,
+	}
+
+	ev.SetHook(d)
+	return d
+}
+
+// Run evaluates program in env under the debugger. It reports whether the
+// program was ended with Quit, in which case there is no result.
+func (d *Debugger) Run(program ast.Node, env *object.Environment) (result object.Object, quit bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(quitSignal); !ok {
+				panic(r)
+			}
+			result, quit = nil, true
+		}
+	}()
+
+	return d.evaluator.Eval(program, env), false
+}
+
+// SetBreakpoint sets a breakpoint on a line of file. An empty file is the
+// file being debugged.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	file = d.resolve(file)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.breakpoints[file] == nil {
+		d.breakpoints[file] = make(map[int]bool)
+	}
+	d.breakpoints[file][line] = true
+}
+
+// ClearBreakpoint removes the breakpoint on a line of file.
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	file = d.resolve(file)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints[file], line)
+}
+
+// ClearBreakpoints removes all the breakpoints of file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	file = d.resolve(file)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.breakpoints, file)
+}
+
+// Pause stops the running program at its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pausing = true
+}
+
+func (d *Debugger) takePause() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pausing := d.pausing
+	d.pausing = false
+	return pausing
+}
+
+func (d *Debugger) hasBreakpoint(file string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.breakpoints[file][line]
+}
+
+func (d *Debugger) resolve(file string) string {
+	if file == "" {
+		return d.stack[0].File
+	}
+
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+
+	return file
+}
+
+// Evaluate evaluates input in the scope frame is paused in. Definitions
+// made by input stay in that scope.
+func (d *Debugger) Evaluate(input string, frame Frame) (object.Object, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()[0]
+	}
+
+	if frame.Env == nil {
+		return nil, errors.New("the program is not paused in this frame")
+	}
+
+	// the evaluation must not stop on breakpoints of its own
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	return d.evaluator.Eval(program, frame.Env), nil
+}
+
+// Enter implements evaluator.Hook.
+func (d *Debugger) Enter(function, file string) {
+	if d.evaluating {
+		return
+	}
+
+	d.stack = append(d.stack, Frame{Function: function, File: file})
+}
+
+// Leave implements evaluator.Hook.
+func (d *Debugger) Leave() {
+	if d.evaluating {
+		return
+	}
+
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+// Statement implements evaluator.Hook.
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+
+	top := &d.stack[len(d.stack)-1]
+	pos := stmt.Pos()
+
+	// the statements following the first one on a line don't stop again
+	laterOnLine := top.Line == pos.Line && top.Column < pos.Column
+
+	top.Line, top.Column, top.Env = pos.Line, pos.Column, env
+
+	var reason Reason
+	switch {
+	case d.entry:
+		d.entry = false
+		reason = ENTRY
+	case d.takePause():
+		reason = PAUSE
+	case d.action == StepIn:
+		reason = STEP
+	case d.action == StepOver && len(d.stack) <= d.stepDepth:
+		reason = STEP
+	case d.action == StepOut && len(d.stack) < d.stepDepth:
+		reason = STEP
+	case !laterOnLine && d.hasBreakpoint(top.File, pos.Line):
+		reason = BREAKPOINT
+	default:
+		return
+	}
+
+	d.pause(reason)
+}
+
+func (d *Debugger) pause(reason Reason) {
+	frames := make([]Frame, len(d.stack))
+	for i, frame := range d.stack {
+		frames[len(frames)-1-i] = frame
+	}
+
+	action := d.onStop(&Stop{Reason: reason, Frames: frames})
+	if action == Quit {
+		panic(quitSignal{})
+	}
+
+	d.action = action
+	d.stepDepth = len(d.stack)
+}
@@ -0,0 +1,279 @@
+package debugger
+
+import (
+	"bytes"
+	"compiler-book/ast"
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let total = 0;
+for (let i = 0; i < 3; i++) {
+  total = add(total, i);
+}
+total`
+
+// location is where the program stopped, as function:line.
+type location string
+
+// run debugs input, answering every stop with the next of actions (and
+// Continue once they run out) and returning where it stopped.
+func run(t *testing.T, input string, stopOnEntry bool, breakpoints []int, actions ...Action) ([]location, object.Object) {
+	t.Helper()
+
+	var stops []location
+	onStop := func(stop *Stop) Action {
+		frame := stop.Frames[0]
+		stops = append(stops, location(frame.Function+":"+itoa(frame.Line)))
+
+		if len(actions) == 0 {
+			return Continue
+		}
+
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	}
+
+	d := New(evaluator.New(), stopOnEntry, onStop)
+	for _, line := range breakpoints {
+		d.SetBreakpoint("", line)
+	}
+
+	result, _ := d.Run(parse(t, input), object.NewEnvironment())
+	return stops, result
+}
+
+func TestBreakpoints(t *testing.T) {
+	stops, result := run(t, program, false, []int{2, 9})
+
+	expected := []location{"add:2", "add:2", "add:2", "<main>:9"}
+	testLocations(t, expected, stops)
+
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 3 {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestBreakpointOnceForEveryLine(t *testing.T) {
+	input := "let a = 1; let b = 2;\nlet c = 3;"
+	stops, _ := run(t, input, false, []int{1, 2})
+
+	testLocations(t, []location{"<main>:1", "<main>:2"}, stops)
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		actions  []Action
+		expected []location
+	}{
+		{
+			[]Action{StepOver, StepOver, StepOver, StepOver, Continue},
+			[]location{"<main>:1", "<main>:5", "<main>:6", "<main>:7", "<main>:7"},
+		},
+		{
+			[]Action{StepOver, StepOver, StepOver, StepIn, StepIn, StepIn, StepIn, Continue},
+			[]location{"<main>:1", "<main>:5", "<main>:6", "<main>:7", "add:2", "add:3", "<main>:7", "add:2"},
+		},
+		{
+			[]Action{StepOver, StepOver, StepOver, StepIn, StepOut, Continue},
+			[]location{"<main>:1", "<main>:5", "<main>:6", "<main>:7", "add:2", "<main>:7"},
+		},
+	}
+
+	for _, tt := range tests {
+		stops, _ := run(t, program, true, nil, tt.actions...)
+		testLocations(t, tt.expected, stops)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var results []string
+
+	var d *Debugger
+	d = New(evaluator.New(), false, func(stop *Stop) Action {
+		for _, input := range []string{"a + b", "let b = 100", "sum", "total", "add(1, 1)"} {
+			result, err := d.Evaluate(input, stop.Frames[0])
+			if err != nil {
+				t.Fatalf("%s: %s", input, err)
+			}
+			results = append(results, result.Inspect())
+		}
+
+		// the breakpoint inside add must not stop the evaluation
+		return Continue
+	})
+	d.SetBreakpoint("", 2)
+
+	result, _ := d.Run(parse(t, program), object.NewEnvironment())
+
+	expected := []string{
+		"0", "null", "ERROR: identifier not found: sum", "0", "2",
+		"101", "null", "ERROR: identifier not found: sum", "100", "2",
+		"202", "null", "ERROR: identifier not found: sum", "200", "2",
+	}
+
+	if strings.Join(results, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong results.\nwant=%v\ngot =%v", expected, results)
+	}
+
+	// let b = 100 changed the parameter before the addition
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 300 {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestEvaluateParseError(t *testing.T) {
+	d := New(evaluator.New(), true, func(stop *Stop) Action { return Continue })
+
+	if _, err := d.Evaluate("let = 1", Frame{Env: object.NewEnvironment()}); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
+
+func TestVariables(t *testing.T) {
+	var frames []Frame
+	d := New(evaluator.New(), false, func(stop *Stop) Action {
+		frames = stop.Frames
+		return Quit
+	})
+	d.SetBreakpoint("", 3)
+
+	_, quit := d.Run(parse(t, program), object.NewEnvironment())
+	if !quit {
+		t.Fatalf("program was not ended")
+	}
+
+	if len(frames) != 2 || frames[0].Function != "add" || frames[1].Function != "<main>" {
+		t.Fatalf("wrong frames. got=%+v", frames)
+	}
+
+	locals := frames[0].Env
+	if names := strings.Join(locals.Names(), ","); names != "a,b,sum" {
+		t.Errorf("wrong names in the function scope. got=%s", names)
+	}
+
+	if names := strings.Join(locals.Outer().Names(), ","); names != "add,total" {
+		t.Errorf("wrong names in the global scope. got=%s", names)
+	}
+
+	if names := strings.Join(frames[1].Env.Names(), ","); names != "i" {
+		t.Errorf("wrong names in the loop scope. got=%s", names)
+	}
+
+	sum, _ := locals.Get("sum")
+	if sum.Inspect() != "0" {
+		t.Errorf("wrong value of sum. got=%s", sum.Inspect())
+	}
+}
+
+func TestQuit(t *testing.T) {
+	env := object.NewEnvironment()
+	d := New(evaluator.New(), true, func(stop *Stop) Action { return Quit })
+
+	result, quit := d.Run(parse(t, "let a = 1;"), env)
+	if !quit || result != nil {
+		t.Errorf("expected the program to quit. got=%v, %v", result, quit)
+	}
+
+	if _, ok := env.Get("a"); ok {
+		t.Errorf("the program ran after quitting")
+	}
+}
+
+func TestBreakpointsInImportedFiles(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.sl")
+	if err := os.WriteFile(lib, []byte("let f = fn() {\n  1\n};"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stops []location
+	ev := evaluator.New()
+	ev.SetFilename(filepath.Join(dir, "main.sl"))
+	d := New(ev, false, func(stop *Stop) Action {
+		frame := stop.Frames[0]
+		stops = append(stops, location(filepath.Base(frame.File)+":"+itoa(frame.Line)))
+		return Continue
+	})
+	d.SetBreakpoint(lib, 2)
+	d.SetBreakpoint("", 2)
+
+	d.Run(parse(t, "import \"lib.sl\";\nlib.f()"), object.NewEnvironment())
+
+	testLocations(t, []location{"main.sl:2", "lib.sl:2"}, stops)
+}
+
+func TestCommandLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.sl")
+	if err := os.WriteFile(file, []byte(program), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := strings.Join([]string{
+		"break 3", "continue", "stack", "vars", "print a + b", "clear 3", "out", "print total", "continue",
+	}, "\n")
+
+	var out bytes.Buffer
+	Start(file, strings.NewReader(commands), &out)
+
+	for _, expected := range []string{
+		"stopped (entry) in <main> at main.sl:1",
+		"breakpoint set at line 3",
+		"stopped (breakpoint) in add at main.sl:3",
+		">    3    sum",
+		"* 0 add at main.sl:3\n  1 <main> at main.sl:7",
+		"locals:\n  a = 0\n  b = 0\n  sum = 0\nglobals:",
+		"  add = fn add(a, b)",
+		"(sdb) 0\n",
+		"stopped (step) in <main> at main.sl:7",
+		"program exited",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q. got=\n%s", expected, out.String())
+		}
+	}
+}
+
+func testLocations(t *testing.T, expected, actual []location) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Errorf("wrong stops. want=%v, got=%v", expected, actual)
+		return
+	}
+
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("wrong stops. want=%v, got=%v", expected, actual)
+			return
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
@@ -1,193 +1,121 @@
 package evaluator
 
 import (
-	"bytes"
+	"bufio"
 	"compiler-book/object"
-	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: btLen,
-	},
-	"print": {
-		Fn: btPrint,
-	},
-	"printf": {
-		Fn: btPrintf,
-	},
-	"push": {
-		Fn: btPush,
-	},
-	"pop": {
-		Fn: btPop,
-	},
-	"first": {
-		Fn: btFirst,
-	},
-	"rest": {
-		Fn: btRest,
-	},
+// Builtins are the values programs find by name without defining them:
+// builtin functions, and namespaces like math. Every Evaluator has its own,
+// so hosts can give the programs of different evaluators different
+// builtins.
+type Builtins map[string]object.Object
+
+// Streams are the standard streams of the programs an evaluator runs.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
-func btLen(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1",
-			len(args))
+// standardBuiltins returns the builtins programs have unless their host
+// says otherwise. The ones that print use the streams of e at the time
+// they are called.
+func (e *Evaluator) standardBuiltins() Builtins {
+	builtins := Builtins{
+		"len": object.GetBuiltinByName("len"),
+		"print": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return object.Print(e.streams.Stdout)(args...)
+		}},
+		"printf": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return object.Printf(e.streams.Stdout)(args...)
+		}},
+		"eprint": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return object.Print(e.streams.Stderr)(args...)
+		}},
+		"push":  object.GetBuiltinByName("push"),
+		"pop":   object.GetBuiltinByName("pop"),
+		"first": object.GetBuiltinByName("first"),
+		"rest":  object.GetBuiltinByName("rest"),
+	}
+
+	sets := []Builtins{e.stringBuiltins(), conversionBuiltins, e.collectionBuiltins(), e.systemBuiltins()}
+	for _, set := range sets {
+		for name, builtin := range set {
+			builtins[name] = builtin
+		}
 	}
+	builtins["math"] = newMathModule()
+	builtins["json"] = newJSONModule()
 
-	switch arg := args[0].(type) {
-	case *object.String:
-		return &object.Integer{Value: int64(len(arg.Value))}
-	case *object.Array:
-		return &object.Integer{Value: int64(len(arg.Elements))}
-	default:
-		return newError("argument to `len` not supported, got %s",
-			args[0].Type())
-	}
+	return builtins
 }
 
-func btPrint(args ...object.Object) object.Object {
-	var out bytes.Buffer
-
-	for _, arg := range args {
-		out.WriteString(arg.Inspect())
-		out.WriteString(" ")
-	}
-
-	fmt.Println(out.String())
-
-	return NULL
+// BuiltinNames returns the names of the standard builtin functions, sorted.
+func BuiltinNames() []string {
+	return New().builtins.names()
 }
 
-func btPrintf(args ...object.Object) object.Object {
-	var opts []any
-
-	if len(args) < 1 {
-		return newError("wrong number of arguments. got=%d, want=1",
-			len(args))
-	}
-
-	format := args[0]
-
-	if format.Type() != object.STRING {
-		return newError("argument to `printf` not supported, got %s",
-			format.Type())
+func (b Builtins) names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	formatValue := format.(*object.String).Value
-
-	for _, arg := range args[1:] {
-		switch arg.Type() {
-		case object.INTEGER:
-			opts = append(opts, arg.(*object.Integer).Value)
-		case object.FLOAT:
-			opts = append(opts, arg.(*object.Float).Value)
-		case object.STRING:
-			opts = append(opts, arg.(*object.String).Value)
-		case object.RUNE:
-			opts = append(opts, arg.(*object.Rune).Value)
-		case object.BOOLEAN:
-			opts = append(opts, arg.(*object.Boolean).Value)
-		default:
-			return newError("argument to `printf` not supported, got %s",
-				arg.Type())
-		}
-	}
-
-	unescape := strings.Replace(formatValue, "\\n", "\n", -1) // FIXME: improve this
-	fmt.Printf(unescape+"\n", opts...)
-
-	return NULL
+	return names
 }
 
-func btPush(args ...object.Object) object.Object {
-	if len(args) != 2 {
-		return newError("wrong number of arguments. got=%d, want=2",
-			len(args))
+// Builtins returns a copy of the builtins of e.
+func (e *Evaluator) Builtins() Builtins {
+	builtins := make(Builtins, len(e.builtins))
+	for name, builtin := range e.builtins {
+		builtins[name] = builtin
 	}
 
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `push` not supported, got %s",
-			args[0].Type())
-	}
-
-	if args[1] == args[0] {
-		return newError("argument to `push` cannot be the same array")
-	}
-
-	array := args[0].(*object.Array)
-	array.Elements = append(array.Elements, args[1])
-
-	return NULL
+	return builtins
 }
 
-func btPop(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1",
-			len(args))
+// SetBuiltins replaces the builtins of e with a copy of builtins.
+func (e *Evaluator) SetBuiltins(builtins Builtins) {
+	e.builtins = make(Builtins, len(builtins))
+	for name, builtin := range builtins {
+		e.builtins[name] = builtin
 	}
+}
 
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `pop` not supported, got %s",
-			args[0].Type())
-	}
-
-	array := args[0].(*object.Array)
-	length := len(array.Elements)
-
-	if length == 0 {
-		return NULL
-	}
-
-	last := array.Elements[length-1]
-	array.Elements = array.Elements[:length-1]
-
-	return last
+// DefineBuiltin adds builtin to the builtins of e as name, replacing the
+// one already named so.
+func (e *Evaluator) DefineBuiltin(name string, builtin object.Object) {
+	e.builtins[name] = builtin
 }
 
-func btFirst(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1",
-			len(args))
+// SetStreams sets the standard streams of the programs e runs. A nil Stdin
+// is empty, a nil Stdout or Stderr discards what is written to it.
+func (e *Evaluator) SetStreams(streams Streams) {
+	if streams.Stdin == nil {
+		streams.Stdin = strings.NewReader("")
 	}
-
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `first` not supported, got %s",
-			args[0].Type())
+	if streams.Stdout == nil {
+		streams.Stdout = io.Discard
 	}
-
-	array := args[0].(*object.Array)
-	length := len(array.Elements)
-
-	if length == 0 {
-		return NULL
+	if streams.Stderr == nil {
+		streams.Stderr = io.Discard
 	}
 
-	return array.Elements[0]
+	e.streams = streams
+	e.stdin = bufio.NewReader(streams.Stdin)
 }
 
-func btRest(args ...object.Object) object.Object {
-	if len(args) != 1 {
-		return newError("wrong number of arguments. got=%d, want=1",
-			len(args))
-	}
-
-	if args[0].Type() != object.ARRAY {
-		return newError("argument to `rest` not supported, got %s",
-			args[0].Type())
-	}
-
-	array := args[0].(*object.Array)
-	length := len(array.Elements)
-
-	if length == 0 {
-		return NULL
-	}
-
-	newElements := make([]object.Object, length-1)
-	copy(newElements, array.Elements[1:length])
+// Streams returns the standard streams of the programs e runs.
+func (e *Evaluator) Streams() Streams {
+	return e.streams
+}
 
-	return &object.Array{Elements: newElements}
+func defaultStreams() Streams {
+	return Streams{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
 }
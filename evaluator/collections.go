@@ -0,0 +1,515 @@
+package evaluator
+
+import (
+	"compiler-book/object"
+	"math"
+	"sort"
+	"strings"
+)
+
+// collectionBuiltins work on arrays and hashes. The ones taking a function
+// call it through e, the way a call expression of the program does, so
+// its errors are traced and the limits of e apply to it.
+func (e *Evaluator) collectionBuiltins() Builtins {
+	return Builtins{
+		"map":       &object.Builtin{Fn: e.mapArray},
+		"filter":    &object.Builtin{Fn: e.filter},
+		"reduce":    &object.Builtin{Fn: e.reduce},
+		"sort":      &object.Builtin{Fn: e.sort},
+		"range":     &object.Builtin{Fn: e.rangeArray},
+		"reverse":   &object.Builtin{Fn: reverse},
+		"zip":       &object.Builtin{Fn: zip},
+		"enumerate": &object.Builtin{Fn: enumerate},
+		"slice":     &object.Builtin{Fn: sliceBuiltin},
+		"concat":    &object.Builtin{Fn: e.concat},
+		"indexOf":   &object.Builtin{Fn: indexOf},
+		"contains":  &object.Builtin{Fn: contains},
+		"keys":      &object.Builtin{Fn: hashElements("keys", func(pair object.HashPair) object.Object { return pair.Key })},
+		"values":    &object.Builtin{Fn: hashElements("values", func(pair object.HashPair) object.Object { return pair.Value })},
+		"items": &object.Builtin{Fn: hashElements("items", func(pair object.HashPair) object.Object {
+			return &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+		})},
+		"has":    &object.Builtin{Fn: has},
+		"delete": &object.Builtin{Fn: deleteKey},
+		"merge":  &object.Builtin{Fn: merge},
+	}
+}
+
+// call calls fn, a function or a builtin the program gave to a builtin.
+func (e *Evaluator) call(fn object.Object, args ...object.Object) object.Object {
+	result := e.applyFunction(fn, args)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// mapArray returns the results of calling a function with every element of
+// an array.
+func (e *Evaluator) mapArray(args ...object.Object) object.Object {
+	if err := checkArguments("map", args, object.ARRAY, object.FUNCTION); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	mapped := make([]object.Object, len(elements))
+	for i, element := range elements {
+		result := e.call(args[1], element)
+		if isError(result) {
+			return result
+		}
+		mapped[i] = result
+	}
+
+	return &object.Array{Elements: mapped}
+}
+
+// filter returns the elements of an array for which a function returns a
+// truthy value.
+func (e *Evaluator) filter(args ...object.Object) object.Object {
+	if err := checkArguments("filter", args, object.ARRAY, object.FUNCTION); err != nil {
+		return err
+	}
+
+	kept := []object.Object{}
+	for _, element := range args[0].(*object.Array).Elements {
+		result := e.call(args[1], element)
+		if isError(result) {
+			return result
+		}
+
+		if isTruthy(result) {
+			kept = append(kept, element)
+		}
+	}
+
+	return &object.Array{Elements: kept}
+}
+
+// reduce folds the elements of an array into a value, starting with an
+// initial one: reduce([1, 2], 0, f) is f(f(0, 1), 2).
+func (e *Evaluator) reduce(args ...object.Object) object.Object {
+	if err := checkArguments("reduce", args, object.ARRAY, anything, object.FUNCTION); err != nil {
+		return err
+	}
+
+	result := args[1]
+	for _, element := range args[0].(*object.Array).Elements {
+		result = e.call(args[2], result, element)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// sort returns the elements of an array sorted, in a stable way. Without a
+// function telling whether an element goes before another, the elements
+// must be all integers, floats, strings or runes.
+func (e *Evaluator) sort(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	want := []object.ObjectType{object.ARRAY, object.FUNCTION}
+	if err := checkArguments("sort", args, want[:len(args)]...); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	sorted := make([]object.Object, len(elements))
+	copy(sorted, elements)
+
+	// the first error stops the comparisons
+	var failed *object.Error
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+
+		if len(args) == 1 {
+			before, err := less(sorted[i], sorted[j])
+			failed = err
+			return before
+		}
+
+		result := e.call(args[1], sorted[i], sorted[j])
+		if isError(result) {
+			failed = result.(*object.Error)
+			return false
+		}
+		return isTruthy(result)
+	})
+
+	if failed != nil {
+		return failed
+	}
+	return &object.Array{Elements: sorted}
+}
+
+// less reports whether a goes before b in the natural order of their type.
+func less(a, b object.Object) (bool, *object.Error) {
+	switch a := a.(type) {
+	case *object.Integer:
+		if b, ok := b.(*object.Integer); ok {
+			return a.Value < b.Value, nil
+		}
+	case *object.Float:
+		if b, ok := b.(*object.Float); ok {
+			return a.Value < b.Value, nil
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value, nil
+		}
+	case *object.Rune:
+		if b, ok := b.(*object.Rune); ok {
+			return a.Value < b.Value, nil
+		}
+	default:
+		return false, newError(object.TYPE_ERROR, "cannot sort %s without a comparison function", a.Type())
+	}
+
+	return false, newError(object.TYPE_ERROR, "cannot compare %s and %s", a.Type(), b.Type())
+}
+
+// rangeArray returns the integers from a start, 0 if left out, up to an
+// end, counting by a step, 1 if left out.
+func (e *Evaluator) rangeArray(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+
+	want := []object.ObjectType{object.INTEGER, object.INTEGER, object.INTEGER}
+	if err := checkArguments("range", args, want[:len(args)]...); err != nil {
+		return err
+	}
+
+	var start, end, step int64 = 0, 0, 1
+	switch len(args) {
+	case 1:
+		end = args[0].(*object.Integer).Value
+	case 3:
+		step = args[2].(*object.Integer).Value
+		fallthrough
+	default:
+		start, end = args[0].(*object.Integer).Value, args[1].(*object.Integer).Value
+	}
+
+	if step == 0 {
+		return newError(object.ARGUMENT_ERROR, "step to `range` cannot be zero")
+	}
+
+	// the differences may not fit an int64, but they fit an uint64
+	var count uint64
+	switch {
+	case step > 0 && start < end:
+		count = (uint64(end-start)-1)/uint64(step) + 1
+	case step < 0 && start > end:
+		count = (uint64(start-end)-1)/uint64(-step) + 1
+	}
+
+	if count > math.MaxInt32 {
+		return newError(object.ARGUMENT_ERROR, "range of %d integers is too large", count)
+	}
+
+	if e.limiter != nil {
+		if err := e.limiter.length(object.ARRAY, int(count)); err != nil {
+			return err
+		}
+	}
+
+	elements := make([]object.Object, count)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: start + int64(i)*step}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func reverse(args ...object.Object) object.Object {
+	if err := checkArguments("reverse", args, object.ARRAY); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	reversed := make([]object.Object, len(elements))
+	for i, element := range elements {
+		reversed[len(elements)-1-i] = element
+	}
+
+	return &object.Array{Elements: reversed}
+}
+
+// zip pairs the elements of two arrays by position, as long as the shorter
+// one.
+func zip(args ...object.Object) object.Object {
+	if err := checkArguments("zip", args, object.ARRAY, object.ARRAY); err != nil {
+		return err
+	}
+
+	left, right := args[0].(*object.Array).Elements, args[1].(*object.Array).Elements
+	if len(right) < len(left) {
+		left = left[:len(right)]
+	}
+
+	pairs := make([]object.Object, len(left))
+	for i := range left {
+		pairs[i] = &object.Array{Elements: []object.Object{left[i], right[i]}}
+	}
+
+	return &object.Array{Elements: pairs}
+}
+
+// enumerate pairs the elements of an array with their indexes.
+func enumerate(args ...object.Object) object.Object {
+	if err := checkArguments("enumerate", args, object.ARRAY); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	pairs := make([]object.Object, len(elements))
+	for i, element := range elements {
+		pairs[i] = &object.Array{Elements: []object.Object{&object.Integer{Value: int64(i)}, element}}
+	}
+
+	return &object.Array{Elements: pairs}
+}
+
+// sliceBuiltin is slice(s, low, high), the same as s[low:high]. The high
+// bound can be left out.
+func sliceBuiltin(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	length, ok := object.SliceLength(args[0])
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `slice` not supported, got %s", args[0].Type())
+	}
+
+	bounds := []int64{0, length}
+	for i, arg := range args[1:] {
+		bound, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument %d to `slice` must be %s, got %s",
+				i+2, object.INTEGER, arg.Type())
+		}
+
+		bounds[i] = bound.Value
+		if bound.Value < 0 {
+			bounds[i] += length
+		}
+	}
+
+	return object.Slice(args[0], bounds[0], bounds[1])
+}
+
+// concat returns the elements of arrays one after the other.
+func (e *Evaluator) concat(args ...object.Object) object.Object {
+	size := 0
+	for i, arg := range args {
+		array, ok := arg.(*object.Array)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument %d to `concat` must be %s, got %s",
+				i+1, object.ARRAY, arg.Type())
+		}
+		size += len(array.Elements)
+	}
+
+	if e.limiter != nil {
+		if err := e.limiter.length(object.ARRAY, size); err != nil {
+			return err
+		}
+	}
+
+	elements := make([]object.Object, 0, size)
+	for _, arg := range args {
+		elements = append(elements, arg.(*object.Array).Elements...)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// indexOf returns the index of the first element of an array equal to a
+// value, or -1.
+func indexOf(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `indexOf` not supported, got %s", args[0].Type())
+	}
+
+	for i, element := range array.Elements {
+		if equal(element, args[1]) {
+			return &object.Integer{Value: int64(i)}
+		}
+	}
+
+	return &object.Integer{Value: -1}
+}
+
+// contains reports whether an array has an element equal to a value, or
+// whether a string contains another.
+func contains(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	switch args[0].(type) {
+	case *object.String:
+		return stringPredicate("contains", strings.Contains)(args...)
+	case *object.Array:
+		return nativeBoolToBooleanObject(indexOf(args...).(*object.Integer).Value >= 0)
+	default:
+		return newError(object.TYPE_ERROR, "argument to `contains` not supported, got %s", args[0].Type())
+	}
+}
+
+// equal reports whether two values are the same: numbers, strings, runes
+// and booleans by value, arrays and hashes by their elements, and the rest
+// by identity.
+func equal(a, b object.Object) bool {
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.Float:
+		b, ok := b.(*object.Float)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Rune:
+		b, ok := b.(*object.Rune)
+		return ok && a.Value == b.Value
+	case *object.Boolean:
+		b, ok := b.(*object.Boolean)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		// the order of the pairs does not matter
+		b, ok := b.(*object.Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(object.Hashable))
+			if !ok || !equal(pair.Value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// hashElements returns a builtin making an array of something of every
+// pair of a hash.
+func hashElements(name string, element func(object.HashPair) object.Object) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArguments(name, args, object.HASH); err != nil {
+			return err
+		}
+
+		pairs := args[0].(*object.Hash).Pairs()
+		elements := make([]object.Object, 0, len(pairs))
+		for _, pair := range pairs {
+			elements = append(elements, element(pair))
+		}
+
+		return &object.Array{Elements: elements}
+	}
+}
+
+// hashKey returns a value as a hash key, if it can be one.
+func hashKey(key object.Object) (object.Hashable, *object.Error) {
+	hashable, ok := object.Key(key)
+	if !ok {
+		return nil, newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+	}
+	return hashable, nil
+}
+
+// has reports whether a hash has a key.
+func has(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `has` not supported, got %s", args[0].Type())
+	}
+
+	key, err := hashKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	_, ok = hash.Get(key)
+	return nativeBoolToBooleanObject(ok)
+}
+
+// deleteKey removes a key from a hash and returns its value, or null when
+// the hash has no such key.
+func deleteKey(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `delete` not supported, got %s", args[0].Type())
+	}
+
+	key, err := hashKey(args[1])
+	if err != nil {
+		return err
+	}
+
+	value, ok := hash.Get(key)
+	if !ok {
+		return NULL
+	}
+
+	hash.Delete(key)
+	return value
+}
+
+// merge returns a new hash with the pairs of hashes, where the pairs of
+// later hashes replace the values of earlier hashes with the same key, in
+// their place.
+func merge(args ...object.Object) object.Object {
+	merged := &object.Hash{}
+	for i, arg := range args {
+		hash, ok := arg.(*object.Hash)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument %d to `merge` must be %s, got %s",
+				i+1, object.HASH, arg.Type())
+		}
+
+		for _, pair := range hash.Pairs() {
+			merged.Set(pair.Key.(object.Hashable), pair.Value)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,651 @@
+// Package evaltest has the programs the tests of the evaluator run, so the
+// tests of the virtual machine can check that both engines agree on them.
+package evaltest
+
+// Case is a program and what it evaluates to. What Expected holds depends
+// on the table: a Go value like an int64, the value inspected, or the
+// message of an error.
+type Case[T any] struct {
+	Input    string
+	Expected T
+}
+
+// Inputs returns the programs of all the tables.
+func Inputs() []string {
+	var all []string
+	for _, table := range [][]string{
+		inputs(Integers),
+		inputs(Floats),
+		inputs(Strings),
+		inputs(Lets),
+		inputs(Errors),
+		inputs(Booleans),
+		inputs(IfElse),
+		inputs(Returns),
+		inputs(Runes),
+		inputs(Bangs),
+		inputs(ForLoops),
+		inputs(ForInLoops),
+		inputs(BreakAndContinue),
+		inputs(WhileLoops),
+		inputs(FunctionApplications),
+		inputs(Builtins),
+		inputs(StringBuiltins),
+		inputs(Arithmetic),
+		inputs(MathBuiltins),
+		inputs(NumberConversions),
+		inputs(CollectionBuiltins),
+		inputs(JSON),
+		inputs(StringIndexesAndSlices),
+		inputs(HashOrder),
+		inputs(HashKeys),
+		inputs(HashIndexes),
+		inputs(Members),
+		inputs(ArrayIndexAssignments),
+		inputs(AssignmentIdentifiers),
+		inputs(HashIndexAssignments),
+		inputs(ArrayIndexes),
+	} {
+		all = append(all, table...)
+	}
+	return all
+}
+
+func inputs[T any](cases []Case[T]) []string {
+	programs := make([]string, len(cases))
+	for i, c := range cases {
+		programs[i] = c.Input
+	}
+	return programs
+}
+
+// Integers are the integer expressions.
+var Integers = []Case[int64]{
+	{"5", 5},
+	{"10", 10},
+	{"-5", -5},
+	{"5 + 5 + 5 + 5 - 10", 10},
+	{"2 * 2 * 2 * 2 * 2", 32},
+	{"-50 + 100 + -50", 0},
+	{"5 * 2 + 10", 20},
+	{"5 + 2 * 10", 25},
+	{"20 + 2 * -10", 0},
+	{"50 / 2 * 2 + 10", 60},
+	{"2 * (5 + 10)", 30},
+	{"3 * 3 * 3 + 10", 37},
+	{"3 * (3 * 3) + 10", 37},
+	{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+}
+
+// Floats are the float literals.
+var Floats = []Case[float64]{
+	{"10.123456", 10.123456},
+	{"1.", 1.},
+	{"-1.0", -1.0},
+}
+
+// Strings are the string literals and their escapes.
+var Strings = []Case[string]{
+	{`"Hello World!"`, "Hello World!"},
+	{`"Hello\nWorld!"`, "Hello\nWorld!"},
+	{`"Hello\tWorld!"`, "Hello\tWorld!"},
+	{`"Hello\rWorld!"`, "Hello\rWorld!"},
+	{`"Hello\"World!"`, "Hello\"World!"},
+	{`"Hello\\World!"`, "Hello\\World!"},
+	{`"Hello😀World!"`, "Hello😀World!"},
+	{`"Hello🐶World!"`, "Hello🐶World!"},
+}
+
+// Lets are the let statements.
+var Lets = []Case[int64]{
+	{"let a = 5; a;", 5},
+	{"let a = 5 * 5; a;", 25},
+	{"let a = 5; let b = a; b;", 5},
+	{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+}
+
+// Errors are the programs that fail, with the message of their error.
+var Errors = []Case[string]{
+	{
+		"5 + true;",
+		"type mismatch: INTEGER + BOOLEAN",
+	},
+	{
+		`"a" - "b"`,
+		"unknown operator: STRING - STRING",
+	},
+	{
+		`'a' * 'b'`,
+		"unknown operator: RUNE * RUNE",
+	},
+	{
+		"5 + true; 5;",
+		"type mismatch: INTEGER + BOOLEAN",
+	},
+	{
+		"-true",
+		"unknown operator: -BOOLEAN",
+	},
+	{
+		"true + false;",
+		"unknown operator: BOOLEAN + BOOLEAN",
+	},
+	{
+		"5; true + false; 5",
+		"unknown operator: BOOLEAN + BOOLEAN",
+	},
+	{
+		"if (10 > 1) { true + false; }",
+		"unknown operator: BOOLEAN + BOOLEAN",
+	},
+	{
+		"foobar",
+		"identifier not found: foobar",
+	},
+	{
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		"unusable as hash key: FUNCTION",
+	},
+	{
+		`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`,
+		"unknown operator: BOOLEAN + BOOLEAN",
+	},
+	{
+		"let f = fn(n) { 1 + f(n) }; f(0)",
+		"stack overflow",
+	},
+}
+
+// Booleans are the comparisons and boolean literals.
+var Booleans = []Case[bool]{
+	{"true", true},
+	{"false", false},
+	{"true", true},
+	{"false", false},
+	{"1 < 2", true},
+	{"1 > 2", false},
+	{"1 < 1", false},
+	{"1 > 1", false},
+	{"1 == 1", true},
+	{"1 != 1", false},
+	{"1 == 2", false},
+	{"1 != 2", true},
+	{"false != true", true},
+	{"(1 < 2) == true", true},
+	{"(1 < 2) == false", false},
+	{"(1 > 2) == true", false},
+	{"(1 > 2) == false", true},
+	{`"a" == "a"`, true},
+	{`"a" == "b"`, false},
+	{`"x" != "x"`, false},
+	{`"a" < "b"`, true},
+	{`"b" > "ab"`, true},
+	{`"a" > "a"`, false},
+	{`'a' == 'a'`, true},
+	{`'a' != 'b'`, true},
+	{`'a' < 'b'`, true},
+	{`'🐶' > 'a'`, true},
+	{`"a" == 'a'`, false},
+	{`let n = 0; for (ch in "banana") { if (ch == 'a') { n++ } }; n == 3`, true},
+}
+
+// IfElse are the if expressions, nil where they have no value.
+var IfElse = []Case[interface{}]{
+	{"if (true) { 10 }", 10},
+	{"if (false) { 10 }", nil},
+	{"if (1) { 10 }", 10},
+	{"if (1 < 2) { 10 }", 10},
+	{"if (1 > 2) { 10 }", nil},
+	{"if (1 > 2) { 10 } else { 20 }", 20},
+	{"if (1 < 2) { 10 } else { 20 }", 10},
+}
+
+// Returns are the return statements.
+var Returns = []Case[int64]{
+	{"return 10;", 10},
+	{"return 10; 9;", 10},
+	{"return 2 * 5; 9;", 10},
+	{"9; return 2 * 5; 9;", 10},
+	{
+		`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`,
+		10,
+	},
+}
+
+// Runes are the rune literals.
+var Runes = []Case[rune]{
+	{"'🐶'", '🐶'},
+}
+
+// Bangs are the uses of the ! operator.
+var Bangs = []Case[bool]{
+	{"!true", false},
+	{"!false", true},
+	{"!5", false},
+	{"!!true", true},
+	{"!!false", false},
+	{"!!5", true},
+}
+
+// ForLoops are the for loops with an init, a condition and a post statement.
+var ForLoops = []Case[int64]{
+	{"for (let i = 0; i < 10; i++) { i }", 10},
+	{"for (let i = 10; i > 0; i--) { i }", 0},
+	{"for (let i = 10; i > 0; i--) { if (i < 5) { return 1000 } return 90 }", 90},
+}
+
+// ForInLoops are the for loops over arrays, strings and hashes.
+var ForInLoops = []Case[string]{
+	{`let s = 0; for (x in [1, 2, 3]) { s = s + x }; s`, "6"},
+	{`let s = 0; for (i, x in [10, 20, 30]) { s = s + i * x }; s`, "80"},
+	{`let r = []; for (i, ch in "héllo") { push(r, [i, ch]) }; r`, "[[0, h], [1, é], [2, l], [3, l], [4, o]]"},
+	{`let r = []; for (ch in "ab") { push(r, ch) }; r`, "[a, b]"},
+	{`let r = []; for (k, v in {"a": 1}) { push(r, [k, v]) }; r`, "[[a, 1]]"},
+	{`let r = []; for (k in {"a": 1}) { push(r, k) }; r`, "[a]"},
+	{`let s = 0; let h = {1: 10, 2: 20, 3: 30}; for (k, v in h) { s = s + k * v }; s`, "140"},
+	{`for (x in [1, 2, 3]) { x * 2 }`, "6"},
+	{`for (x in []) { x }`, "null"},
+	{`let fs = []; for (x in [1, 2]) { push(fs, fn() { x }) }; [fs[0](), fs[1]()]`, "[1, 2]"},
+	{`let x = 5; for (x in [1, 2]) { x }; x`, "5"},
+	{`let f = fn(a) { for (x in a) { if (x > 1) { return x } } 0 }; f([1, 2, 3])`, "2"},
+	{`let a = [1, 2]; let n = 0; for (x in a) { push(a, x); n++ }; [n, len(a)]`, "[2, 4]"},
+	{`for (x in 1) { x }`, "ERROR: cannot loop over INTEGER"},
+	{`for (x in [1, y]) { x }`, "ERROR: identifier not found: y"},
+}
+
+// BreakAndContinue are the loops left or continued early, labeled or not.
+var BreakAndContinue = []Case[string]{
+	{`let s = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break }; s = s + x }; s`, "3"},
+	{`let s = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { continue }; s = s + x }; s`, "7"},
+	{`let s = 0; for (let i = 0; i < 10; i++) { if (i == 4) { break }; s = s + i }; s`, "6"},
+	{`let s = 0; for (let i = 0; i < 5; i++) { if (i % 2 == 0) { continue }; s = s + i }; s`, "4"},
+	{`for (x in [1, 2]) { break }`, "null"},
+	{`for (x in [1, 2]) { if (x == 2) { continue }; x }`, "null"},
+	{`let n = 0; for (a in [1, 2]) { for (b in [1, 2, 3]) { if (b == 2) { break }; n++ } }; n`, "2"},
+	{`let f = fn() { for (x in [1, 2]) { break }; 7 }; f()`, "7"},
+	{`let n = 0; for (x in [1, 2, 3]) { try { if (x == 2) { break } } finally { n++ } }; n`, "2"},
+	{`let n = 0; for (x in [1, 2, 3]) { try { throw "e" } catch (e) { continue }; n++ }; n`, "0"},
+	{`let i = 0; while (true) { i++; if (i > 4) { break } }; i`, "5"},
+	{`let i = 0; let s = 0; while (i < 5) { i++; if (i % 2 == 0) { continue }; s = s + i }; s`, "9"},
+	{`let n = 0; outer: for (a in [1, 2, 3]) { for (b in [1, 2, 3]) { if (b == 2) { continue outer }; if (a == 3) { break outer }; n++ } }; n`, "2"},
+	{`let r = []; outer: while (len(r) < 5) { for (x in [1, 2]) { if (len(r) == 3) { break outer }; push(r, x) } }; r`, "[1, 2, 1]"},
+	{`let n = 0; a: for (x in [1, 2]) { b: for (y in [1, 2]) { n++; break b } }; n`, "2"},
+	{`let n = 0; outer: for (let i = 0; i < 3; i++) { while (true) { n++; continue outer } }; n`, "3"},
+}
+
+// WhileLoops are the while loops.
+var WhileLoops = []Case[string]{
+	{`let i = 0; while (i < 3) { i = i + 1 }; i`, "3"},
+	{`let i = 0; while (i < 3) { i = i + 1; i * 10 }`, "30"},
+	{`while (false) { 1 }`, "null"},
+	{`let f = fn(n) { while (true) { if (n > 3) { return n }; n++ } }; f(0)`, "4"},
+	{`while (x) { 1 }`, "ERROR: identifier not found: x"},
+	{`let i = 0; while (i < 2) { let j = i; i++ }; j`, "ERROR: identifier not found: j"},
+}
+
+// FunctionApplications are the calls of function literals.
+var FunctionApplications = []Case[int64]{
+	{"let identity = fn(x) { x; }; identity(5);", 5},
+	{"let identity = fn(x) { return x; }; identity(5);", 5},
+	{"let double = fn(x) { x * 2; }; double(5);", 10},
+	{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+	{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+	{"fn(x) { x; }(5)", 5},
+}
+
+// Builtins are the calls of len, with the integers they return or the
+// messages of their errors.
+var Builtins = []Case[interface{}]{
+	{`len("")`, 0},
+	{`len("four")`, 4},
+	{`len("hello world")`, 11},
+	{`len("héllo, 世界")`, 9},
+	{`len(1)`, "argument to `len` not supported, got INTEGER"},
+	{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+}
+
+// StringBuiltins are the builtins working on strings.
+var StringBuiltins = []Case[string]{
+	{`split("a,b,,c", ",")`, "[a, b, , c]"},
+	{`split("añb", "")`, "[a, ñ, b]"},
+	{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+	{`join([], "-")`, ""},
+	{`join(["a", 1], "")`, "ERROR: argument 1 to `join` must be an array of strings, got INTEGER at 1"},
+	{`contains("slang", "lan")`, "true"},
+	{`contains("slang", "x")`, "false"},
+	{`index("héllo", "llo")`, "2"},
+	{`index("hello", "x")`, "-1"},
+	{`replace("a-b-c", "-", "+")`, "a+b+c"},
+	{`trim("  \t hi \n")`, "hi"},
+	{`upper("ñandú")`, "ÑANDÚ"},
+	{`lower("ÀB")`, "àb"},
+	{`substr("héllo", 1, 3)`, "éll"},
+	{`substr("héllo", 2)`, "llo"},
+	{`substr("héllo", 5)`, ""},
+	{`substr("héllo", 3, 5)`, "ERROR: substring out of range: start 3, length 5 of 5"},
+	{`substr("héllo")`, "ERROR: wrong number of arguments. got=1, want=3"},
+	{`startsWith("slang", "sl")`, "true"},
+	{`endsWith("slang", "sl")`, "false"},
+	{`repeat("ab", 3)`, "ababab"},
+	{`repeat("ab", -1)`, "ERROR: negative count to `repeat`: -1"},
+	{`repeat("ab", 4611686018427387904)`, "ERROR: 4611686018427387904 copies of a string of 2 bytes are too large"},
+	{`repeat("", 4611686018427387904)`, ""},
+	{`substr("abc", 1, 9223372036854775807)`, "ERROR: substring out of range: start 1, length 9223372036854775807 of 3"},
+	{`upper(1)`, "ERROR: argument 1 to `upper` must be STRING, got INTEGER"},
+	{`contains("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+}
+
+// Arithmetic are the arithmetic operators on integers and floats.
+var Arithmetic = []Case[string]{
+	{"7 % 3", "1"},
+	{"-7 % 3", "-1"},
+	{"7.5 % 2.0", "1.500000"},
+	{"2 ** 10", "1024"},
+	{"2 ** 3 ** 2", "512"},
+	{"-2 ** 2", "-4"},
+	{"(-2) ** 2", "4"},
+	{"2 * 3 ** 2", "18"},
+	{"5 ** 0", "1"},
+	{"2.0 ** 0.5", "1.414214"},
+	{"2 ** -1", "ERROR: negative exponent: 2 ** -1"},
+	{"2 ** 62", "4611686018427387904"},
+	{"2 ** 63", "ERROR: integer overflow: 2 ** 63"},
+	{"2 ** 64", "ERROR: integer overflow: 2 ** 64"},
+	{"1 / 0", "ERROR: division by zero"},
+	{"1 % 0", "ERROR: division by zero"},
+	{"1.0 / 0.0", "ERROR: division by zero"},
+	{"1.0 % 0.0", "ERROR: division by zero"},
+	{"2 ** 1.0", "ERROR: type mismatch: INTEGER ** FLOAT"},
+	{"try { 1 / 0 } catch (e) { e.kind }", "ZeroDivisionError"},
+}
+
+// MathBuiltins are the members of the math namespace.
+var MathBuiltins = []Case[string]{
+	{"math.pi", "3.141593"},
+	{"math.e", "2.718282"},
+	{"math.abs(-3)", "3"},
+	{"math.abs(-2.5)", "2.500000"},
+	{"math.floor(2.7)", "2.000000"},
+	{"math.ceil(2.1)", "3.000000"},
+	{"math.round(2.5)", "3.000000"},
+	{"math.floor(4)", "4"},
+	{"math.sqrt(16)", "4.000000"},
+	{"math.sqrt(-1.0)", "ERROR: argument to `sqrt` out of its domain: -1.000000"},
+	{"math.log(1)", "0.000000"},
+	{"math.log(0)", "ERROR: argument to `log` out of its domain: 0"},
+	{"math.exp(0)", "1.000000"},
+	{"math.sin(0)", "0.000000"},
+	{"math.cos(0.0)", "1.000000"},
+	{"math.tan(0)", "0.000000"},
+	{"math.pow(2, 8)", "256"},
+	{"math.pow(4, 0.5)", "2.000000"},
+	{"math.pow(2, -1)", "ERROR: negative exponent: 2 ** -1"},
+	{"math.pow(2, 64)", "ERROR: integer overflow: 2 ** 64"},
+	{"math.pow(2.0, 64)", "18446744073709551616.000000"},
+	{"math.min(3, 1, 2)", "1"},
+	{"math.max(1.5, 2.5)", "2.500000"},
+	{"math.max(1, 2.5)", "ERROR: arguments to `max` must be of one type, got INTEGER and FLOAT"},
+	{"math.min()", "ERROR: wrong number of arguments. got=0, want at least 1"},
+	{`math.abs("a")`, "ERROR: argument 1 to `abs` must be INTEGER or FLOAT, got STRING"},
+	{"math.sqrt(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+}
+
+// NumberConversions are the conversions between strings, integers and floats.
+var NumberConversions = []Case[string]{
+	{"int(2.9)", "2"},
+	{"int(-2.9)", "-2"},
+	{"int('a')", "97"},
+	{`int(" 42 ")`, "42"},
+	{`int("4x")`, `ERROR: cannot convert "4x" to an integer`},
+	{"int(math.sqrt(-0.0) / 0.0)", "ERROR: division by zero"},
+	{"int(2.0 ** 63.0)", "ERROR: cannot convert 9223372036854775808.000000 to an integer"},
+	{"int(true)", "ERROR: argument to `int` not supported, got BOOLEAN"},
+	{"float(3)", "3.000000"},
+	{`float("2.5")`, "2.500000"},
+	{`float("x")`, `ERROR: cannot convert "x" to a float`},
+	{"float(3) / 2.0", "1.500000"},
+	{"int(7.0 / 2.0) % 2", "1"},
+}
+
+// CollectionBuiltins are the builtins working on arrays and hashes.
+var CollectionBuiltins = []Case[string]{
+	{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+	{"map([], fn(x) { x })", "[]"},
+	{`map(["a", "bc"], len)`, "[1, 2]"},
+	{"filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })", "[2, 4]"},
+	{"reduce([1, 2, 3], 0, fn(sum, x) { sum + x })", "6"},
+	{"reduce([], 10, fn(sum, x) { sum + x })", "10"},
+	{"let xs = [3, 1, 2]; sort(xs); xs", "[3, 1, 2]"},
+	{"sort([3, 1, 2])", "[1, 2, 3]"},
+	{`sort(["b", "c", "a"])`, "[a, b, c]"},
+	{"sort([2.5, 1.5])", "[1.500000, 2.500000]"},
+	{"sort([3, 1, 2], fn(a, b) { a > b })", "[3, 2, 1]"},
+	{"sort([[2, 'a'], [1, 'b'], [2, 'c'], [1, 'd']], fn(a, b) { a[0] < b[0] })", "[[1, b], [1, d], [2, a], [2, c]]"},
+	{`sort([1, "a"])`, "ERROR: cannot compare STRING and INTEGER"},
+	{"sort([[1], [2]])", "ERROR: cannot sort ARRAY without a comparison function"},
+	{"reverse([1, 2, 3])", "[3, 2, 1]"},
+	{"range(4)", "[0, 1, 2, 3]"},
+	{"range(2, 5)", "[2, 3, 4]"},
+	{"range(10, 0, -3)", "[10, 7, 4, 1]"},
+	{"range(0, 10, 5)", "[0, 5]"},
+	{"range(3, 1)", "[]"},
+	{"range(0, 1, 0)", "ERROR: step to `range` cannot be zero"},
+	{"range(0, 9223372036854775807)", "ERROR: range of 9223372036854775807 integers is too large"},
+	{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+	{`enumerate(["a", "b"])`, "[[0, a], [1, b]]"},
+	{"slice([1, 2, 3, 4], 1, 3)", "[2, 3]"},
+	{"slice([1, 2, 3, 4], -2)", "[3, 4]"},
+	{`slice("héllo", 1, -1)`, "éll"},
+	{"slice([1], 0, 2)", "ERROR: slice bounds out of range: [0:2] with length 1"},
+	{"concat([1], [], [2, 3])", "[1, 2, 3]"},
+	{"concat()", "[]"},
+	{"concat([1], 2)", "ERROR: argument 2 to `concat` must be ARRAY, got INTEGER"},
+	{`indexOf([1, "a", [2]], [2])`, "2"},
+	{"indexOf([1, 2], 1.0)", "-1"},
+	{`contains(["a", "b"], "b")`, "true"},
+	{`contains("slang", "an")`, "true"},
+	{`contains(1, 1)`, "ERROR: argument to `contains` not supported, got INTEGER"},
+	{`keys({"a": 1})`, "[a]"},
+	{`values({"a": 1})`, "[1]"},
+	{`items({"a": 1})`, "[[a, 1]]"},
+	{`sort(keys({"b": 1, "a": 2, "c": 3}))`, "[a, b, c]"},
+	{`has({"a": 1}, "a")`, "true"},
+	{`has({"a": 1}, "b")`, "false"},
+	{`has({}, [])`, "false"},
+	{`has({}, [fn() {}])`, "ERROR: unusable as hash key: ARRAY"},
+	{`let h = {"a": 1, "b": 2}; let v = delete(h, "a"); [v, h]`, "[1, {b: 2}]"},
+	{`delete({}, "a")`, "null"},
+	{`merge({"a": 1, "b": 2}, {"b": 3})["b"]`, "3"},
+	{`let h = {"a": 1}; merge(h, {"b": 2}); h`, "{a: 1}"},
+	{"map([1], 2)", "ERROR: argument 2 to `map` must be FUNCTION, got INTEGER"},
+	{"map(1, fn(x) { x })", "ERROR: argument 1 to `map` must be ARRAY, got INTEGER"},
+	{"reduce([1], 0, 1)", "ERROR: argument 3 to `reduce` must be FUNCTION, got INTEGER"},
+	{"filter([1])", "ERROR: wrong number of arguments. got=1, want=2"},
+	{"map([1], fn(x, y) { x })", "ERROR: wrong number of arguments: want=2, got=1"},
+	{`map([1, "a"], fn(x) { x + 1 })`, "ERROR: type mismatch: STRING + INTEGER"},
+	{`try { map([1], fn(x) { throw "stop" }) } catch (e) { e.message }`, "stop"},
+}
+
+// JSON are the members of the json namespace.
+var JSON = []Case[string]{
+	{`json.parse("[1, -2.5, 1e2, \"a\\nb\", true, false, null]")`, "[1, -2.500000, 100.000000, a\nb, true, false, null]"},
+	{`json.parse("{\"a\": {\"b\": [1]}}").a.b[0]`, "1"},
+	{`json.parse("{}")`, "{}"},
+	{`json.parse("92233720368547758070")`, "92233720368547758080.000000"},
+	{`json.parse("[1,")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+	{`json.parse("")`, "ERROR: invalid JSON: unexpected end of JSON input"},
+	{`json.parse("{1: 2}")`, "ERROR: invalid JSON: object member name must be a string"},
+	{`json.parse("1 2")`, "ERROR: invalid JSON: data after the value"},
+	{`json.parse(1)`, "ERROR: argument 1 to `parse` must be STRING, got INTEGER"},
+	{`json.stringify([1, 2.0, 1.5, "a\"<b>", 'c', true, first([]), [], {}])`, `[1,2.0,1.5,"a\"<b>","c",true,null,[],{}]`},
+	{`json.stringify({"a": {"b": [1, 2]}})`, `{"a":{"b":[1,2]}}`},
+	{`json.stringify({"a": [1, 2]}, 2)`, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+	{`json.stringify([1], "\t")`, "[\n\t1\n]"},
+	{`json.stringify("x", true)`, "ERROR: argument 2 to `stringify` must be INTEGER or STRING, got BOOLEAN"},
+	{`json.stringify(fn(x) { x })`, "ERROR: cannot convert FUNCTION to JSON"},
+	{`json.stringify([len])`, "ERROR: cannot convert BUILTIN to JSON"},
+	{`json.stringify({1: "a"})`, "ERROR: cannot convert a hash with INTEGER keys to JSON"},
+	{`let a = [1, 2]; a[0] = a; json.stringify(a)`, "ERROR: cannot convert a ARRAY that contains itself to JSON"},
+	{`let a = [1]; json.stringify([a, a])`, "[[1],[1]]"},
+	{`json.stringify(0.0 / 1.0 - math.log(1))`, "0.0"},
+	{`let v = json.parse("{\"n\": [2.0, 3, \"x\"]}"); json.stringify(v)`, `{"n":[2.0,3,"x"]}`},
+}
+
+// StringIndexesAndSlices are the indexes and slices of strings and arrays.
+var StringIndexesAndSlices = []Case[string]{
+	{`"héllo"[1]`, "é"},
+	{`"héllo"[-1]`, "o"},
+	{`"héllo"[5]`, "ERROR: index out of range: 5"},
+	{`"héllo"["a"]`, "ERROR: index must be an integer"},
+	{`"héllo"[1:3]`, "él"},
+	{`"héllo"[:2]`, "hé"},
+	{`"héllo"[2:]`, "llo"},
+	{`"héllo"[:]`, "héllo"},
+	{`"héllo"[-3:-1]`, "ll"},
+	{`"héllo"[3:2]`, "ERROR: slice bounds out of range: [3:2] with length 5"},
+	{`"héllo"[0:9]`, "ERROR: slice bounds out of range: [0:9] with length 5"},
+	{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+	{`[1, 2, 3][true:]`, "ERROR: slice index must be an integer, got BOOLEAN"},
+	{`let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a`, "[1, 2, 3]"},
+	{`1[0:1]`, "ERROR: slice operator not supported: INTEGER"},
+	{`[1, 2]["a"]`, "ERROR: index must be an integer"},
+}
+
+// HashOrder are the hashes, which keep the order their keys were set in.
+var HashOrder = []Case[string]{
+	{`{"name": "John", "age": 42, "nested": {"z": 1, "a": 2}}`, "{name: John, age: 42, nested: {z: 1, a: 2}}"},
+	{`let h = {"b": 1}; h["a"] = 2; h["c"] = 3; h["b"] = 4; h`, "{b: 4, a: 2, c: 3}"},
+	{`let h = {"b": 1, "a": 2}; delete(h, "b"); h["b"] = 3; h`, "{a: 2, b: 3}"},
+	{`let h = {3: 'c', 1: 'a', 2: 'b'}; [keys(h), values(h), items(h)]`, "[[3, 1, 2], [c, a, b], [[3, c], [1, a], [2, b]]]"},
+	{`let r = []; for (k, v in {"z": 1, "y": 2, "x": 3}) { push(r, k) }; r`, "[z, y, x]"},
+	{`merge({"b": 1, "a": 2}, {"c": 3, "b": 4})`, "{b: 4, a: 2, c: 3}"},
+	{`json.stringify({"z": 1, "a": [true]})`, `{"z":1,"a":[true]}`},
+	{`json.stringify(json.parse("{\"z\": 1, \"a\": 2}"))`, `{"z":1,"a":2}`},
+	{`try { throw "boom" } catch (e) { keys(e) }`, "[message, kind, trace]"},
+	// the order of the pairs doesn't matter to equality
+	{`contains([{"a": 1, "b": 2}], {"b": 2, "a": 1})`, "true"},
+	{`contains([{"a": 1, "b": 2}], {"b": 2, "a": 3})`, "false"},
+}
+
+// HashKeys are the values used as hash keys.
+var HashKeys = []Case[string]{
+	{`let h = {1.2: "a", 1.9: "b"}; [h[1.2], h[1.9], len(keys(h))]`, "[a, b, 2]"},
+	{`{0.0: "zero"}[-0.0]`, "zero"},
+	{`{'a': 1, "a": 2}['a']`, "1"},
+	{`{[1, 2]: "x", [[1], 'a']: "y"}[[1, 2]]`, "x"},
+	{`{[[1], 'a']: "y"}[[[1], 'a']]`, "y"},
+	{`{[1, 2]: "x"}[[2, 1]]`, "null"},
+	{`let k = [1]; let h = {}; h[k] = "x"; push(k, 2); [h[[1]], h[k], keys(h)]`, "[x, null, [[1]]]"},
+	{`let n = 1; let h = {n: "x"}; n++; [h[1], h[n]]`, "[x, null]"},
+	// 1 and 1.0 are not equal, so they are different keys
+	{`{1: "i"}[1.0]`, "null"},
+	{`let h = {1: "i", 1.0: "f"}; [h[1], h[1.0], len(keys(h))]`, "[i, f, 2]"},
+	{`{[fn() {}]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+	{`let a = [1]; a[0] = a; {a: 1}`, "ERROR: unusable as hash key: ARRAY"},
+	{`let h = {}; h[[{}]] = 1`, "ERROR: unusable as hash key: ARRAY"},
+}
+
+// HashIndexes are the indexes of hashes, nil where the key is missing.
+var HashIndexes = []Case[interface{}]{
+	{`{"foo": 5}["foo"]`, 5},
+	{`{"foo": 5}["bar"]`, nil},
+	{`let key = "foo"; {"foo": 5}[key]`, 5},
+	{`{}["foo"]`, nil},
+	{`{5: 5}[5]`, 5},
+	{`{true: 5}[true]`, 5},
+	{`{false: 5}[false]`, 5},
+}
+
+// Members are the members of hashes, nil where they are missing.
+var Members = []Case[interface{}]{
+	{`let h = {"foo": 5}; h.foo`, 5},
+	{`let h = {"foo": 5}; h.bar`, nil},
+	{`let h = {"foo": {"bar": 5}}; h.foo.bar`, 5},
+	{`let h = {}; h.foo = 5; h["foo"]`, 5},
+	{`let a = 1; a.foo`, "index operator not supported: INTEGER"},
+}
+
+// ArrayIndexAssignments are the assignments to elements of arrays.
+var ArrayIndexAssignments = []Case[interface{}]{
+	{`let a = [1, 2, 3]; a[0] = 4; a[0]`, 4},
+	{`let a = [1, 2, 3]; a[0] = 4; a[1]`, 2},
+	{`let a = [1, 2, 3]; a[0] = 4; a[2]`, 3},
+	{`let a = [1, 2, 3]; a[0] = 4; a[3]`, "index out of range: 3"},
+}
+
+// AssignmentIdentifiers are the assignments to names.
+var AssignmentIdentifiers = []Case[interface{}]{
+	{`let a = 5; a = 6; a`, 6},
+	{`let a = 5; let b = a; b`, 5},
+	{`let a = 5; let b = a; let c = a + b + 5; c`, 15},
+	{`let a = 5; let b = a; let c = a + b + 5; a = 10; c`, 15},
+}
+
+// HashIndexAssignments are the assignments to keys of hashes.
+var HashIndexAssignments = []Case[interface{}]{
+	{`let h = {}; h["foo"] = 5; h["foo"]`, 5},
+	{`let h = {}; h["foo"] = 5; h["bar"]`, nil},
+	{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["bar"]`, 6},
+	{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["foo"]`, 5},
+	{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["baz"] = 7; h["baz"]`, 7},
+}
+
+// ArrayIndexes are the indexes of arrays, nil where they are out of range.
+var ArrayIndexes = []Case[interface{}]{
+	{
+		"[1, 2, 3][0]",
+		1,
+	},
+	{
+		"[1, 2, 3][1]",
+		2,
+	},
+	{
+		"[1, 2, 3][2]",
+		3,
+	},
+	{
+		"let i = 0; [1][i];",
+		1,
+	},
+	{
+		"[1, 2, 3][1 + 1];",
+		3,
+	},
+	{
+		"let myArray = [1, 2, 3]; myArray[2];",
+		3,
+	},
+	{
+		"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+		6,
+	},
+	{
+		"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+		2,
+	},
+	{
+		"[1, 2, 3][3]",
+		"index out of range: 3",
+	},
+	{
+		"[1, 2, 3][-1]",
+		3,
+	},
+}
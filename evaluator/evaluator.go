@@ -1,9 +1,12 @@
 package evaluator
 
 import (
+	"bufio"
 	"compiler-book/ast"
+	"compiler-book/lexer"
 	"compiler-book/object"
 	"fmt"
+	"math"
 )
 
 var (
@@ -15,71 +18,194 @@ var (
 	NULL = &object.Null{}
 )
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
+// MaxNesting is the number of nodes that can be evaluated one inside the
+// other, which keeps deep recursion from overflowing the stack of Go. Its
+// 1 GB filled up after 550,000 to 800,000 nested nodes, depending on the
+// nodes, and a call like f(n - 1) in 1 + f(n - 1) nests 4 of them.
+const MaxNesting = 450_000
+
+// Evaluator holds the state of a running program, such as the modules it
+// has imported so far.
+type Evaluator struct {
+	modules map[string]*object.Module // imported modules by absolute path
+	files   []string                  // files being evaluated, innermost last
+	frames  []frame                   // the call stack, innermost last
+	hook    Hook                      // nil unless a debugger is attached
+	limiter *limiter                  // nil unless evaluating with limits
+	nesting int                       // nodes being evaluated
+	foreign ForeignApply              // nil unless another engine uses the builtins
+
+	builtins    Builtins
+	streams     Streams
+	stdin       *bufio.Reader // reads streams.Stdin a line at a time
+	permissions Permissions
+	args        []string // the arguments of the program
+}
+
+// frame is a function call being evaluated.
+type frame struct {
+	function string
+	file     string
+}
+
+// New returns an evaluator with the standard builtins, whose programs use
+// the standard streams of the process.
+func New() *Evaluator {
+	e := &Evaluator{
+		modules: make(map[string]*object.Module),
+		frames:  []frame{{function: "<main>"}},
+	}
+	e.SetStreams(defaultStreams())
+	e.builtins = e.standardBuiltins()
+
+	return e
+}
+
+// Eval evaluates node with a fresh Evaluator.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if e.nesting < MaxNesting {
+		e.nesting++
+		defer func() { e.nesting-- }()
+
+		result = e.evalLimited(node, env)
+	} else {
+		result = newError(object.STACK_OVERFLOW, "stack overflow")
+	}
+
+	// the innermost node an error comes out of is where it was raised
+	if err, ok := result.(*object.Error); ok && len(err.Trace) == 0 {
+		e.traceError(err, node)
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalLimited(node ast.Node, env *object.Environment) object.Object {
+	if e.limiter == nil {
+		return e.eval(node, env)
+	}
+
+	if err := e.limiter.step(); err != nil {
+		return err
+	}
+
+	result := e.eval(node, env)
+	if err := e.limiter.size(result); err != nil {
+		return err
+	}
+
+	return result
+}
+
+// traceError adds the position of node in the current function to the
+// trace of err.
+func (e *Evaluator) traceError(err *object.Error, node ast.Node) {
+	current := e.frames[len(e.frames)-1]
+	pos := node.Pos()
+
+	err.Trace = append(err.Trace, object.Frame{
+		Function: current.function,
+		File:     current.file,
+		Line:     pos.Line,
+		Column:   pos.Column,
+	})
+}
+
+func (e *Evaluator) pushFrame(function, file string) {
+	e.frames = append(e.frames, frame{function: function, file: file})
+
+	if e.hook != nil {
+		e.hook.Enter(function, file)
+	}
+}
+
+func (e *Evaluator) popFrame() {
+	e.frames = e.frames[:len(e.frames)-1]
+
+	if e.hook != nil {
+		e.hook.Leave()
+	}
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.FloatLiteral:
 		return &object.Float{Value: node.Value}
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return &object.String{Value: lexer.Unescape(node.Value)}
 	case *ast.RuneLiteral:
 		return &object.Rune{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := e.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+
+		return evalMemberExpression(obj, node.Property.Value)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node.Statements, env)
+		return e.evalBlockStatement(node.Statements, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
@@ -90,49 +216,84 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalPostfixExpression(node.Operator, env, ident)
 	case *ast.AssignExpression:
-		return evalAssignExpression(node, env)
+		return e.evalAssignExpression(node, env)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 
 		// store the value in the environment
 		env.Set(node.Name.Value, val)
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.ForExpression:
-		return evalForExpression(node, env)
+		return e.evalForExpression(node, env)
+	case *ast.ForInExpression:
+		return e.evalForInExpression(node, env)
+	case *ast.WhileExpression:
+		return e.evalWhileExpression(node, env)
+	case *ast.BreakStatement:
+		return &object.Break{Label: labelName(node.Label)}
+	case *ast.ContinueStatement:
+		return &object.Continue{Label: labelName(node.Label)}
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+	case *ast.BadExpression, *ast.BadStatement:
+		return newError(object.TYPE_ERROR, "cannot evaluate a syntax error")
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		file := e.frames[len(e.frames)-1].file
+
+		markTailCalls(body)
+
+		return &object.Function{Name: node.Name, File: file, Parameters: params, Env: env, Body: body}
 	case *ast.CallExpression:
 		// quote is a special form, so we handle it here
 		if node.Function.TokenLiteral() == "quote" {
-			return quote(node.Arguments[0], env)
+			return e.quote(node.Arguments[0], env)
 		}
 
-		function := Eval(node.Function, env)
+		function := e.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		// wrong calls are made here, so their errors point at them
+		if fn, ok := function.(*object.Function); ok && node.Tail && len(args) == len(fn.Parameters) {
+			return &tailCall{function: fn, args: args}
+		}
+
+		result := e.applyFunction(function, args)
+		if err, ok := result.(*object.Error); ok {
+			// point at the name of the function being called
+			var site ast.Node = node.Function
+			if member, ok := site.(*ast.MemberExpression); ok {
+				site = member.Property
+			}
+			e.traceError(err, site)
+		}
+
+		return result
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 
 	}
 	return NULL
 }
 
-func evalAssignExpression(exp *ast.AssignExpression, env *object.Environment) object.Object {
-	val := Eval(exp.Value, env)
+func (e *Evaluator) evalAssignExpression(exp *ast.AssignExpression, env *object.Environment) object.Object {
+	val := e.Eval(exp.Value, env)
 	if isError(val) {
 		return val
 	}
@@ -141,97 +302,182 @@ func evalAssignExpression(exp *ast.AssignExpression, env *object.Environment) ob
 	case *ast.Identifier:
 		_, ok := env.SetOnFound(left.Value, val) // this sets in the first found scope else returns error
 		if !ok {
-			return newError("identifier not found: " + left.Value)
+			return newError(object.NAME_ERROR, "identifier not found: "+left.Value)
 		}
 		return NULL
 	case *ast.IndexExpression:
-		structure := Eval(left.Left, env)
+		structure := e.Eval(left.Left, env)
 		if isError(structure) {
 			return structure
 		}
 
-		index := Eval(left.Index, env)
+		index := e.Eval(left.Index, env)
 		if isError(index) {
 			return index
 		}
 
-		return evalIndexAssignExpression(structure, index, val)
+		return e.evalIndexAssignExpression(structure, index, val)
+	case *ast.MemberExpression:
+		structure := e.Eval(left.Object, env)
+		if isError(structure) {
+			return structure
+		}
+
+		if module, ok := structure.(*object.Module); ok {
+			return newError(object.TYPE_ERROR, "cannot assign to %s.%s, module members are read-only",
+				module.Name, left.Property.Value)
+		}
+
+		return e.evalIndexAssignExpression(structure, &object.String{Value: left.Property.Value}, val)
 	}
 
 	return val
 }
 
-func evalIndexAssignExpression(structure object.Object, index, val object.Object) object.Object {
+func (e *Evaluator) evalIndexAssignExpression(structure object.Object, index, val object.Object) object.Object {
 	switch structure := structure.(type) {
 	case *object.Array:
 		idx, ok := index.(*object.Integer)
 		if !ok {
-			return newError("index must be an integer")
+			return newError(object.TYPE_ERROR, "index must be an integer")
 		}
 
 		if idx.Value < 0 || idx.Value > int64(len(structure.Elements)-1) {
-			return newError("index out of bounds")
+			return newError(object.INDEX_ERROR, "index out of bounds")
 		}
 
 		structure.Elements[idx.Value] = val
 		return NULL
 	case *object.Hash:
-		key, ok := index.(object.Hashable)
+		key, ok := object.Key(index)
 		if !ok {
-			return newError("unusable as hash key: %s", val.Type())
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 		}
 
-		hashed := key.HashKey()
-		structure.Pairs[hashed] = object.HashPair{Key: index, Value: val}
+		structure.Set(key, val)
+
+		if e.limiter != nil {
+			if err := e.limiter.size(structure); err != nil {
+				return err
+			}
+		}
 		return NULL
 	default:
-		return newError("index operator not supported: %s", structure.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", structure.Type())
 	}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash(len(node.Keys))
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, keyNode := range node.Keys {
+		key := e.Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.Key(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d",
+				len(fn.Parameters), len(args))
+		}
+
+		if e.limiter != nil {
+			if err := e.limiter.call(len(e.frames) - 1); err != nil {
+				return err
+			}
+		}
+
+		e.pushFrame(functionName(fn), fn.File)
+		defer e.popFrame()
+
+		for {
+			evaluated := unwrapReturnValue(e.Eval(fn.Body, extendFunctionEnv(fn, args)))
+
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return evaluated
+			}
+
+			// the function called in tail position takes over the frame
+			fn, args = call.function, call.args
+			e.popFrame()
+			e.pushFrame(functionName(fn), fn.File)
+		}
 	case *object.Builtin:
-		return fn.Fn(args...)
+		result := fn.Fn(args...)
+
+		// builtins like push grow their arguments
+		if e.limiter != nil && len(args) > 0 {
+			if err := e.limiter.size(args[0]); err != nil {
+				return err
+			}
+		}
+
+		if result != nil {
+			return result
+		}
+		return NULL
 	}
 
-	return newError("not a function: %s", fn.Type())
+	if e.foreign != nil {
+		return e.foreign(fn, args)
+	}
+
+	return newError(object.TYPE_ERROR, "not a function: %s", fn.Type())
+}
+
+// ForeignApply calls the functions of another engine, like the closures of
+// the virtual machine, that its programs give to the builtins of an
+// Evaluator.
+type ForeignApply func(fn object.Object, args []object.Object) object.Object
+
+// SetForeignApply makes the builtins of e call the functions they cannot
+// apply themselves with apply.
+func (e *Evaluator) SetForeignApply(apply ForeignApply) {
+	e.foreign = apply
+}
+
+// Apply calls fn, a function or a builtin, with args, the way a call
+// expression of the program does.
+func (e *Evaluator) Apply(fn object.Object, args []object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 
-	idx := index.(*object.Integer).Value
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "index must be an integer")
+	}
+
+	idx := integer.Value
 	max := int64(len(arrayObject.Elements) - 1)
 
 	// if index is -n, return the nth element from the end
@@ -240,26 +486,50 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	}
 
 	if idx < 0 || idx > max {
-		return newError("index out of range: %d", idx)
+		return newError(object.INDEX_ERROR, "index out of range: %d", idx)
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression returns the rune at index, counting runes, not
+// bytes.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "index must be an integer")
+	}
+
+	runes := []rune(str.(*object.String).Value)
+	idx := integer.Value
+	max := int64(len(runes) - 1)
+
+	// if index is -n, return the nth rune from the end
+	if idx < 0 {
+		idx = max + idx + 1
+	}
+
+	if idx < 0 || idx > max {
+		return newError(object.INDEX_ERROR, "index out of range: %d", idx)
+	}
+
+	return &object.Rune{Value: runes[idx]}
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.Key(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
@@ -269,9 +539,30 @@ func evalIndexExpression(left, index object.Object) object.Object {
 		return evalArrayIndexExpression(left, index)
 	case structure == object.HASH:
 		return evalHashIndexExpression(left, index)
+	case structure == object.STRING:
+		return evalStringIndexExpression(left, index)
+	case structure == object.MODULE && index.Type() == object.STRING:
+		return evalMemberExpression(left, index.(*object.String).Value)
 	}
 
-	return newError("index operator not supported: %s", structure)
+	return newError(object.TYPE_ERROR, "index operator not supported: %s", structure)
+}
+
+// evalMemberExpression looks up name in a module, or the string key name in
+// a hash, so h.name is the same as h["name"].
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		member, ok := obj.Member(name)
+		if !ok {
+			return newError(object.NAME_ERROR, "module %s has no member %s", obj.Name, name)
+		}
+		return member
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	default:
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", obj.Type())
+	}
 }
 
 func extendFunctionEnv(
@@ -295,14 +586,14 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalExpressions(
+func (e *Evaluator) evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
 ) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -315,7 +606,7 @@ func evalExpressions(
 func evalPostfixExpression(operator string, env *object.Environment, ident string) object.Object {
 	val, ok := env.Get(ident)
 	if !ok {
-		return newError("identifier not found: " + ident)
+		return newError(object.NAME_ERROR, "identifier not found: "+ident)
 	}
 
 	switch operator {
@@ -328,7 +619,7 @@ func evalPostfixExpression(operator string, env *object.Environment, ident strin
 			val := val.(*object.Float)
 			val.Value++
 		default:
-			return newError("unknown operator: %s%s", operator, val.Type())
+			return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, val.Type())
 		}
 	case "--":
 		switch val.Type() {
@@ -339,39 +630,44 @@ func evalPostfixExpression(operator string, env *object.Environment, ident strin
 			val := val.(*object.Float)
 			val.Value--
 		default:
-			return newError("unknown operator: %s%s", operator, val.Type())
+			return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, val.Type())
 		}
 	default:
-		return newError("unknown operator: %s%s", operator, val.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, val.Type())
 	}
 
 	env.Set(ident, val)
 	return val
 }
 
-func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
 	var bodyResult object.Object
 
 	enclosedEnv := object.NewEnclosedEnvironment(env)
 
-	startResult := Eval(fe.Init, enclosedEnv)
+	startResult := e.Eval(fe.Init, enclosedEnv)
 	if isError(startResult) {
 		return startResult
 	}
 
 	bodyResult = NULL
 
-	for isTruthy(Eval(fe.Condition, enclosedEnv)) {
-		bodyResult = Eval(fe.Body, enclosedEnv)
-		if isError(bodyResult) {
-			return bodyResult
+	for {
+		condition := e.Eval(fe.Condition, enclosedEnv)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			break
 		}
 
-		if bodyResult.Type() == object.RETURN_VALUE {
+		var more bool
+		if bodyResult, more = e.evalLoopBody(fe.Body, fe.Label, enclosedEnv); !more {
 			return bodyResult
 		}
 
-		postResult := Eval(fe.Post, enclosedEnv)
+		postResult := e.Eval(fe.Post, enclosedEnv)
 		if isError(postResult) {
 			return postResult
 		}
@@ -380,31 +676,31 @@ func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Ob
 	return bodyResult
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	val, ok := env.Get(node.Value)
 	if ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: "+node.Value)
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	enclosedEnv := object.NewEnclosedEnvironment(env)
 
-	condition := Eval(ie.Condition, enclosedEnv)
+	condition := e.Eval(ie.Condition, enclosedEnv)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, enclosedEnv)
+		return e.Eval(ie.Consequence, enclosedEnv)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, enclosedEnv)
+		return e.Eval(ie.Alternative, enclosedEnv)
 	} else {
 		return NULL
 	}
@@ -440,10 +736,10 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s",
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -457,8 +753,24 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return &object.Integer{Value: leftVal - rightVal}
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
+		if operator == "%" {
+			// the remainder has the sign of the dividend, like in Go
+			return &object.Integer{Value: leftVal % rightVal}
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError(object.ARGUMENT_ERROR, "negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		result, ok := object.IntPow(leftVal, rightVal)
+		if !ok {
+			return newError(object.OVERFLOW_ERROR, "integer overflow: %d ** %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: result}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -468,7 +780,7 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -482,8 +794,16 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 		return &object.Float{Value: leftVal - rightVal}
 	case "*":
 		return &object.Float{Value: leftVal * rightVal}
-	case "/":
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
+		if operator == "%" {
+			return &object.Float{Value: math.Mod(leftVal, rightVal)}
+		}
 		return &object.Float{Value: leftVal / rightVal}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -493,7 +813,7 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -504,8 +824,17 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	switch operator {
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
-	return NULL
 }
 
 func evalRuneInfixExpression(operator string, left, right object.Object) object.Object {
@@ -517,8 +846,17 @@ func evalRuneInfixExpression(operator string, left, right object.Object) object.
 		return &object.Rune{Value: leftVal + rightVal}
 	case "-":
 		return &object.Rune{Value: leftVal - rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
-	return NULL
 }
 
 func evalPrefixExpression(operator string, right object.Object) object.Object {
@@ -528,7 +866,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -539,7 +877,7 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	case *object.Float:
 		return &object.Float{Value: -right.Value}
 	default:
-		return newError("unknown operator: -%s", right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
 }
 
@@ -555,13 +893,17 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	return FALSE
 }
 
-func evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		if e.hook != nil {
+			e.hook.Statement(statement, env)
+		}
+
+		result = e.Eval(statement, env)
 
-		if result != nil && result.Type() == object.RETURN_VALUE || result.Type() == object.ERROR {
+		if leavesBlock(result) {
 			return result
 		}
 	}
@@ -569,11 +911,15 @@ func evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.O
 	return result
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		if e.hook != nil {
+			e.hook.Statement(statement, env)
+		}
+
+		result = e.Eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -586,6 +932,21 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
+// leavesBlock reports whether obj ends the evaluation of the blocks around
+// it: returned values, errors, and the signals of break and continue.
+func leavesBlock(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.RETURN_VALUE, object.ERROR, object.BREAK, object.CONTINUE:
+		return true
+	default:
+		return false
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
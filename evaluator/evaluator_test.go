@@ -1,207 +1,496 @@
 package evaluator
 
 import (
+	"compiler-book/evaluator/evaltest"
 	"compiler-book/lexer"
 	"compiler-book/object"
 	"compiler-book/parser"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
+	for _, tt := range evaltest.Integers {
+		evaluated := testEval(tt.Input)
+		testIntegerObject(t, evaluated, tt.Expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	for _, tt := range evaltest.Floats {
+		evaluated := testEval(tt.Input)
+		testFloatObject(t, evaluated, tt.Expected)
+	}
+}
+
+func TestEvalStringExpression(t *testing.T) {
+	for _, tt := range evaltest.Strings {
+		evaluated := testEval(tt.Input)
+		testStringObject(t, evaluated, tt.Expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	for _, tt := range evaltest.Lets {
+		testIntegerObject(t, testEval(tt.Input), tt.Expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	for _, tt := range evaltest.Errors {
+		evaluated := testEval(tt.Input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.Expected {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.Expected, errObj.Message)
+		}
+	}
+}
+
+func TestErrorTraces(t *testing.T) {
+	input := `let check = fn(x) {
+  x + true
+};
+let run = fn() {
+  let twice = fn(y) { check(y) * 2 };
+  twice(1) * 2;
+};
+run()`
+
+	evaluated := testEval(input)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "check", Line: 2, Column: 5},
+		{Function: "twice", Line: 5, Column: 23},
+		{Function: "run", Line: 6, Column: 3},
+		{Function: "<main>", Line: 8, Column: 1},
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%+v", len(expected), errObj.Trace)
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i] != frame {
+			t.Errorf("frame %d wrong. want=%+v, got=%+v", i, frame, errObj.Trace[i])
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected int64
 	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"5 + 5 + 5 + 5 - 10", 10},
-		{"2 * 2 * 2 * 2 * 2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{`let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(100000)`, 0},
+		{`let count = fn(n) { if (n == 0) { return 0; } return count(n - 1); }; count(100000)`, 0},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		  if (even(100001)) { 1 } else { 2 }`, 2},
+		{`let find = fn(n) { for (let i = 0; i < 10; i++) { if (i == n) { return find(n + 1); } } n }; find(0)`, 10},
+		{`let reduce = fn(arr, i, acc, f) {
+		    if (i == len(arr)) { return acc; }
+		    reduce(arr, i + 1, f(acc, arr[i]), f)
+		  };
+		  let arr = [];
+		  for (let i = 0; i < 1000000; i++) { push(arr, 1); }
+		  reduce(arr, 0, 0, fn(acc, x) { acc + x })`, 1000000},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
+		testIntegerObject(t, testEval(tt.input), tt.expected)
 	}
 }
 
-func TestEvalFloatExpression(t *testing.T) {
+func TestDeepRecursion(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected float64
+		expected interface{}
 	}{
-		{"10.123456", 10.123456},
-		{"1.", 1.},
-		{"-1.0", -1.0},
+		{`let count = fn(n) { if (n == 0) { return 0 } 1 + count(n - 1) }; count(100000)`, 100000},
+		{`let f = fn(n) { if (n == 0) { return 0 } {"a": {"a": {"a": {"a": f(n - 1)}}}} }; f(1000000)`, "stack overflow"},
+		{`let f = fn(n) { if (n == 0) { return 0 } map([1], fn(x) { f(n - 1) })[0] }; f(1000000)`, "stack overflow"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		testFloatObject(t, evaluated, tt.expected)
+		if message, ok := tt.expected.(string); ok {
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Kind != object.STACK_OVERFLOW || errObj.Message != message {
+				t.Errorf("%s: wrong result. got=%s", tt.input, evaluated.Inspect())
+			}
+			continue
+		}
+		testIntegerObject(t, evaluated, int64(tt.expected.(int)))
 	}
 }
 
-func TestEvalStringExpression(t *testing.T) {
+func TestTailCallTraces(t *testing.T) {
+	input := `let check = fn(x) {
+  x + true
+};
+let run = fn() {
+  check(1)
+};
+run() * 2`
+
+	evaluated := testEval(input)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	// check took over the frame of run
+	expected := []object.Frame{
+		{Function: "check", Line: 2, Column: 5},
+		{Function: "<main>", Line: 7, Column: 1},
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%+v", len(expected), errObj.Trace)
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i] != frame {
+			t.Errorf("frame %d wrong. want=%+v, got=%+v", i, frame, errObj.Trace[i])
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected string
+		input   string
+		limits  Limits
+		message string
 	}{
-		{`"Hello World!"`, "Hello World!"},
-		{`"Hello\nWorld!"`, "Hello\nWorld!"},
-		{`"Hello\tWorld!"`, "Hello\tWorld!"},
-		{`"Hello\rWorld!"`, "Hello\rWorld!"},
-		{`"Hello\"World!"`, "Hello\"World!"},
-		{`"Hello\\World!"`, "Hello\\World!"},
-		{`"Hello😀World!"`, "Hello😀World!"},
-		{`"Hello🐶World!"`, "Hello🐶World!"},
+		{"for (let i = 0; true; i++) {}", Limits{Steps: 1000}, "step limit of 1000 exceeded"},
+		{"let f = fn(n) { f(n + 1) * 2 }; f(0)", Limits{Depth: 100}, "call depth limit of 100 exceeded"},
+		{"let a = []; for (let i = 0; true; i++) { push(a, i) }", Limits{Size: 10},
+			"size limit of 10 exceeded: ARRAY of size 11"},
+		{`let h = {}; for (let i = 0; true; i++) { h[i] = i }`, Limits{Size: 10},
+			"size limit of 10 exceeded: HASH of size 11"},
+		{`let s = "a"; for (let i = 0; true; i++) { s = s + s }`, Limits{Size: 100},
+			"size limit of 100 exceeded: STRING of size 128"},
+		{"[1, 2, 3, 4]", Limits{Size: 3}, "size limit of 3 exceeded: ARRAY of size 4"},
+		{`repeat("ab", 200000000)`, Limits{Size: 1000}, "size limit of 1000 exceeded: STRING of size 400000000"},
+		{`let a = range(1000); concat(a, a)`, Limits{Size: 1000}, "size limit of 1000 exceeded: ARRAY of size 2000"},
+		{`let a = split(repeat("a", 1000), ""); join(a, "bb")`, Limits{Size: 1000},
+			"size limit of 1000 exceeded: STRING of size 2998"},
+		{`replace(repeat("a", 1000), "a", "bb")`, Limits{Size: 1000}, "size limit of 1000 exceeded: STRING of size 2000"},
+		{"try { for (let i = 0; true; i++) {} } catch (e) { 1 }", Limits{Steps: 1000}, "step limit of 1000 exceeded"},
+		{"try { 1 } finally { for (let i = 0; true; i++) {} }", Limits{Steps: 1000}, "step limit of 1000 exceeded"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testStringObject(t, evaluated, tt.expected)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New().EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != object.LIMIT_ERROR || errObj.Message != tt.message {
+			t.Errorf("%q: wrong error. want=%s %q, got=%s %q",
+				tt.input, object.LIMIT_ERROR, tt.message, errObj.Kind, errObj.Message)
+		}
 	}
 }
 
-func TestLetStatements(t *testing.T) {
+func TestLimitsAllowTheProgramsWithin(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10)`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	limits := Limits{Steps: 10000, Depth: 11, Size: 10}
+	evaluated := New().EvalContext(context.Background(), program, object.NewEnvironment(), limits)
+
+	testIntegerObject(t, evaluated, 10)
+}
+
+func TestDeadline(t *testing.T) {
+	program := parser.New(lexer.New("for (let i = 0; true; i++) {}")).ParseProgram()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated := New().EvalContext(ctx, program, object.NewEnvironment(), Limits{})
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := "evaluation stopped: context deadline exceeded"
+	if errObj.Kind != object.LIMIT_ERROR || errObj.Message != expected {
+		t.Errorf("wrong error. want=%q, got=%s %q", expected, errObj.Kind, errObj.Message)
+	}
+}
+
+func TestBuiltinsArePerEvaluator(t *testing.T) {
+	program := parser.New(lexer.New(`print("a", 1); printf("%d!", 2); shout("b")`)).ParseProgram()
+
+	var out strings.Builder
+	e := New()
+	e.SetStreams(Streams{Stdout: &out})
+	e.DefineBuiltin("shout", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return object.Print(e.Streams().Stdout)(&object.String{Value: strings.ToUpper(args[0].Inspect())})
+	}})
+
+	if result := e.Eval(program, object.NewEnvironment()); isError(result) {
+		t.Fatalf("unexpected error: %s", result.Inspect())
+	}
+
+	if out.String() != "a 1 \n2!\nB \n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	// another evaluator does not have shout, and one without print cannot
+	// print
+	builtins := New().Builtins()
+	if _, ok := builtins["shout"]; ok {
+		t.Errorf("shout is defined in a new evaluator")
+	}
+
+	delete(builtins, "print")
+	sandboxed := New()
+	sandboxed.SetBuiltins(builtins)
+
+	program = parser.New(lexer.New(`print("a")`)).ParseProgram()
+	errObj, ok := sandboxed.Eval(program, object.NewEnvironment()).(*object.Error)
+	if !ok || errObj.Message != "identifier not found: print" {
+		t.Errorf("print is still defined. got=%+v", errObj)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	input := `
+	let lines = [];
+	let line = readLine();
+	while (line) { push(lines, line); line = readLine() };
+	eprint(len(lines), "lines");
+	print(lines);
+	readLine(1)`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	var out, errs strings.Builder
+	e := New()
+	e.SetStreams(Streams{Stdin: strings.NewReader("one\r\n\ntwo"), Stdout: &out, Stderr: &errs})
+
+	errObj, ok := e.Eval(program, object.NewEnvironment()).(*object.Error)
+	if !ok || errObj.Message != "wrong number of arguments. got=1, want=0" {
+		t.Errorf("wrong result. got=%+v", errObj)
+	}
+
+	if out.String() != "[one, , two] \n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+	if errs.String() != "3 lines \n" {
+		t.Errorf("wrong error output. got=%q", errs.String())
+	}
+}
+
+func TestSystemBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	secret := filepath.Join(dir, "secret")
+	os.Mkdir(data, 0o755)
+	os.Mkdir(secret, 0o755)
+	os.WriteFile(filepath.Join(data, "in.txt"), []byte("input"), 0o644)
+	os.WriteFile(filepath.Join(secret, "key"), []byte("key"), 0o644)
+	os.Symlink(secret, filepath.Join(data, "link"))
+	t.Setenv("SLANG_TEST", "set")
+
+	e := New()
+	e.SetArgs([]string{"a", "b"})
+	e.SetPermissions(Permissions{Read: []string{data}, Write: []string{data}, Env: []string{"SLANG_TEST", "SLANG_UNSET"}})
+
 	tests := []struct {
 		input    string
-		expected int64
+		expected string // the value inspected
 	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{`readFile("data/in.txt")`, "input"},
+		{`writeFile("data/out.txt", "a"); appendFile("data/out.txt", "b"); readFile("data/out.txt")`, "ab"},
+		{`writeFile("data/out.txt", "c"); readFile("data/out.txt")`, "c"},
+		{`listDir("data")`, "[in.txt, link, out.txt]"},
+		{`[exists("data/in.txt"), exists("data/none")]`, "[true, false]"},
+		{`readFile("data/none")`, "ERROR: open data/none: no such file or directory"},
+		{`try { readFile("data/none") } catch (e) { e.kind }`, "IOError"},
+		{`readFile("secret/key")`, "ERROR: read access to secret/key is not allowed"},
+		{`readFile("data/../secret/key")`, "ERROR: read access to data/../secret/key is not allowed"},
+		{`readFile("data/link/key")`, "ERROR: read access to data/link/key is not allowed"},
+		{`writeFile("secret/new", "x")`, "ERROR: write access to secret/new is not allowed"},
+		{`try { exists("/") } catch (e) { e.kind }`, "PermissionError"},
+		{`env("SLANG_TEST")`, "set"},
+		{`env("SLANG_UNSET")`, "null"},
+		{`env("HOME")`, "ERROR: access to the environment variable HOME is not allowed"},
+		{`args()`, "[a, b]"},
+		{`readFile(1)`, "ERROR: argument 1 to `readFile` must be STRING, got INTEGER"},
 	}
 
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := e.Eval(program, object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// a new evaluator is not allowed anything
+	program := parser.New(lexer.New(`readFile("data/in.txt")`)).ParseProgram()
+	if result := New().Eval(program, object.NewEnvironment()); result.Inspect() != "ERROR: read access to data/in.txt is not allowed" {
+		t.Errorf("a new evaluator can read files. got=%s", result.Inspect())
+	}
+
+	e.SetPermissions(AllPermissions())
+	program = parser.New(lexer.New(`readFile("secret/key")`)).ParseProgram()
+	if result := e.Eval(program, object.NewEnvironment()); result.Inspect() != "key" {
+		t.Errorf("all permissions do not allow reading. got=%s", result.Inspect())
 	}
 }
 
-func TestErrorHandling(t *testing.T) {
+func TestExit(t *testing.T) {
 	tests := []struct {
-		input           string
-		expectedMessage string
+		input  string
+		status int
 	}{
-		{
-			"5 + true;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"-true",
-			"unknown operator: -BOOLEAN",
-		},
-		{
-			"true + false;",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"5; true + false; 5",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"if (10 > 1) { true + false; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"foobar",
-			"identifier not found: foobar",
-		},
-		{
-			`{"name": "Monkey"}[fn(x) { x }];`,
-			"unusable as hash key: FUNCTION",
-		},
-		{
-			`
-if (10 > 1) {
-  if (10 > 1) {
-    return true + false;
-  }
-
-  return 1;
-}
-`,
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
+		{"exit()", 0},
+		{"exit(3); 1", 3},
+		{"let f = fn() { exit(2) }; try { f() } catch (e) { 1 } finally { return 4 }", 2},
+		{"map([1], fn(x) { exit(x) })", 1},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)",
-				evaluated, evaluated)
-			continue
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok || err.Kind != object.EXIT || err.Status != tt.status {
+			t.Errorf("%s: wrong result. want exit status %d, got=%+v", tt.input, tt.status, err)
 		}
+	}
+}
 
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q",
-				tt.expectedMessage, errObj.Message)
-		}
+func TestBuiltinErrorsAreTracedToTheirCall(t *testing.T) {
+	evaluated := testEval("let a = 1;\nlet b = len(a);")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := object.Frame{Function: "<main>", Line: 2, Column: 9}
+	if len(errObj.Trace) != 1 || errObj.Trace[0] != expected {
+		t.Errorf("wrong trace. want=[%+v], got=%+v", expected, errObj.Trace)
 	}
 }
 
-func TestEvalBooleanExpression(t *testing.T) {
+func TestTryCatch(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected bool
+		expected interface{}
 	}{
-		{"true", true},
-		{"false", false},
-		{"true", true},
-		{"false", false},
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { [1][5] } catch (e) { 2 }`, 2},
+		{`try { [1][5] } catch (e) { e["message"] }`, "index out of range: 5"},
+		{`try { [1][5] } catch (e) { e["kind"] }`, "IndexError"},
+		{`try { x } catch (e) { e["kind"] }`, "NameError"},
+		{`try { 1 + true } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { len(1, 2) } catch (e) { e["kind"] }`, "ArgumentError"},
+		{`try { throw "boom" } catch (e) { e["message"] + e["kind"] }`, "boomError"},
+		{`try { throw {"message": "bad", "kind": "MyError"} } catch (e) { e["kind"] }`, "MyError"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e["message"] }`, "inner"},
+		{`let f = fn() { throw "deep" }; try { f() } catch (e) { len(e["trace"]) }`, 2},
+		{`let f = fn() { throw "deep" }; try { f() } catch (e) { e["trace"][0]["function"] }`, "f"},
+		{`let f = fn() { throw "deep" }; try { f() } catch (e) { e["trace"][0]["column"] }`, 16},
+		{`let a = 0; try { 1 } finally { a = 5 }; a`, 5},
+		{`let a = 0; try { throw "x" } catch (e) { a = 1 } finally { a = a + 10 }; a`, 11},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`try { 1 } catch (e) { 2 }; e`, "identifier not found: e"},
+		{`try { throw "a" } finally { 1 }`, "a"},
+		{`try { throw "a" } catch (e) { throw "b" }`, "b"},
+		{`try { 1 } finally { throw "c" }`, "c"},
+		{`throw "uncaught"`, "uncaught"},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch evaluated := evaluated.(type) {
+			case *object.Error:
+				testErrorObject(t, evaluated, expected)
+			default:
+				testStringObject(t, evaluated, expected)
+			}
+		}
 	}
 }
 
-func TestIfElseExpressions(t *testing.T) {
+func TestThrownErrorKinds(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected interface{}
+		expected object.ErrorKind
 	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{`throw "boom"`, object.USER_ERROR},
+		{`throw {"kind": "Custom"}`, "Custom"},
+		{`foo`, object.NAME_ERROR},
+		{`[1, 2][2]`, object.INDEX_ERROR},
+		{`"a" - "b" - 1`, object.TYPE_ERROR},
+		{`1()`, object.TYPE_ERROR},
+		{`fn(x) { x }()`, object.ARGUMENT_ERROR},
+		{`10 ** 19`, object.OVERFLOW_ERROR},
 	}
 
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Kind != tt.expected {
+			t.Errorf("%s: wrong kind. want=%s, got=%s", tt.input, tt.expected, errObj.Kind)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	for _, tt := range evaltest.Booleans {
+		evaluated := testEval(tt.Input)
+		testBooleanObject(t, evaluated, tt.Expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	for _, tt := range evaltest.IfElse {
+		evaluated := testEval(tt.Input)
+		integer, ok := tt.Expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
 		} else {
@@ -211,31 +500,9 @@ func TestIfElseExpressions(t *testing.T) {
 }
 
 func TestReturnStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5; 9;", 10},
-		{
-			`
-if (10 > 1) {
-  if (10 > 1) {
-    return 10;
-  }
-
-  return 1;
-}
-`,
-			10,
-		},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
+	for _, tt := range evaltest.Returns {
+		evaluated := testEval(tt.Input)
+		testIntegerObject(t, evaluated, tt.Expected)
 	}
 }
 
@@ -248,51 +515,50 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 }
 
 func TestEvalRuneExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected rune
-	}{
-		{"'🐶'", '🐶'},
+	for _, tt := range evaltest.Runes {
+		evaluated := testEval(tt.Input)
+		testRuneObject(t, evaluated, tt.Expected)
 	}
+}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testRuneObject(t, evaluated, tt.expected)
+func TestBangOperator(t *testing.T) {
+	for _, tt := range evaltest.Bangs {
+		evaluated := testEval(tt.Input)
+		testBooleanObject(t, evaluated, tt.Expected)
 	}
 }
 
-func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
+func TestEvalForExpressions(t *testing.T) {
+	for _, tt := range evaltest.ForLoops {
+		evaluated := testEval(tt.Input)
+		testIntegerObject(t, evaluated, tt.Expected)
 	}
+}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+func TestForInLoops(t *testing.T) {
+	for _, tt := range evaltest.ForInLoops {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
 	}
 }
 
-func TestEvalForExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"for (let i = 0; i < 10; i++) { i }", 10},
-		{"for (let i = 10; i > 0; i--) { i }", 0},
-		{"for (let i = 10; i > 0; i--) { if (i < 5) { return 1000 } return 90 }", 90},
+func TestBreakAndContinue(t *testing.T) {
+	for _, tt := range evaltest.BreakAndContinue {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
 	}
+}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
+func TestWhileExpressions(t *testing.T) {
+	for _, tt := range evaltest.WhileLoops {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -322,39 +588,16 @@ func TestFunctionObject(t *testing.T) {
 }
 
 func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
-	}
-
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+	for _, tt := range evaltest.FunctionApplications {
+		testIntegerObject(t, testEval(tt.Input), tt.Expected)
 	}
 }
 
 func TestBuiltinFunctions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+	for _, tt := range evaltest.Builtins {
+		evaluated := testEval(tt.Input)
 
-		switch expected := tt.expected.(type) {
+		switch expected := tt.Expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
 		case string:
@@ -372,6 +615,91 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestStringBuiltins(t *testing.T) {
+	for _, tt := range evaltest.StringBuiltins {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArithmeticOperators(t *testing.T) {
+	for _, tt := range evaltest.Arithmetic {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	for _, tt := range evaltest.MathBuiltins {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestNumberConversions(t *testing.T) {
+	for _, tt := range evaltest.NumberConversions {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	for _, tt := range evaltest.CollectionBuiltins {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionBuiltinsCallBackUnderLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected string
+	}{
+		{"map(range(100), fn(x) { x })", Limits{Steps: 100}, "step limit of 100 exceeded"},
+		{"range(1000)", Limits{Size: 100}, "size limit of 100 exceeded: ARRAY of size 1000"},
+		{"let f = fn(x) { map([x], f) }; f(1)", Limits{Depth: 50}, "call depth limit of 50 exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New().EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Kind != object.LIMIT_ERROR || err.Message != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, tt := range evaltest.JSON {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringIndexesAndSlices(t *testing.T) {
+	for _, tt := range evaltest.StringIndexesAndSlices {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
     {
@@ -390,116 +718,283 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
-		(&object.Float{Value: 3.14}).HashKey():     7,
+	// in the order of the source
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
+		{&object.Float{Value: 3.14}, 7},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for i, pair := range result.Pairs() {
+		if pair.Key.Inspect() != expected[i].key.Inspect() {
+			t.Errorf("pair %d has the wrong key. want=%s, got=%s", i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+
+		value, ok := result.Get(expected[i].key)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, value, expected[i].value)
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	for _, tt := range evaltest.HashOrder {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	for _, tt := range evaltest.HashKeys {
+		evaluated := testEval(tt.Input)
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
 	}
 }
 
 func TestHashIndexExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{`{"foo": 5}["foo"]`, 5},
-		{`{"foo": 5}["bar"]`, nil},
-		{`let key = "foo"; {"foo": 5}[key]`, 5},
-		{`{}["foo"]`, nil},
-		{`{5: 5}[5]`, 5},
-		{`{true: 5}[true]`, 5},
-		{`{false: 5}[false]`, 5},
+	for _, tt := range evaltest.HashIndexes {
+		evaluated := testEval(tt.Input)
+
+		switch expected := tt.Expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		}
 	}
+}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+func TestMemberExpressions(t *testing.T) {
+	for _, tt := range evaltest.Members {
+		evaluated := testEval(tt.Input)
 
-		switch expected := tt.expected.(type) {
+		switch expected := tt.Expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
 		case nil:
 			testNullObject(t, evaluated)
 		}
 	}
 }
 
-func TestArrayIndexAssignmentExpressions(t *testing.T) {
+func TestImports(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.sl":       `let x = 40; let add = fn(a, b) { a + b };`,
+		"sub/a.sl":     `import "b.sl"; let value = b.value;`,
+		"sub/b.sl":     `let value = 7;`,
+		"shared.sl":    `import "lib.sl";`,
+		"cycle/a.sl":   `import "b.sl";`,
+		"cycle/b.sl":   `import "a.sl";`,
+		"self.sl":      `import "self.sl";`,
+		"broken.sl":    `let x = 1 + true;`,
+		"bad_parse.sl": `let = 1;`,
+	})
+
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{`let a = [1, 2, 3]; a[0] = 4; a[0]`, 4},
-		{`let a = [1, 2, 3]; a[0] = 4; a[1]`, 2},
-		{`let a = [1, 2, 3]; a[0] = 4; a[2]`, 3},
-		{`let a = [1, 2, 3]; a[0] = 4; a[3]`, "index out of range: 3"},
+		{`import "lib.sl"; lib.add(lib.x, 2)`, 42},
+		{`import "lib.sl" as l; l["x"]`, 40},
+		{`import "./lib.sl"; let f = fn() { lib.x }; f()`, 40},
+		{`import "sub/a.sl"; a.value`, 7},
+		{`import "lib.sl"; import "shared.sl"; shared.lib == lib`, true},
+		{`import "lib.sl"; lib.nope`, "module lib has no member nope"},
+		{`import "lib.sl"; lib.x = 1`, "cannot assign to lib.x, module members are read-only"},
+		{`import "cycle/a.sl"`, "import cycle: a.sl -> b.sl -> a.sl"},
+		{`import "self.sl"`, "import cycle: self.sl -> self.sl"},
+		{`import "main.sl"`, "import cycle: main.sl -> main.sl"},
+		{`import "broken.sl"`, "type mismatch: INTEGER + BOOLEAN"},
+		{`import "sub/a.sl"; a.b.value`, 7},
+		{`import "missing.sl"`, "cannot import missing.sl: "},
+		{`import "bad_parse.sl"`, "cannot import bad_parse.sl: "},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		e := New()
+		e.SetFilename(filepath.Join(dir, "main.sl"))
+		evaluated := e.Eval(program, object.NewEnvironment())
 
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
-		case nil:
-			testNullObject(t, evaluated)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			// messages ending in ': ' carry an OS or parser error after them
+			if !strings.HasPrefix(errObj.Message, expected) ||
+				!strings.HasSuffix(expected, ": ") && errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q",
+					tt.input, expected, errObj.Message)
+			}
 		}
 	}
 }
 
-func TestAssignmentIdentifiers(t *testing.T) {
+func TestImportErrorTraces(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.sl": "let fail = fn() {\n  1 + true\n};",
+	})
+
+	input := "import \"lib.sl\";\nlib.fail()"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	e := New()
+	e.SetFilename(filepath.Join(dir, "main.sl"))
+	evaluated := e.Eval(program, object.NewEnvironment())
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "fail", File: filepath.Join(dir, "lib.sl"), Line: 2, Column: 5},
+		{Function: "<main>", File: filepath.Join(dir, "main.sl"), Line: 2, Column: 5},
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%+v", len(expected), errObj.Trace)
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i] != frame {
+			t.Errorf("frame %d wrong. want=%+v, got=%+v", i, frame, errObj.Trace[i])
+		}
+	}
+}
+
+func TestModulesAreEvaluatedOnce(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"counter.sl": `let state = {"loads": 0}; state.loads = state.loads + 1;`,
+		"a.sl":       `import "counter.sl";`,
+	})
+
+	input := `import "a.sl"; import "counter.sl"; import "counter.sl" as again; again.state.loads`
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	e := New()
+	e.SetFilename(filepath.Join(dir, "main.sl"))
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 1)
+}
+
+// writeFiles creates files, keyed by their path relative to a temporary
+// directory, and returns the directory.
+func TestImportPermissions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"program/main.sl":  ``,
+		"program/lib.sl":   `let x = 1;`,
+		"secret/secret.sl": `let secret = "hunter2";`,
+	})
+	secret := filepath.Join(dir, "secret", "secret.sl")
+
 	tests := []struct {
-		input    string
-		expected interface{}
+		filename    string
+		permissions Permissions
+		input       string
+		expected    string // the value inspected
 	}{
-		{`let a = 5; a = 6; a`, 6},
-		{`let a = 5; let b = a; b`, 5},
-		{`let a = 5; let b = a; let c = a + b + 5; c`, 15},
-		{`let a = 5; let b = a; let c = a + b + 5; a = 10; c`, 15},
+		{"", Permissions{}, `import "` + secret + `"; secret.secret`,
+			"ERROR: read access to " + secret + " is not allowed"},
+		{"program/main.sl", Permissions{}, `import "lib.sl"; lib.x`, "1"},
+		{"program/main.sl", Permissions{}, `import "../secret/secret.sl"; secret.secret`,
+			"ERROR: read access to " + secret + " is not allowed"},
+		{"program/main.sl", Permissions{Read: []string{filepath.Join(dir, "secret")}},
+			`import "../secret/secret.sl"; secret.secret`, "hunter2"},
+		{"", Permissions{Read: []string{Everything}}, `import "` + secret + `"; secret.secret`, "hunter2"},
+		{"program/main.sl", Permissions{Import: []string{Everything}},
+			`import "../secret/secret.sl"; secret.secret`, "hunter2"},
+		{"program/main.sl", Permissions{Import: []string{Everything}},
+			`import "../secret/secret.sl"; readFile("` + secret + `")`,
+			"ERROR: read access to " + secret + " is not allowed"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		e := New()
+		if tt.filename != "" {
+			e.SetFilename(filepath.Join(dir, tt.filename))
+		}
+		e.SetPermissions(tt.permissions)
 
-		switch expected := tt.expected.(type) {
+		evaluated := e.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong value. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestArrayIndexAssignmentExpressions(t *testing.T) {
+	for _, tt := range evaltest.ArrayIndexAssignments {
+		evaluated := testEval(tt.Input)
+
+		switch expected := tt.Expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
 		}
 	}
 }
 
-func TestHashIndexAssignmentExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{`let h = {}; h["foo"] = 5; h["foo"]`, 5},
-		{`let h = {}; h["foo"] = 5; h["bar"]`, nil},
-		{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["bar"]`, 6},
-		{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["foo"]`, 5},
-		{`let h = {}; h["foo"] = 5; h["bar"] = 6; h["baz"] = 7; h["baz"]`, 7},
+func TestAssignmentIdentifiers(t *testing.T) {
+	for _, tt := range evaltest.AssignmentIdentifiers {
+		evaluated := testEval(tt.Input)
+
+		switch expected := tt.Expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		}
 	}
+}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+func TestHashIndexAssignmentExpressions(t *testing.T) {
+	for _, tt := range evaltest.HashIndexAssignments {
+		evaluated := testEval(tt.Input)
 
-		switch expected := tt.expected.(type) {
+		switch expected := tt.Expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
 		case nil:
@@ -528,62 +1023,16 @@ func TestArrayLiterals(t *testing.T) {
 }
 
 func TestArrayIndexExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{
-			"[1, 2, 3][0]",
-			1,
-		},
-		{
-			"[1, 2, 3][1]",
-			2,
-		},
-		{
-			"[1, 2, 3][2]",
-			3,
-		},
-		{
-			"let i = 0; [1][i];",
-			1,
-		},
-		{
-			"[1, 2, 3][1 + 1];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[2];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
-			6,
-		},
-		{
-			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
-			2,
-		},
-		{
-			"[1, 2, 3][3]",
-			"index out of range: 3",
-		},
-		{
-			"[1, 2, 3][-1]",
-			3,
-		},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
+	for _, tt := range evaltest.ArrayIndexes {
+		evaluated := testEval(tt.Input)
+		integer, ok := tt.Expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
 		} else {
-			message, ok := tt.expected.(string)
+			message, ok := tt.Expected.(string)
 			if !ok {
 				t.Fatalf("test case expected is not string. got=%T (%+v)",
-					tt.expected, tt.expected)
+					tt.Expected, tt.Expected)
 			}
 			testErrorObject(t, evaluated, message)
 		}
@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+)
+
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.Eval(te.Body, object.NewEnclosedEnvironment(env))
+
+	// the program must stop, whatever it says
+	if uncatchable(result) {
+		return result
+	}
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(te.Parameter.Value, errorToHash(err))
+
+		result = e.Eval(te.Catch, catchEnv)
+		if uncatchable(result) {
+			return result
+		}
+	}
+
+	if te.Finally != nil {
+		// like in most languages, leaving a finally block early overrides
+		// the outcome of the try and catch blocks
+		finally := e.Eval(te.Finally, object.NewEnclosedEnvironment(env))
+		if leavesBlock(finally) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalThrowStatement(ts *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.Eval(ts.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return valueToError(val)
+}
+
+// valueToError turns a thrown value into an error. Strings become the
+// message, hashes may set the message and kind, like the ones caught by a
+// catch block, so caught errors can be thrown again.
+func valueToError(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.String:
+		return newError(object.USER_ERROR, "%s", val.Value)
+	case *object.Hash:
+		err := newError(object.USER_ERROR, "%s", val.Inspect())
+
+		if message, ok := hashString(val, "message"); ok {
+			err.Message = message
+		}
+
+		if kind, ok := hashString(val, "kind"); ok {
+			err.Kind = object.ErrorKind(kind)
+		}
+
+		return err
+	default:
+		return newError(object.USER_ERROR, "%s", val.Inspect())
+	}
+}
+
+// errorToHash is the value a catch block receives for err.
+func errorToHash(err *object.Error) *object.Hash {
+	trace := make([]object.Object, len(err.Trace))
+	for i, frame := range err.Trace {
+		trace[i] = newStringHash(
+			[]string{"function", "file", "line", "column"},
+			&object.String{Value: frame.Function},
+			&object.String{Value: frame.File},
+			&object.Integer{Value: int64(frame.Line)},
+			&object.Integer{Value: int64(frame.Column)},
+		)
+	}
+
+	return newStringHash(
+		[]string{"message", "kind", "trace"},
+		&object.String{Value: err.Message},
+		&object.String{Value: string(err.Kind)},
+		&object.Array{Elements: trace},
+	)
+}
+
+// newStringHash returns a hash whose keys are names, in that order, and
+// whose values are values.
+func newStringHash(names []string, values ...object.Object) *object.Hash {
+	hash := object.NewHash(len(names))
+
+	for i, name := range names {
+		hash.Set(&object.String{Value: name}, values[i])
+	}
+
+	return hash
+}
+
+func hashString(hash *object.Hash, key string) (string, bool) {
+	value, ok := hash.Get(&object.String{Value: key})
+	if !ok {
+		return "", false
+	}
+
+	str, ok := value.(*object.String)
+	if !ok {
+		return "", false
+	}
+
+	return str.Value, true
+}
@@ -0,0 +1,30 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+)
+
+// Hook follows the evaluation of a program, for debuggers. Its methods run
+// on the goroutine evaluating the program, which a debugger pauses simply
+// by not returning. Without a hook the evaluator only pays for a nil check.
+type Hook interface {
+	// Enter is called when a function call, or the evaluation of an
+	// imported file, starts.
+	Enter(function, file string)
+	// Leave is called when the innermost call entered returns.
+	Leave()
+	// Statement is called before every statement is evaluated in env.
+	Statement(stmt ast.Statement, env *object.Environment)
+}
+
+// SetHook attaches hook to the evaluator, or detaches the current one when
+// hook is nil.
+func (e *Evaluator) SetHook(hook Hook) {
+	e.hook = hook
+}
+
+// Filename returns the file set with SetFilename.
+func (e *Evaluator) Filename() string {
+	return e.frames[0].file
+}
@@ -0,0 +1,247 @@
+package evaluator
+
+import (
+	"bytes"
+	"compiler-book/object"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// newJSONModule returns the json namespace, which turns JSON text into
+// values and back: json.parse(text), json.stringify(value, indent).
+func newJSONModule() *object.Module {
+	env := object.NewEnvironment()
+	env.Set("parse", &object.Builtin{Fn: parseJSON})
+	env.Set("stringify", &object.Builtin{Fn: stringifyJSON})
+
+	return &object.Module{Name: "json", Env: env}
+}
+
+// parseJSON turns JSON text into a value. Objects are hashes with string
+// keys, and numbers are integers unless they have a fraction or an
+// exponent, or are too large for an integer.
+func parseJSON(args ...object.Object) object.Object {
+	if err := checkArguments("parse", args, object.STRING); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(stringValue(args[0])))
+	decoder.UseNumber()
+
+	value, err := decodeJSON(decoder)
+	if err != nil {
+		return newError(object.ARGUMENT_ERROR, "invalid JSON: %s", err)
+	}
+
+	// the text is one value alone, like a file
+	if _, err := decoder.Token(); err != io.EOF {
+		return newError(object.ARGUMENT_ERROR, "invalid JSON: data after the value")
+	}
+
+	return value
+}
+
+// errEndOfJSON is what json.Decoder says of texts that end in a value.
+var errEndOfJSON = errors.New("unexpected end of JSON input")
+
+// decodeJSON decodes the next value of decoder.
+func decodeJSON(decoder *json.Decoder) (object.Object, error) {
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, errEndOfJSON
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '[' {
+			elements := []object.Object{}
+			for decoder.More() {
+				element, err := decodeJSON(decoder)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+
+			_, err := decoder.Token() // ]
+			return &object.Array{Elements: elements}, err
+		}
+
+		hash := &object.Hash{}
+		for decoder.More() {
+			name, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			hash.Set(&object.String{Value: name.(string)}, value)
+		}
+
+		_, err := decoder.Token() // }
+		return hash, err
+	case json.Number:
+		if integer, err := token.Int64(); err == nil {
+			return &object.Integer{Value: integer}, nil
+		}
+
+		float, err := token.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: float}, nil
+	case string:
+		return &object.String{Value: token}, nil
+	case bool:
+		return nativeBoolToBooleanObject(token), nil
+	default:
+		return NULL, nil
+	}
+}
+
+// stringifyJSON turns a value into JSON text, on one line, or indented by
+// a number of spaces or a string.
+func stringifyJSON(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	var out bytes.Buffer
+	encoder := &jsonEncoder{out: &out, seen: make(map[object.Object]bool)}
+	if err := encoder.encode(args[0]); err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		return &object.String{Value: out.String()}
+	}
+
+	var indent string
+	switch arg := args[1].(type) {
+	case *object.Integer:
+		if arg.Value < 0 || arg.Value > 10 {
+			return newError(object.ARGUMENT_ERROR, "indent to `stringify` out of range: %d", arg.Value)
+		}
+		indent = strings.Repeat(" ", int(arg.Value))
+	case *object.String:
+		indent = arg.Value
+	default:
+		return newError(object.TYPE_ERROR, "argument 2 to `stringify` must be INTEGER or STRING, got %s",
+			arg.Type())
+	}
+
+	var indented bytes.Buffer
+	json.Indent(&indented, out.Bytes(), "", indent)
+
+	return &object.String{Value: indented.String()}
+}
+
+type jsonEncoder struct {
+	out  *bytes.Buffer
+	seen map[object.Object]bool // the arrays and hashes being encoded
+}
+
+func (e *jsonEncoder) encode(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError(object.ARGUMENT_ERROR, "cannot convert %s to JSON", obj.Inspect())
+		}
+
+		// floats keep a fraction or an exponent, so they are parsed back
+		// as floats
+		number := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(number, ".e") {
+			number += ".0"
+		}
+		e.out.WriteString(number)
+	case *object.String:
+		e.string(obj.Value)
+	case *object.Rune:
+		e.string(string(obj.Value))
+	case *object.Array:
+		if err := e.enter(obj); err != nil {
+			return err
+		}
+		defer delete(e.seen, obj)
+
+		e.out.WriteByte('[')
+		for i, element := range obj.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			if err := e.encode(element); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte(']')
+	case *object.Hash:
+		if err := e.enter(obj); err != nil {
+			return err
+		}
+		defer delete(e.seen, obj)
+
+		e.out.WriteByte('{')
+		first := true
+		for _, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "cannot convert a hash with %s keys to JSON",
+					pair.Key.Type())
+			}
+
+			if !first {
+				e.out.WriteByte(',')
+			}
+			first = false
+
+			e.string(key.Value)
+			e.out.WriteByte(':')
+			if err := e.encode(pair.Value); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte('}')
+	default:
+		return newError(object.TYPE_ERROR, "cannot convert %s to JSON", obj.Type())
+	}
+
+	return nil
+}
+
+// enter marks an array or a hash as being encoded, which it must not be
+// already.
+func (e *jsonEncoder) enter(obj object.Object) *object.Error {
+	if e.seen[obj] {
+		return newError(object.ARGUMENT_ERROR, "cannot convert a %s that contains itself to JSON", obj.Type())
+	}
+
+	e.seen[obj] = true
+	return nil
+}
+
+func (e *jsonEncoder) string(s string) {
+	encoder := json.NewEncoder(e.out)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+
+	// Encode ends the value with a newline
+	e.out.Truncate(e.out.Len() - 1)
+}
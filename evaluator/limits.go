@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+	"context"
+)
+
+// Limits bound what a program may take from its host. Zero fields are
+// unlimited.
+type Limits struct {
+	Steps int // nodes evaluated
+	Depth int // function calls in progress at once
+	Size  int // elements of an array or a hash, bytes of a string
+}
+
+// how many steps are taken between two looks at the context
+const contextInterval = 1024
+
+type limiter struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+}
+
+// EvalContext evaluates node like Eval, but stops with a LimitError, which
+// try blocks cannot catch, once ctx is done or the program goes past
+// limits.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return e.limited(ctx, limits, func() object.Object { return e.Eval(node, env) })
+}
+
+// ApplyContext calls fn with args like Apply, with the limits of
+// EvalContext.
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args []object.Object, limits Limits) object.Object {
+	return e.limited(ctx, limits, func() object.Object { return e.Apply(fn, args) })
+}
+
+func (e *Evaluator) limited(ctx context.Context, limits Limits, run func() object.Object) object.Object {
+	outer := e.limiter
+	e.limiter = &limiter{ctx: ctx, limits: limits}
+	defer func() { e.limiter = outer }()
+
+	if err := ctx.Err(); err != nil {
+		return newError(object.LIMIT_ERROR, "evaluation stopped: %s", err)
+	}
+
+	return run()
+}
+
+// step counts the evaluation of a node.
+func (l *limiter) step() *object.Error {
+	l.steps++
+
+	if l.limits.Steps > 0 && l.steps > l.limits.Steps {
+		return newError(object.LIMIT_ERROR, "step limit of %d exceeded", l.limits.Steps)
+	}
+
+	if l.steps%contextInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			return newError(object.LIMIT_ERROR, "evaluation stopped: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// call checks that a function can be called with depth calls in progress.
+func (l *limiter) call(depth int) *object.Error {
+	if l.limits.Depth > 0 && depth >= l.limits.Depth {
+		return newError(object.LIMIT_ERROR, "call depth limit of %d exceeded", l.limits.Depth)
+	}
+	return nil
+}
+
+// size checks the size of a value the program made or grew.
+func (l *limiter) size(obj object.Object) *object.Error {
+	if l.limits.Size <= 0 {
+		return nil
+	}
+
+	var size int
+	switch obj := obj.(type) {
+	case *object.Array:
+		size = len(obj.Elements)
+	case *object.Hash:
+		size = obj.Len()
+	case *object.String:
+		size = len(obj.Value)
+	}
+
+	return l.length(obj.Type(), size)
+}
+
+// length checks the size of a value of type t before it is made. Builtins
+// making values larger than their arguments check it before allocating.
+func (l *limiter) length(t object.ObjectType, size int) *object.Error {
+	if l.limits.Size > 0 && size > l.limits.Size {
+		return newError(object.LIMIT_ERROR, "size limit of %d exceeded: %s of size %d",
+			l.limits.Size, t, size)
+	}
+	return nil
+}
+
+// uncatchable reports whether obj is an error that ends the program, like
+// the ones of limits and exit.
+func uncatchable(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	return ok && (err.Kind == object.LIMIT_ERROR || err.Kind == object.EXIT)
+}
@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+)
+
+// evalLoopBody evaluates the body of a loop once, and reports whether the
+// loop goes on. A body that breaks or continues has no value. Breaking or
+// continuing a loop around this one leaves it with the signal, for the
+// loops around to handle.
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, label *ast.Identifier, env *object.Environment) (object.Object, bool) {
+	result := e.Eval(body, env)
+
+	// an empty body has no value
+	if result == nil {
+		return NULL, true
+	}
+
+	switch result := result.(type) {
+	case *object.Break:
+		if !targets(result.Label, label) {
+			return result, false
+		}
+		return NULL, false
+	case *object.Continue:
+		if !targets(result.Label, label) {
+			return result, false
+		}
+		return NULL, true
+	}
+
+	if leavesBlock(result) {
+		return result, false
+	}
+
+	return result, true
+}
+
+// targets reports whether a break or a continue naming a label is for the
+// loop with label. Those naming no label are for the innermost loop.
+func targets(name string, label *ast.Identifier) bool {
+	return name == "" || label != nil && label.Value == name
+}
+
+func labelName(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value
+}
+
+// evalWhileExpression runs the body of a loop as long as its condition is
+// truthy. Like for loops, its value is the value of its last iteration.
+func (e *Evaluator) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	enclosedEnv := object.NewEnclosedEnvironment(env)
+
+	var result object.Object = NULL
+	for {
+		condition := e.Eval(we.Condition, enclosedEnv)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return result
+		}
+
+		var more bool
+		if result, more = e.evalLoopBody(we.Body, we.Label, enclosedEnv); !more {
+			return result
+		}
+	}
+}
+
+// evalForInExpression runs the body of a loop once for every item of an
+// array, a string or a hash, in a new scope each time, so functions made
+// by the body keep the item they were made for. The value of the loop is
+// the value of its last iteration.
+func (e *Evaluator) evalForInExpression(fe *ast.ForInExpression, env *object.Environment) object.Object {
+	iterable := e.Eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	keys, values, err := loopItems(iterable)
+	if err != nil {
+		return err
+	}
+
+	// alone, the variable of a loop over a hash gets the keys
+	if _, ok := iterable.(*object.Hash); ok && fe.Key == nil {
+		values = keys
+	}
+
+	var result object.Object = NULL
+	for i := range values {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		if fe.Key != nil {
+			loopEnv.Set(fe.Key.Value, keys[i])
+		}
+		loopEnv.Set(fe.Value.Value, values[i])
+
+		var more bool
+		if result, more = e.evalLoopBody(fe.Body, fe.Label, loopEnv); !more {
+			return result
+		}
+	}
+
+	return result
+}
+
+// loopItems returns what a for-in loop goes over: the indexes and the
+// elements of an array, the indexes and the characters of a string, or the
+// keys and the values of a hash.
+func loopItems(obj object.Object) (keys, values []object.Object, err *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		values = obj.Elements
+		keys = make([]object.Object, len(values))
+		for i := range values {
+			keys[i] = &object.Integer{Value: int64(i)}
+		}
+	case *object.String:
+		runes := []rune(obj.Value)
+		keys = make([]object.Object, len(runes))
+		values = make([]object.Object, len(runes))
+		for i, r := range runes {
+			keys[i] = &object.Integer{Value: int64(i)}
+			values[i] = &object.Rune{Value: r}
+		}
+	case *object.Hash:
+		keys = make([]object.Object, 0, obj.Len())
+		values = make([]object.Object, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+	default:
+		return nil, nil, newError(object.TYPE_ERROR, "cannot loop over %s", obj.Type())
+	}
+
+	return keys, values, nil
+}
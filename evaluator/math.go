@@ -0,0 +1,228 @@
+package evaluator
+
+import (
+	"compiler-book/object"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// conversionBuiltins convert between numbers, and from strings and runes.
+var conversionBuiltins = Builtins{
+	"int":   &object.Builtin{Fn: toInt},
+	"float": &object.Builtin{Fn: toFloat},
+}
+
+// newMathModule returns the math namespace, whose members are read like
+// the ones of an imported module: math.sqrt(2.0), math.pi.
+func newMathModule() *object.Module {
+	env := object.NewEnvironment()
+
+	members := map[string]object.Object{
+		"pi":    &object.Float{Value: math.Pi},
+		"e":     &object.Float{Value: math.E},
+		"abs":   &object.Builtin{Fn: abs},
+		"floor": &object.Builtin{Fn: rounding("floor", math.Floor)},
+		"ceil":  &object.Builtin{Fn: rounding("ceil", math.Ceil)},
+		"round": &object.Builtin{Fn: rounding("round", math.Round)},
+		"sqrt":  &object.Builtin{Fn: realFunction("sqrt", math.Sqrt, func(x float64) bool { return x >= 0 })},
+		"log":   &object.Builtin{Fn: realFunction("log", math.Log, func(x float64) bool { return x > 0 })},
+		"exp":   &object.Builtin{Fn: realFunction("exp", math.Exp, nil)},
+		"sin":   &object.Builtin{Fn: realFunction("sin", math.Sin, nil)},
+		"cos":   &object.Builtin{Fn: realFunction("cos", math.Cos, nil)},
+		"tan":   &object.Builtin{Fn: realFunction("tan", math.Tan, nil)},
+		"pow":   &object.Builtin{Fn: pow},
+		"min":   &object.Builtin{Fn: extreme("min", func(a, b float64) bool { return a < b })},
+		"max":   &object.Builtin{Fn: extreme("max", func(a, b float64) bool { return a > b })},
+	}
+
+	for name, member := range members {
+		env.Set(name, member)
+	}
+
+	return &object.Module{Name: "math", Env: env}
+}
+
+// number returns the value of an integer or a float as a float.
+func number(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+func checkNumber(name string, args []object.Object, i int) *object.Error {
+	if _, ok := number(args[i]); !ok {
+		return newError(object.TYPE_ERROR, "argument %d to `%s` must be INTEGER or FLOAT, got %s",
+			i+1, name, args[i].Type())
+	}
+	return nil
+}
+
+func abs(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return &object.Integer{Value: -arg.Value}
+		}
+		return arg
+	case *object.Float:
+		return &object.Float{Value: math.Abs(arg.Value)}
+	default:
+		return checkNumber("abs", args, 0)
+	}
+}
+
+// rounding returns a builtin rounding floats with f. Integers are already
+// round.
+func rounding(name string, f func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch arg := args[0].(type) {
+		case *object.Integer:
+			return arg
+		case *object.Float:
+			return &object.Float{Value: f(arg.Value)}
+		default:
+			return checkNumber(name, args, 0)
+		}
+	}
+}
+
+// realFunction returns a builtin computing f of a number as a float. The
+// builtin raises an error for the arguments outside of the domain of f, if
+// given.
+func realFunction(name string, f func(float64) float64, domain func(float64) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		x, ok := number(args[0])
+		if !ok {
+			return checkNumber(name, args, 0)
+		}
+
+		if domain != nil && !domain(x) {
+			return newError(object.ARGUMENT_ERROR, "argument to `%s` out of its domain: %s",
+				name, args[0].Inspect())
+		}
+
+		return &object.Float{Value: f(x)}
+	}
+}
+
+// pow is like **, for integers and floats mixed too.
+func pow(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	base, ok := number(args[0])
+	if !ok {
+		return checkNumber("pow", args, 0)
+	}
+
+	exp, ok := number(args[1])
+	if !ok {
+		return checkNumber("pow", args, 1)
+	}
+
+	if args[0].Type() == object.INTEGER && args[1].Type() == object.INTEGER {
+		return evalIntegerInfixExpression("**", args[0], args[1])
+	}
+
+	return &object.Float{Value: math.Pow(base, exp)}
+}
+
+// extreme returns a builtin choosing the number that comes first by
+// before. The numbers must be all integers or all floats.
+func extreme(name string, before func(a, b float64) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want at least 1")
+		}
+
+		chosen := 0
+		for i, arg := range args {
+			if err := checkNumber(name, args, i); err != nil {
+				return err
+			}
+
+			if arg.Type() != args[0].Type() {
+				return newError(object.TYPE_ERROR, "arguments to `%s` must be of one type, got %s and %s",
+					name, args[0].Type(), arg.Type())
+			}
+
+			x, _ := number(arg)
+			y, _ := number(args[chosen])
+			if before(x, y) {
+				chosen = i
+			}
+		}
+
+		return args[chosen]
+	}
+}
+
+// toInt converts a number, rune or string to an integer. Floats are
+// truncated towards zero.
+func toInt(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) ||
+			arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			return newError(object.ARGUMENT_ERROR, "cannot convert %s to an integer", arg.Inspect())
+		}
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.Rune:
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError(object.ARGUMENT_ERROR, "cannot convert %q to an integer", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	default:
+		return newError(object.TYPE_ERROR, "argument to `int` not supported, got %s", arg.Type())
+	}
+}
+
+// toFloat converts a number or a string to a float.
+func toFloat(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError(object.ARGUMENT_ERROR, "cannot convert %q to a float", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError(object.TYPE_ERROR, "argument to `float` not supported, got %s", arg.Type())
+	}
+}
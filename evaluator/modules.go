@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetFilename tells the evaluator which file the program comes from, so
+// its imports are resolved relative to it. Programs without a file, like
+// the ones typed into the REPL, import relative to the working directory.
+func (e *Evaluator) SetFilename(filename string) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filename
+	}
+
+	e.files = []string{path}
+	e.frames[0].file = path
+}
+
+func (e *Evaluator) evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := e.importModule(lexer.Unescape(node.Path.Value))
+	if err, ok := module.(*object.Error); ok {
+		e.traceError(err, node)
+		return err
+	}
+
+	env.Set(node.Name(), module)
+	return NULL
+}
+
+// importModule evaluates the file at path in its own environment. Every
+// file is evaluated once, later imports get the cached module.
+func (e *Evaluator) importModule(path string) object.Object {
+	path = e.resolveImport(path)
+
+	for i, file := range e.files {
+		if file == path {
+			cycle := append(append([]string{}, e.files[i:]...), path)
+			return newError(object.IMPORT_ERROR, "import cycle: %s", formatImportCycle(cycle))
+		}
+	}
+
+	if module, ok := e.modules[path]; ok {
+		return module
+	}
+
+	if err := e.checkImport(path); err != nil {
+		return err
+	}
+
+	name := filepath.Base(path)
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError(object.IMPORT_ERROR, "cannot import %s: %s", name, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError(object.IMPORT_ERROR, "cannot import %s: %s", name, p.Errors()[0])
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded := ExpandMacros(program, macroEnv)
+
+	env := object.NewEnvironment()
+
+	e.files = append(e.files, path)
+	e.pushFrame("<module>", path)
+	result := e.Eval(expanded, env)
+	e.popFrame()
+	e.files = e.files[:len(e.files)-1]
+
+	if isError(result) {
+		return result
+	}
+
+	module := &object.Module{
+		Name: strings.TrimSuffix(name, filepath.Ext(name)),
+		Path: path,
+		Env:  env,
+	}
+	e.modules[path] = module
+
+	return module
+}
+
+// resolveImport makes path absolute, relative to the directory of the file
+// that imports it.
+func (e *Evaluator) resolveImport(path string) string {
+	importer := e.frames[len(e.frames)-1].file
+	if !filepath.IsAbs(path) && importer != "" {
+		path = filepath.Join(filepath.Dir(importer), path)
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return filepath.Clean(path)
+}
+
+// checkImport tells whether the file at path may be imported. A program
+// may import the files in its own directory, the ones it may import and the
+// ones it may read.
+func (e *Evaluator) checkImport(path string) *object.Error {
+	if main := e.frames[0].file; main != "" && allowed([]string{filepath.Dir(main)}, path) {
+		return nil
+	}
+	if allowed(e.permissions.Import, path) {
+		return nil
+	}
+
+	return checkAccess("read", e.permissions.Read, path)
+}
+
+func formatImportCycle(files []string) string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = filepath.Base(file)
+	}
+
+	return strings.Join(names, " -> ")
+}
@@ -7,12 +7,12 @@ import (
 	"fmt"
 )
 
-func quote(node ast.Node, env *object.Environment) object.Object {
-	node = evalUnquoteCalls(node, env)
+func (e *Evaluator) quote(node ast.Node, env *object.Environment) object.Object {
+	node = e.evalUnquoteCalls(node, env)
 	return &object.Quote{Node: node}
 }
 
-func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+func (e *Evaluator) evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
 	return ast.Modify(quoted, func(node ast.Node) ast.Node {
 		if !isUnquoteCall(node) {
 			return node
@@ -27,7 +27,7 @@ func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
 			return node
 		}
 
-		unquoted := Eval(call.Arguments[0], env)
+		unquoted := e.Eval(call.Arguments[0], env)
 		return convertObjectToASTNode(unquoted)
 	})
 }
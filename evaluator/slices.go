@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+)
+
+// evalSliceExpression evaluates s[low:high], the elements of an array or
+// the runes of a string from low up to high. Like indexes, the bounds
+// count from the end when they are negative.
+func (e *Evaluator) evalSliceExpression(se *ast.SliceExpression, env *object.Environment) object.Object {
+	left := e.Eval(se.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	length, ok := object.SliceLength(left)
+	if !ok {
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+
+	low, err := e.evalSliceBound(se.Low, env, 0, length)
+	if err != nil {
+		return err
+	}
+
+	high, err := e.evalSliceBound(se.High, env, length, length)
+	if err != nil {
+		return err
+	}
+
+	return object.Slice(left, low, high)
+}
+
+// evalSliceBound evaluates a bound of a slice, which is otherwise when it
+// is left out.
+func (e *Evaluator) evalSliceBound(bound ast.Expression, env *object.Environment, otherwise, length int64) (int64, object.Object) {
+	if bound == nil {
+		return otherwise, nil
+	}
+
+	value := e.Eval(bound, env)
+	if isError(value) {
+		return 0, value
+	}
+
+	index, err := object.SliceBound(value, otherwise, length)
+	if err != nil {
+		return 0, err
+	}
+	return index, nil
+}
@@ -0,0 +1,216 @@
+package evaluator
+
+import (
+	"compiler-book/object"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringBuiltins work on strings as sequences of runes, so indexes and
+// lengths count characters, not bytes.
+func (e *Evaluator) stringBuiltins() Builtins {
+	return Builtins{
+		"split":      &object.Builtin{Fn: split},
+		"join":       &object.Builtin{Fn: e.join},
+		"startsWith": &object.Builtin{Fn: stringPredicate("startsWith", strings.HasPrefix)},
+		"endsWith":   &object.Builtin{Fn: stringPredicate("endsWith", strings.HasSuffix)},
+		"index":      &object.Builtin{Fn: index},
+		"replace":    &object.Builtin{Fn: e.replace},
+		"trim":       &object.Builtin{Fn: stringMapping("trim", strings.TrimSpace)},
+		"upper":      &object.Builtin{Fn: stringMapping("upper", strings.ToUpper)},
+		"lower":      &object.Builtin{Fn: stringMapping("lower", strings.ToLower)},
+		"substr":     &object.Builtin{Fn: substr},
+		"repeat":     &object.Builtin{Fn: e.repeat},
+	}
+}
+
+// anything is the type checkArguments takes for the arguments of any type.
+const anything object.ObjectType = "ANYTHING"
+
+// checkArguments checks that the builtin name got arguments of the types
+// want, in order. Builtins are arguments of type FUNCTION too.
+func checkArguments(name string, args []object.Object, want ...object.ObjectType) *object.Error {
+	if len(args) != len(want) {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d",
+			len(args), len(want))
+	}
+
+	for i, arg := range args {
+		if want[i] == anything || want[i] == object.FUNCTION && arg.Type() == object.BUILTIN {
+			continue
+		}
+
+		if arg.Type() != want[i] {
+			return newError(object.TYPE_ERROR, "argument %d to `%s` must be %s, got %s",
+				i+1, name, want[i], arg.Type())
+		}
+	}
+
+	return nil
+}
+
+func stringValue(obj object.Object) string {
+	return obj.(*object.String).Value
+}
+
+func stringMapping(name string, f func(string) string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArguments(name, args, object.STRING); err != nil {
+			return err
+		}
+
+		return &object.String{Value: f(stringValue(args[0]))}
+	}
+}
+
+func stringPredicate(name string, f func(s, substr string) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArguments(name, args, object.STRING, object.STRING); err != nil {
+			return err
+		}
+
+		return nativeBoolToBooleanObject(f(stringValue(args[0]), stringValue(args[1])))
+	}
+}
+
+// split splits a string around a separator, or into its characters when
+// the separator is empty.
+func split(args ...object.Object) object.Object {
+	if err := checkArguments("split", args, object.STRING, object.STRING); err != nil {
+		return err
+	}
+
+	parts := strings.Split(stringValue(args[0]), stringValue(args[1]))
+
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// join concatenates an array of strings, with a separator between them.
+func (e *Evaluator) join(args ...object.Object) object.Object {
+	if err := checkArguments("join", args, object.ARRAY, object.STRING); err != nil {
+		return err
+	}
+
+	elements := args[0].(*object.Array).Elements
+	separator := stringValue(args[1])
+
+	parts := make([]string, len(elements))
+	size := 0
+	for i, element := range elements {
+		str, ok := element.(*object.String)
+		if !ok {
+			return newError(object.TYPE_ERROR, "argument 1 to `join` must be an array of strings, got %s at %d",
+				element.Type(), i)
+		}
+		parts[i] = str.Value
+
+		size += len(str.Value)
+		if i > 0 {
+			size += len(separator)
+		}
+	}
+
+	if e.limiter != nil {
+		if err := e.limiter.length(object.STRING, size); err != nil {
+			return err
+		}
+	}
+
+	return &object.String{Value: strings.Join(parts, separator)}
+}
+
+// index returns the position of the first occurrence of a substring, in
+// runes, or -1 if there is none.
+func index(args ...object.Object) object.Object {
+	if err := checkArguments("index", args, object.STRING, object.STRING); err != nil {
+		return err
+	}
+
+	s := stringValue(args[0])
+
+	i := strings.Index(s, stringValue(args[1]))
+	if i < 0 {
+		return &object.Integer{Value: -1}
+	}
+
+	return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+}
+
+// replace replaces every occurrence of old by new.
+func (e *Evaluator) replace(args ...object.Object) object.Object {
+	if err := checkArguments("replace", args, object.STRING, object.STRING, object.STRING); err != nil {
+		return err
+	}
+
+	s, old, with := stringValue(args[0]), stringValue(args[1]), stringValue(args[2])
+
+	if e.limiter != nil {
+		size := len(s) + strings.Count(s, old)*(len(with)-len(old))
+		if err := e.limiter.length(object.STRING, size); err != nil {
+			return err
+		}
+	}
+
+	return &object.String{Value: strings.ReplaceAll(s, old, with)}
+}
+
+// substr returns length runes of a string from start, or all of them up to
+// the end when the length is left out.
+func substr(args ...object.Object) object.Object {
+	var err *object.Error
+	if len(args) == 2 {
+		err = checkArguments("substr", args, object.STRING, object.INTEGER)
+	} else {
+		err = checkArguments("substr", args, object.STRING, object.INTEGER, object.INTEGER)
+	}
+	if err != nil {
+		return err
+	}
+
+	runes := []rune(stringValue(args[0]))
+	start := args[1].(*object.Integer).Value
+
+	length := int64(len(runes)) - start
+	if len(args) == 3 {
+		length = args[2].(*object.Integer).Value
+	}
+
+	if start < 0 || start > int64(len(runes)) || length < 0 || length > int64(len(runes))-start {
+		return newError(object.INDEX_ERROR, "substring out of range: start %d, length %d of %d",
+			start, length, len(runes))
+	}
+
+	return &object.String{Value: string(runes[start : start+length])}
+}
+
+// repeat returns count copies of a string.
+func (e *Evaluator) repeat(args ...object.Object) object.Object {
+	if err := checkArguments("repeat", args, object.STRING, object.INTEGER); err != nil {
+		return err
+	}
+
+	s := stringValue(args[0])
+	count := args[1].(*object.Integer).Value
+	if count < 0 {
+		return newError(object.ARGUMENT_ERROR, "negative count to `repeat`: %d", count)
+	}
+
+	// the length may not fit an int64, so count is compared instead
+	if s != "" && count > math.MaxInt32/int64(len(s)) {
+		return newError(object.ARGUMENT_ERROR, "%d copies of a string of %d bytes are too large", count, len(s))
+	}
+
+	if e.limiter != nil {
+		if err := e.limiter.length(object.STRING, len(s)*int(count)); err != nil {
+			return err
+		}
+	}
+
+	return &object.String{Value: strings.Repeat(s, int(count))}
+}
@@ -0,0 +1,308 @@
+package evaluator
+
+import (
+	"compiler-book/object"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Everything stands for every path or environment variable in
+// Permissions.
+const Everything = "*"
+
+// Permissions are what the programs of an evaluator may do with the system
+// they run on. The zero value allows nothing, so programs are sandboxed
+// unless their host says otherwise.
+type Permissions struct {
+	Read  []string // the files and directories whose contents may be read
+	Write []string // the files and directories whose contents may be written
+	Env   []string // the environment variables that may be read
+
+	// Import are the files and directories modules may be imported from,
+	// besides the directory of the main file and the ones that may be read.
+	// Importing runs a module, it does not give its contents to the program.
+	Import []string
+}
+
+// AllPermissions are the permissions that allow everything.
+func AllPermissions() Permissions {
+	return Permissions{
+		Read:   []string{Everything},
+		Write:  []string{Everything},
+		Env:    []string{Everything},
+		Import: []string{Everything},
+	}
+}
+
+// SetPermissions sets what the programs e runs may do with the system.
+// Relative paths are relative to the working directory.
+func (e *Evaluator) SetPermissions(permissions Permissions) {
+	e.permissions = permissions
+}
+
+// Permissions returns what the programs e runs may do with the system.
+func (e *Evaluator) Permissions() Permissions {
+	return e.permissions
+}
+
+// SetArgs sets the arguments programs get from args().
+func (e *Evaluator) SetArgs(args []string) {
+	e.args = args
+}
+
+// systemBuiltins read and write files, and talk to the process. Those
+// reaching outside of the program check the permissions of e when they
+// are called.
+func (e *Evaluator) systemBuiltins() Builtins {
+	return Builtins{
+		"readFile":   &object.Builtin{Fn: e.readFile},
+		"writeFile":  &object.Builtin{Fn: e.writeFile("writeFile", os.O_TRUNC)},
+		"appendFile": &object.Builtin{Fn: e.writeFile("appendFile", os.O_APPEND)},
+		"listDir":    &object.Builtin{Fn: e.listDir},
+		"exists":     &object.Builtin{Fn: e.exists},
+		"env":        &object.Builtin{Fn: e.env},
+		"args":       &object.Builtin{Fn: e.arguments},
+		"readLine":   &object.Builtin{Fn: e.readLine},
+		"exit":       &object.Builtin{Fn: exit},
+	}
+}
+
+// allowed reports whether path is one of paths or inside one of them.
+// Symbolic links are followed, so they cannot lead out of the paths.
+func allowed(paths []string, path string) bool {
+	resolved, err := resolve(path)
+	if err != nil {
+		return false
+	}
+
+	for _, p := range paths {
+		if p == Everything {
+			return true
+		}
+
+		p, err := resolve(p)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(p, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolve returns the absolute path of path without symbolic links. A
+// path that does not exist yet, like a file about to be written, is
+// resolved from its closest existing directory.
+func resolve(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		dir, base := filepath.Split(path)
+		if dir == path {
+			return path, nil
+		}
+
+		dir, err = resolve(filepath.Clean(dir))
+		return filepath.Join(dir, base), err
+	}
+
+	return resolved, err
+}
+
+func checkAccess(access string, paths []string, path string) *object.Error {
+	if !allowed(paths, path) {
+		return newError(object.PERMISSION_ERROR, "%s access to %s is not allowed", access, path)
+	}
+	return nil
+}
+
+func ioError(err error) *object.Error {
+	return newError(object.IO_ERROR, "%s", err)
+}
+
+func (e *Evaluator) readFile(args ...object.Object) object.Object {
+	if err := checkArguments("readFile", args, object.STRING); err != nil {
+		return err
+	}
+
+	path := stringValue(args[0])
+	if err := checkAccess("read", e.permissions.Read, path); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ioError(err)
+	}
+
+	return &object.String{Value: string(content)}
+}
+
+// writeFile returns a builtin writing a string to a file, which is created
+// if needed. flag tells what happens to what the file had.
+func (e *Evaluator) writeFile(name string, flag int) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := checkArguments(name, args, object.STRING, object.STRING); err != nil {
+			return err
+		}
+
+		path := stringValue(args[0])
+		if err := checkAccess("write", e.permissions.Write, path); err != nil {
+			return err
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0o644)
+		if err != nil {
+			return ioError(err)
+		}
+
+		_, err = file.WriteString(stringValue(args[1]))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return ioError(err)
+		}
+
+		return nil
+	}
+}
+
+// listDir returns the names of the entries of a directory, sorted.
+func (e *Evaluator) listDir(args ...object.Object) object.Object {
+	if err := checkArguments("listDir", args, object.STRING); err != nil {
+		return err
+	}
+
+	path := stringValue(args[0])
+	if err := checkAccess("read", e.permissions.Read, path); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return ioError(err)
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = &object.String{Value: name}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (e *Evaluator) exists(args ...object.Object) object.Object {
+	if err := checkArguments("exists", args, object.STRING); err != nil {
+		return err
+	}
+
+	path := stringValue(args[0])
+	if err := checkAccess("read", e.permissions.Read, path); err != nil {
+		return err
+	}
+
+	_, err := os.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ioError(err)
+	}
+
+	return nativeBoolToBooleanObject(err == nil)
+}
+
+// env returns the value of an environment variable, or null when it is not
+// set.
+func (e *Evaluator) env(args ...object.Object) object.Object {
+	if err := checkArguments("env", args, object.STRING); err != nil {
+		return err
+	}
+
+	name := stringValue(args[0])
+
+	permitted := false
+	for _, allowed := range e.permissions.Env {
+		permitted = permitted || allowed == Everything || allowed == name
+	}
+	if !permitted {
+		return newError(object.PERMISSION_ERROR, "access to the environment variable %s is not allowed", name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: value}
+}
+
+func (e *Evaluator) arguments(args ...object.Object) object.Object {
+	if err := checkArguments("args", args); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(e.args))
+	for i, arg := range e.args {
+		elements[i] = &object.String{Value: arg}
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// readLine reads a line from the standard input, without its line ending,
+// or null at the end of the input.
+func (e *Evaluator) readLine(args ...object.Object) object.Object {
+	if err := checkArguments("readLine", args); err != nil {
+		return err
+	}
+
+	line, err := e.stdin.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return NULL
+	}
+	if err != nil && err != io.EOF {
+		return ioError(err)
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	return &object.String{Value: strings.TrimSuffix(line, "\r")}
+}
+
+// exit ends the program with a status, 0 if left out. It is up to the host
+// to end the process too.
+func exit(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+
+	var status int64
+	if len(args) == 1 {
+		if err := checkArguments("exit", args, object.INTEGER); err != nil {
+			return err
+		}
+		status = args[0].(*object.Integer).Value
+	}
+
+	err := newError(object.EXIT, "exit status %d", status)
+	err.Status = int(status)
+
+	return err
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"compiler-book/ast"
+	"compiler-book/object"
+)
+
+const TAIL_CALL object.ObjectType = "TAIL_CALL"
+
+// tailCall is a call in tail position, returned to applyFunction instead
+// of being made, so the call reuses the frame of the function making it
+// rather than growing the Go stack.
+type tailCall struct {
+	function *object.Function
+	args     []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return TAIL_CALL }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.function.Inspect() }
+
+// markTailCalls records the calls in tail position of a function body: the
+// value of a return statement, or of the last statement of the body,
+// looking through if expressions. Calls inside try blocks are not in tail
+// position, since errors they raise must still be caught. The marks stay on
+// the calls, so marking a body again changes nothing.
+func markTailCalls(body *ast.BlockStatement) {
+	var statements func(stmts []ast.Statement, tail bool)
+	var expression func(exp ast.Expression, tail bool)
+
+	statements = func(stmts []ast.Statement, tail bool) {
+		for i, stmt := range stmts {
+			switch stmt := stmt.(type) {
+			case *ast.ReturnStatement:
+				expression(stmt.ReturnValue, true)
+			case *ast.ExpressionStatement:
+				expression(stmt.Expression, tail && i == len(stmts)-1)
+			}
+		}
+	}
+
+	expression = func(exp ast.Expression, tail bool) {
+		switch exp := exp.(type) {
+		case *ast.CallExpression:
+			if tail {
+				exp.Tail = true
+			}
+		case *ast.IfExpression:
+			statements(exp.Consequence.Statements, tail)
+			if exp.Alternative != nil {
+				statements(exp.Alternative.Statements, tail)
+			}
+		case *ast.ForExpression:
+			// the body may return
+			statements(exp.Body.Statements, false)
+		case *ast.ForInExpression:
+			statements(exp.Body.Statements, false)
+		case *ast.WhileExpression:
+			statements(exp.Body.Statements, false)
+		}
+	}
+
+	statements(body.Statements, true)
+}
@@ -0,0 +1,142 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const usage = `usage: slang fmt [--check | --write] [path ...]
+
+Formats Slang programs. Directories are searched for .sl files. Without
+paths, the program is read from the standard input. Without flags, the
+formatted programs are printed.
+`
+
+// Run runs slang fmt with args and returns its exit code: 1 if a program
+// could not be formatted or, with --check, is not formatted, 2 for wrong
+// arguments.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	check := flags.Bool("check", false, "list the files that are not formatted, without changing them")
+	write := flags.Bool("write", false, "format the files in place")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *check && *write {
+		fmt.Fprintln(stderr, "use either --check or --write")
+		return 2
+	}
+
+	r := &runner{check: *check, write: *write, stdout: stdout, stderr: stderr}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "--write needs files to format")
+			return 2
+		}
+
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		r.format("<stdin>", src, nil)
+		return r.code
+	}
+
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// the files given are formatted whatever their extension
+			if entry.IsDir() || path != root && filepath.Ext(path) != ".sl" {
+				return nil
+			}
+
+			return r.file(path, entry)
+		})
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			r.code = 1
+		}
+	}
+
+	return r.code
+}
+
+type runner struct {
+	check, write   bool
+	stdout, stderr io.Writer
+	code           int
+}
+
+func (r *runner) file(path string, entry fs.DirEntry) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return err
+	}
+
+	r.format(path, src, func(formatted []byte) error {
+		return os.WriteFile(path, formatted, info.Mode().Perm())
+	})
+	return nil
+}
+
+// format formats the program in src, named name, and checks, prints or
+// saves the result.
+func (r *runner) format(name string, src []byte, save func([]byte) error) {
+	formatted, err := Source(src)
+	if err != nil {
+		var syntax *Error
+		if errors.As(err, &syntax) {
+			for _, e := range syntax.Errors {
+				fmt.Fprintf(r.stderr, "%s:%d:%d: %s\n", name, e.Line, e.Column, e.Message)
+			}
+		} else {
+			fmt.Fprintf(r.stderr, "%s: %s\n", name, err)
+		}
+		r.code = 1
+		return
+	}
+
+	changed := !bytes.Equal(src, formatted)
+
+	switch {
+	case r.check:
+		if changed {
+			fmt.Fprintln(r.stdout, name)
+			r.code = 1
+		}
+	case r.write:
+		if changed {
+			if err := save(formatted); err != nil {
+				fmt.Fprintln(r.stderr, err)
+				r.code = 1
+			}
+		}
+	default:
+		r.stdout.Write(formatted)
+	}
+}
@@ -0,0 +1,686 @@
+// Package format prints Slang programs in a canonical layout, keeping their
+// comments.
+package format
+
+import (
+	"compiler-book/ast"
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"compiler-book/token"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const INDENT = "  "
+
+// Error lists the syntax errors that keep a program from being formatted.
+type Error struct {
+	Errors []*parser.ParseError
+}
+
+func (e *Error) Error() string {
+	first := e.Errors[0]
+	msg := fmt.Sprintf("%d:%d: %s", first.Line, first.Column, first.Message)
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+// Source formats a program:
+//
+//   - blocks and multi-line literals are indented by two spaces, one
+//     statement or element per line
+//   - blocks and literals written on one line stay on one line if they fit
+//   - operators are surrounded by spaces, and only the parentheses that
+//     change the meaning are kept
+//   - statements end with a semicolon, except for if, for and try, and the
+//     value of a block written on one line
+//   - comments are kept, and runs of blank lines become a single one
+//
+// Programs with syntax errors are not formatted.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewWithComments(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &Error{Errors: p.Errors()}
+	}
+
+	pr := &printer{out: &strings.Builder{}, lineStart: true, printed: make(map[token.TokenMetadata]bool)}
+	pr.statements(program.Statements)
+	pr.innerComments(program)
+	out := []byte(pr.out.String())
+
+	// a mistake of the printer must never change what a program means, nor
+	// lose a comment
+	check := parser.New(lexer.NewWithComments(string(out)))
+	formatted := check.ParseProgram()
+	if len(check.Errors()) != 0 || dump(formatted) != dump(program) ||
+		strings.Join(commentTexts(formatted), "\n") != strings.Join(commentTexts(program), "\n") {
+		return nil, errors.New("formatting would change the program")
+	}
+
+	return out, nil
+}
+
+type printer struct {
+	out       *strings.Builder
+	indent    int
+	lineStart bool // nothing was written on the current line yet
+	lastLine  int  // the source line printed last, 0 at the start of a block
+
+	printed map[token.TokenMetadata]bool // the comments printed, by position
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart && s != "" {
+		p.out.WriteString(strings.Repeat(INDENT, p.indent))
+		p.lineStart = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+	p.lineStart = true
+}
+
+// capture returns what print writes instead of writing it.
+func (p *printer) capture(print func()) string {
+	out, lineStart := p.out, p.lineStart
+	p.out = &strings.Builder{}
+	print()
+
+	captured := p.out.String()
+	p.out, p.lineStart = out, lineStart
+	return captured
+}
+
+// raw writes text that was captured, indentation included.
+func (p *printer) raw(text string) {
+	p.out.WriteString(text)
+	p.lineStart = strings.HasSuffix(text, "\n")
+}
+
+// item prints a statement, or an element of a literal from its first to
+// its last node, on a line of its own. Its leading comments go before it
+// and its trailing comment after it; the comments inside that print did
+// not place go before it too.
+func (p *printer) item(first, last ast.Node, print func()) {
+	for _, c := range first.Source().Leading {
+		p.separate(c.Metadata.Line)
+		p.commentLine(c)
+	}
+	p.separate(first.Source().Start.Line)
+
+	trailing := last.Source().Trailing
+	if trailing != nil {
+		p.printed[trailing.Metadata] = true
+	}
+
+	nodes := []ast.Node{first}
+	if last != first {
+		nodes = append(nodes, last)
+	}
+
+	text := p.capture(print)
+	for _, c := range p.unprinted(nodes...) {
+		p.commentLine(c)
+	}
+	p.raw(text)
+
+	p.lastLine = last.Source().End.Line
+	if trailing != nil {
+		p.write(" " + comment(*trailing))
+	}
+	p.newline()
+}
+
+// unprinted returns the comments in nodes that are not printed yet, in the
+// order of the source.
+func (p *printer) unprinted(nodes ...ast.Node) []token.Token {
+	var unprinted []token.Token
+	for _, node := range nodes {
+		ast.Walk(node, func(node ast.Node) bool {
+			for _, c := range all(node.Source()) {
+				if !p.printed[c.Metadata] {
+					unprinted = append(unprinted, c)
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Slice(unprinted, func(i, j int) bool { return before(unprinted[i].Metadata, unprinted[j].Metadata) })
+	return unprinted
+}
+
+func all(s *ast.Syntax) []token.Token {
+	comments := append(append([]token.Token{}, s.Leading...), s.Inner...)
+	if s.Trailing != nil {
+		comments = append(comments, *s.Trailing)
+	}
+	return comments
+}
+
+func (p *printer) commentLine(c token.Token) {
+	p.write(comment(c))
+	p.newline()
+	p.printed[c.Metadata] = true
+	p.lastLine = c.Metadata.Line
+}
+
+func comment(c token.Token) string {
+	return strings.TrimRight(c.Literal, " \t\r")
+}
+
+// openingComment prints a comment on the line of the bracket that opens
+// node after the bracket.
+func (p *printer) openingComment(node ast.Node) {
+	s := node.Source()
+	if len(s.Inner) > 0 && s.Inner[0].Metadata.Line == s.Start.Line && !p.printed[s.Inner[0].Metadata] {
+		p.write(" " + comment(s.Inner[0]))
+		p.printed[s.Inner[0].Metadata] = true
+	}
+}
+
+// innerComments prints the comments at the end of a block, a literal or
+// the program on lines of their own.
+func (p *printer) innerComments(node ast.Node) {
+	for _, c := range node.Source().Inner {
+		if !p.printed[c.Metadata] {
+			p.separate(c.Metadata.Line)
+			p.commentLine(c)
+		}
+	}
+}
+
+// separate keeps a blank line before source line if there was at least one.
+func (p *printer) separate(line int) {
+	if p.lastLine != 0 && line > p.lastLine+1 {
+		p.newline()
+	}
+}
+
+// statements prints the statements of a block or of the program.
+func (p *printer) statements(statements []ast.Statement) {
+	p.lastLine = 0
+
+	for i, statement := range statements {
+		statement, terminated := statement, semicolon(statements, i, false)
+		p.item(statement, statement, func() { p.statement(statement, terminated) })
+	}
+}
+
+// semicolon reports whether the statement at i ends with a semicolon. The
+// statement of a block written on one line doesn't need one.
+func semicolon(statements []ast.Statement, i int, inline bool) bool {
+	statement, ok := statements[i].(*ast.ExpressionStatement)
+	if !ok {
+		return true
+	}
+
+	if inline {
+		return false
+	}
+
+	switch statement.Expression.(type) {
+	case *ast.IfExpression, *ast.ForExpression, *ast.ForInExpression, *ast.WhileExpression, *ast.TryExpression:
+		// unless the next statement would continue the expression
+		if i+1 < len(statements) {
+			if next, ok := statements[i+1].(*ast.ExpressionStatement); ok {
+				return strings.IndexByte("([-", opening(next.Expression)) >= 0
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *printer) statement(statement ast.Statement, semicolon bool) {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		p.write("let " + declaration(statement.Name) + " = ")
+		p.expression(statement.Value)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(statement.ReturnValue)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(statement.Value)
+	case *ast.BreakStatement:
+		p.write("break" + jumpLabel(statement.Label))
+	case *ast.ContinueStatement:
+		p.write("continue" + jumpLabel(statement.Label))
+	case *ast.ImportStatement:
+		p.write("import \"" + statement.Path.Value + "\"")
+		if statement.Alias != nil {
+			p.write(" as " + statement.Alias.Value)
+		}
+	case *ast.ExpressionStatement:
+		p.expression(statement.Expression)
+	}
+
+	if semicolon {
+		p.write(";")
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	s := block.Source()
+
+	if len(block.Statements) == 0 && len(s.Inner) == 0 {
+		p.write("{}")
+		return
+	}
+
+	// a block written on one line can stay there, comments can't be on it
+	if len(block.Statements) == 1 && s.Start.Line == s.End.Line {
+		inline := p.capture(func() { p.statement(block.Statements[0], semicolon(block.Statements, 0, true)) })
+		if !strings.Contains(inline, "\n") {
+			p.write("{ ")
+			p.raw(inline)
+			p.write(" }")
+			return
+		}
+	}
+
+	p.write("{")
+	p.openingComment(block)
+	p.newline()
+
+	p.indent++
+	p.statements(block.Statements)
+	p.innerComments(block)
+	p.indent--
+
+	p.write("}")
+}
+
+// element is an element of an array literal or a pair of a hash literal,
+// from its first to its last node.
+type element struct {
+	first, last ast.Node
+	print       func()
+}
+
+// list prints the elements of an array or hash literal.
+func (p *printer) list(node ast.Node, brackets string, elements []element) {
+	s := node.Source()
+
+	if len(elements) == 0 && len(s.Inner) == 0 {
+		p.write(brackets)
+		return
+	}
+
+	if s.Start.Line == s.End.Line {
+		inline := p.capture(func() {
+			for i, element := range elements {
+				if i > 0 {
+					p.write(", ")
+				}
+				element.print()
+			}
+		})
+		if !strings.Contains(inline, "\n") {
+			p.write(brackets[:1])
+			p.raw(inline)
+			p.write(brackets[1:])
+			return
+		}
+	}
+
+	p.write(brackets[:1])
+	p.openingComment(node)
+	p.newline()
+
+	p.indent++
+	p.lastLine = 0
+	for i, element := range elements {
+		element, comma := element, i < len(elements)-1
+		p.item(element.first, element.last, func() {
+			element.print()
+			if comma {
+				p.write(",")
+			}
+		})
+	}
+	p.innerComments(node)
+	p.indent--
+
+	p.write(brackets[1:])
+}
+
+// operand prints an operand of an operator that binds with precedence,
+// in parentheses if it binds less tightly.
+func (p *printer) operand(e ast.Expression, precedence int) {
+	if needsParens(e, precedence) {
+		p.write("(")
+		p.expression(e)
+		p.write(")")
+		return
+	}
+
+	p.expression(e)
+}
+
+func (p *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		p.write(e.TokenLiteral())
+	case *ast.StringLiteral:
+		p.write("\"" + e.Token.Literal + "\"")
+	case *ast.RuneLiteral:
+		if e.Token.Literal == "\\" || e.Token.Literal == "'" {
+			p.write("'\\" + e.Token.Literal + "'")
+		} else {
+			p.write("'" + e.Token.Literal + "'")
+		}
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		if e.Operator == "-" && !needsParens(e.Right, parser.PREFIX) && opening(e.Right) == '-' {
+			// --x would be a decrement
+			p.write("(")
+			p.expression(e.Right)
+			p.write(")")
+		} else {
+			p.operand(e.Right, parser.PREFIX)
+		}
+	case *ast.PostfixExpression:
+		p.write(e.Token.Literal + e.Operator)
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(e.Token.Type)
+		if e.Token.Type == token.POWER {
+			// right associative
+			p.operand(e.Left, precedence+1)
+			p.write(" " + e.Operator + " ")
+			p.operand(e.Right, precedence)
+			break
+		}
+
+		p.operand(e.Left, precedence)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, precedence+1)
+	case *ast.AssignExpression:
+		p.operand(e.Left, parser.CALL)
+		p.write(" = ")
+		p.expression(e.Value)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		p.write("(")
+		for i, argument := range e.Arguments {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(argument)
+		}
+		p.write(")")
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.operand(e.Left, parser.CALL)
+		p.write("[")
+		if e.Low != nil {
+			p.expression(e.Low)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.expression(e.High)
+		}
+		p.write("]")
+	case *ast.MemberExpression:
+		p.operand(e.Object, parser.CALL)
+		p.write("." + e.Property.Value)
+	case *ast.ArrayLiteral:
+		elements := make([]element, len(e.Elements))
+		for i, value := range e.Elements {
+			value := value
+			elements[i] = element{value, value, func() { p.expression(value) }}
+		}
+		p.list(e, "[]", elements)
+	case *ast.HashLiteral:
+		elements := make([]element, len(e.Keys))
+		for i, key := range e.Keys {
+			key, value := key, e.Pairs[key]
+			elements[i] = element{key, value, func() {
+				p.expression(key)
+				p.write(": ")
+				p.expression(value)
+			}}
+		}
+		p.list(e, "{}", elements)
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.parameters(e.Parameters)
+		if e.ReturnType != nil {
+			p.write(": " + e.ReturnType.String())
+		}
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.write("magic")
+		p.parameters(e.Parameters)
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.ForExpression:
+		p.label(e.Label)
+		p.write("for (")
+		p.statement(e.Init, false)
+		p.write("; ")
+		p.expression(e.Condition)
+		p.write("; ")
+		p.expression(e.Post)
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.ForInExpression:
+		p.label(e.Label)
+		p.write("for (")
+		if e.Key != nil {
+			p.write(e.Key.Value + ", ")
+		}
+		p.write(e.Value.Value + " in ")
+		p.expression(e.Iterable)
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.WhileExpression:
+		p.label(e.Label)
+		p.write("while (")
+		p.expression(e.Condition)
+		p.write(") ")
+		p.block(e.Body)
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(e.Body)
+		if e.Catch != nil {
+			p.write(" catch (" + e.Parameter.Value + ") ")
+			p.block(e.Catch)
+		}
+		if e.Finally != nil {
+			p.write(" finally ")
+			p.block(e.Finally)
+		}
+	}
+}
+
+func (p *printer) label(label *ast.Identifier) {
+	if label != nil {
+		p.write(label.Value + ": ")
+	}
+}
+
+// jumpLabel returns how the label of a break or a continue statement is
+// written after it.
+func jumpLabel(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return " " + label.Value
+}
+
+func (p *printer) parameters(parameters []*ast.Identifier) {
+	names := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		names[i] = declaration(parameter)
+	}
+
+	p.write("(" + strings.Join(names, ", ") + ")")
+}
+
+// declaration returns how a declared name is written, with its annotation.
+// Annotations are printed the way their String method writes them.
+func declaration(name *ast.Identifier) string {
+	if name.Type == nil {
+		return name.Value
+	}
+
+	return name.Value + ": " + name.Type.String()
+}
+
+// binding returns how tightly an expression holds together as an operand.
+// Assignments take everything to their right, so they bind the least.
+func binding(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.AssignExpression:
+		return parser.LOWEST
+	default:
+		return parser.ASSIGN + 1
+	}
+}
+
+func needsParens(e ast.Expression, precedence int) bool {
+	return binding(e) < precedence
+}
+
+// opening returns the first character e is printed with.
+func opening(e ast.Expression) byte {
+	operand := func(e ast.Expression, precedence int) byte {
+		if needsParens(e, precedence) {
+			return '('
+		}
+		return opening(e)
+	}
+
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		return e.Operator[0]
+	case *ast.InfixExpression:
+		return operand(e.Left, parser.Precedence(e.Token.Type))
+	case *ast.AssignExpression:
+		return operand(e.Left, parser.CALL)
+	case *ast.CallExpression:
+		return operand(e.Function, parser.CALL)
+	case *ast.IndexExpression:
+		return operand(e.Left, parser.CALL)
+	case *ast.SliceExpression:
+		return operand(e.Left, parser.CALL)
+	case *ast.MemberExpression:
+		return operand(e.Object, parser.CALL)
+	case *ast.StringLiteral:
+		return '"'
+	case *ast.RuneLiteral:
+		return '\''
+	default:
+		return e.TokenLiteral()[0]
+	}
+}
+
+func before(a, b token.TokenMetadata) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// dump describes a program without positions and comments, so that the
+// programs before and after formatting can be compared.
+func dump(node ast.Node) string {
+	var out strings.Builder
+	dumpValue(&out, reflect.ValueOf(node))
+	return out.String()
+}
+
+var (
+	metadataType  = reflect.TypeOf(token.TokenMetadata{})
+	syntaxType    = reflect.TypeOf(ast.Syntax{})
+	statementType = reflect.TypeOf(ast.ExpressionStatement{})
+)
+
+func dumpValue(out *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			out.WriteString("nil")
+			return
+		}
+		dumpValue(out, v.Elem())
+	case reflect.Struct:
+		if v.Type() == metadataType || v.Type() == syntaxType {
+			return
+		}
+
+		out.WriteString(v.Type().Name() + "{")
+		for i := 0; i < v.NumField(); i++ {
+			// the first token of a statement may be a parenthesis
+			if v.Type() == statementType && v.Type().Field(i).Name == "Token" {
+				continue
+			}
+			dumpValue(out, v.Field(i))
+			out.WriteString(" ")
+		}
+		out.WriteString("}")
+	case reflect.Slice:
+		out.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			dumpValue(out, v.Index(i))
+			out.WriteString(" ")
+		}
+		out.WriteString("]")
+	case reflect.Map:
+		// the pairs of hash literals are not ordered
+		var pairs []string
+		for iter := v.MapRange(); iter.Next(); {
+			var pair strings.Builder
+			dumpValue(&pair, iter.Key())
+			pair.WriteString(": ")
+			dumpValue(&pair, iter.Value())
+			pairs = append(pairs, pair.String())
+		}
+		sort.Strings(pairs)
+		out.WriteString("{" + strings.Join(pairs, ", ") + "}")
+	default:
+		fmt.Fprintf(out, "%q", fmt.Sprint(v.Interface()))
+	}
+}
+
+// commentTexts returns the text of the comments in a program, sorted.
+func commentTexts(program *ast.Program) []string {
+	var texts []string
+	ast.Walk(program, func(node ast.Node) bool {
+		for _, c := range all(node.Source()) {
+			texts = append(texts, comment(c))
+		}
+		return true
+	})
+
+	sort.Strings(texts)
+	return texts
+}
@@ -0,0 +1,268 @@
+package format
+
+import (
+	"bytes"
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let  a=(1+2)*3;let b = 1-(2-3) ; let c = -(a+b)",
+			"let a = (1 + 2) * 3;\nlet b = 1 - (2 - 3);\nlet c = -(a + b);\n",
+		},
+		{
+			"let d = -(-a); (-f)(1); (a = 1) + 2;x=y=1; a.b.c = d[0](1)[2]; !!x; -!x; i++ + j--",
+			"let d = -(-a);\n(-f)(1);\n(a = 1) + 2;\nx = y = 1;\na.b.c = d[0](1)[2];\n!!x;\n-!x;\ni++ + j--;\n",
+		},
+		{
+			"let add = fn(a,b){\nlet sum = a+b\nsum\n}\nadd(1, 2)",
+			"let add = fn(a, b) {\n  let sum = a + b;\n  sum;\n};\nadd(1, 2);\n",
+		},
+		{
+			// blocks on one line stay there, without a semicolon
+			"map(arr, fn(x) { x * 2; }); let f = fn() { x; y }",
+			"map(arr, fn(x) { x * 2 });\nlet f = fn() {\n  x;\n  y;\n};\n",
+		},
+		{
+			// a semicolon after a block only where the next statement would continue it
+			"if (a) { b };\n(c + d) * 2\nif (a) { b };\nc\nfor (let i=0;i<3;i++){i};\n[1]\ntry { x } catch (e) { y } finally { z }",
+			"if (a) { b };\n(c + d) * 2;\nif (a) { b }\nc;\nfor (let i = 0; i < 3; i++) { i };\n[1];\ntry { x } catch (e) { y } finally { z }\n",
+		},
+		{
+			"if (x) {\n  1\n} else { 2 }\ntry {\n throw \"a\"\n} catch (e) {\n}",
+			"if (x) {\n  1;\n} else { 2 }\ntry {\n  throw \"a\";\n} catch (e) {}\n",
+		},
+		{
+			"import \"lib.sl\" as lib\nimport \"other.sl\"",
+			"import \"lib.sl\" as lib;\nimport \"other.sl\";\n",
+		},
+		{
+			"print(\"a\\\"b\", '\\'', '\\\\', 'x', 1.5, true)",
+			"print(\"a\\\"b\", '\\'', '\\\\', 'x', 1.5, true);\n",
+		},
+		{
+			// hashes keep the order of the source
+			"let h = {\"b\": 1, \"a\": 2, 3: [ ]}; let e = {}",
+			"let h = {\"b\": 1, \"a\": 2, 3: []};\nlet e = {};\n",
+		},
+		{
+			// literals spanning lines get one element per line
+			"let g = {\n\"x\": 1,\n\"y\": [1,\n2]}",
+			"let g = {\n  \"x\": 1,\n  \"y\": [\n    1,\n    2\n  ]\n};\n",
+		},
+		{
+			"let m = magic(a) { quote(unquote(a) + 1) };",
+			"let m = magic(a) { quote(unquote(a) + 1) };\n",
+		},
+		{
+			"let x:int=1; let f = fn(a : [int],b):{string:fn(int):bool} { {} }; let g = fn() : int { 1 }",
+			"let x: int = 1;\nlet f = fn(a: [int], b): {string: fn(int): bool} { {} };\nlet g = fn(): int { 1 };\n",
+		},
+		{
+			"a[1 : 2]; a[:n+1]; a[ 1: ]; a[:]",
+			"a[1:2];\na[:n + 1];\na[1:];\na[:];\n",
+		},
+		{
+			"a%b*c; (a*b)%c; a**(b**c); (a**b)**c; (-a)**b; -(a**b); a**-b",
+			"a % b * c;\na * b % c;\na ** b ** c;\n(a ** b) ** c;\n(-a) ** b;\n-a ** b;\na ** (-b);\n",
+		},
+		{
+			"for(x in xs){if (x) { continue }\nbreak}\nfor (k,v in h) { k };\n[1]",
+			"for (x in xs) {\n  if (x) { continue; }\n  break;\n}\nfor (k, v in h) { k };\n[1];\n",
+		},
+		{
+			"outer:while(a<b){for(x in xs){break  outer}\ncontinue outer}",
+			"outer: while (a < b) {\n  for (x in xs) { break outer; }\n  continue outer;\n}\n",
+		},
+		{"", ""},
+		{"\n\n", ""},
+	}
+
+	for _, tt := range tests {
+		formatted := format(t, tt.input)
+		if formatted != tt.expected {
+			t.Errorf("wrong format of %q.\nwant:\n%s\ngot:\n%s", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"// header\n\n\n// second\nlet x = 1; // trailing   \n\n\n\nlet y = 2\n// last",
+			"// header\n\n// second\nlet x = 1; // trailing\n\nlet y = 2;\n// last\n",
+		},
+		{
+			"let f = fn(a) { // opening\n  // inside\n  a\n\n  // end\n}",
+			"let f = fn(a) { // opening\n  // inside\n  a;\n\n  // end\n};\n",
+		},
+		{
+			"let g = fn() {\n// todo\n}",
+			"let g = fn() {\n  // todo\n};\n",
+		},
+		{
+			"let h = {\n\"x\": 1, // one\n// before y\n\"y\": 2\n}",
+			"let h = {\n  \"x\": 1, // one\n  // before y\n  \"y\": 2\n};\n",
+		},
+		{
+			// comments that have no line of their own go before the statement
+			"let a = 1;\nf(1, // one\n 2) // two",
+			"let a = 1;\n// one\nf(1, 2); // two\n",
+		},
+		{"// only", "// only\n"},
+	}
+
+	for _, tt := range tests {
+		formatted := format(t, tt.input)
+		if formatted != tt.expected {
+			t.Errorf("wrong format of %q.\nwant:\n%s\ngot:\n%s", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+// format formats input, checking that formatting again changes nothing and
+// that the program is the same.
+func format(t *testing.T, input string) string {
+	t.Helper()
+
+	formatted, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("formatting %q failed: %s", input, err)
+	}
+
+	again, err := Source(formatted)
+	if err != nil {
+		t.Fatalf("formatting the result of %q failed: %s", input, err)
+	}
+
+	if !bytes.Equal(again, formatted) {
+		t.Errorf("formatting %q is not idempotent.\nonce:\n%s\ntwice:\n%s", input, formatted, again)
+	}
+
+	if parse(t, string(formatted)) != parse(t, input) {
+		t.Errorf("formatting %q changed the program to %q", input, formatted)
+	}
+
+	for _, comment := range comments(input) {
+		if !strings.Contains(string(formatted), comment) {
+			t.Errorf("formatting %q lost the comment %q", input, comment)
+		}
+	}
+
+	return string(formatted)
+}
+
+func parse(t *testing.T, input string) string {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q has errors: %v", input, p.Errors()[0])
+	}
+
+	return dump(program)
+}
+
+func comments(input string) []string {
+	var comments []string
+	for _, line := range strings.Split(input, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 && !strings.Contains(line[:i], "\"") {
+			comments = append(comments, strings.TrimSpace(line[i:]))
+		}
+	}
+	return comments
+}
+
+func TestSamples(t *testing.T) {
+	files, err := filepath.Glob("../samples/*.sl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		format(t, string(src))
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	_, err := Source([]byte("let = 1;\nlet y = ;"))
+
+	syntax, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("wrong error. got=%v", err)
+	}
+
+	if len(syntax.Errors) != 2 {
+		t.Errorf("wrong number of errors. got=%v", syntax.Errors)
+	}
+
+	if err.Error() != "1:5: expected next token to be IDENT, got = instead (and 1 more errors)" {
+		t.Errorf("wrong message. got=%q", err.Error())
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	formatted := filepath.Join(dir, "formatted.sl")
+	unformatted := filepath.Join(dir, "nested", "unformatted.sl")
+	broken := filepath.Join(dir, "broken.txt")
+
+	os.Mkdir(filepath.Join(dir, "nested"), 0o755)
+	os.WriteFile(formatted, []byte("let x = 1;\n"), 0o644)
+	os.WriteFile(unformatted, []byte("let  x=1"), 0o644)
+	os.WriteFile(broken, []byte("let = 1"), 0o644)
+
+	run := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// files that aren't .sl are only formatted when given
+	code, stdout, stderr := run("", "--check", dir)
+	if code != 1 || stdout != unformatted+"\n" || stderr != "" {
+		t.Errorf("wrong check. got=%d %q %q", code, stdout, stderr)
+	}
+
+	code, _, stderr = run("", "--check", broken)
+	if code != 1 || stderr != broken+":1:5: expected next token to be IDENT, got = instead\n" {
+		t.Errorf("wrong check of a broken file. got=%d %q", code, stderr)
+	}
+
+	if code, _, stderr := run("", "--write", dir); code != 0 || stderr != "" {
+		t.Errorf("wrong write. got=%d %q", code, stderr)
+	}
+
+	if src, _ := os.ReadFile(unformatted); string(src) != "let x = 1;\n" {
+		t.Errorf("the file was not formatted. got=%q", src)
+	}
+
+	if code, stdout, _ := run("", "--check", dir); code != 0 || stdout != "" {
+		t.Errorf("files are still unformatted. got=%d %q", code, stdout)
+	}
+
+	if code, stdout, _ := run("let  y=2"); code != 0 || stdout != "let y = 2;\n" {
+		t.Errorf("wrong format of the input. got=%d %q", code, stdout)
+	}
+
+	if code, _, _ := run("", "--check", "--write", dir); code != 2 {
+		t.Errorf("wrong code for conflicting flags. got=%d", code)
+	}
+}
@@ -0,0 +1,56 @@
+// Package framing reads and writes the messages of the Language Server
+// Protocol and the Debug Adapter Protocol, which both prefix their JSON
+// content with a Content-Length header.
+package framing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// MaxContentLength is the size of the largest message read, so a broken
+// or hostile client cannot make a server allocate any amount of memory.
+const MaxContentLength = 64 << 20
+
+// ReadMessage reads the content of a message, which is prefixed by a
+// Content-Length header.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	if length > MaxContentLength {
+		return nil, fmt.Errorf("Content-Length %d is larger than %d", length, MaxContentLength)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// WriteMessage writes message as JSON, prefixed by its Content-Length.
+func WriteMessage(w io.Writer, message any) error {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	return err
+}
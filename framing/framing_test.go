@@ -0,0 +1,51 @@
+package framing
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the content, or the error
+	}{
+		{"Content-Length: 2\r\n\r\n{}", "{}"},
+		{"Content-Type: application/json\r\nContent-Length: 4\r\n\r\nnull", "null"},
+		{"Content-Length: x\r\n\r\n{}", `invalid Content-Length "x"`},
+		{"Content-Length: -1\r\n\r\n{}", `invalid Content-Length "-1"`},
+		{"\r\n{}", `invalid Content-Length ""`},
+		{fmt.Sprintf("Content-Length: %d\r\n\r\n{}", MaxContentLength+1),
+			fmt.Sprintf("Content-Length %d is larger than %d", MaxContentLength+1, MaxContentLength)},
+		{"Content-Length: 5\r\n\r\n{}", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		content, err := ReadMessage(bufio.NewReader(strings.NewReader(tt.input)))
+		got := string(content)
+		if err != nil {
+			got = err.Error()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%q: want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	var out strings.Builder
+	if err := WriteMessage(&out, map[string]any{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ReadMessage(bufio.NewReader(strings.NewReader(out.String())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `{"id":1}` {
+		t.Errorf("wrong content. got=%q", content)
+	}
+}
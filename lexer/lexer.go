@@ -13,10 +13,19 @@ type lexer struct {
 	ch           rune // current rune under examination
 	column       int  // current column in input
 	line         int  // current line in input
+	comments     bool // whether comments are tokens or skipped
 }
 
 func New(input string) Lexer {
-	l := &lexer{input: []rune(input)}
+	l := &lexer{input: []rune(input), line: 1}
+	l.readChar()
+	return l
+}
+
+// NewWithComments creates a lexer that returns comments as COMMENT tokens,
+// for tools that keep them, instead of skipping them.
+func NewWithComments(input string) Lexer {
+	l := &lexer{input: []rune(input), line: 1, comments: true}
 	l.readChar()
 	return l
 }
@@ -26,12 +35,15 @@ func (l *lexer) NextToken() token.Token {
 
 	l.skipWhitespace()
 
+	// tokens are located by their first character
+	start := l.pos()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
 
 		} else {
 			tok = l.newToken(token.ASSIGN, l.ch)
@@ -40,7 +52,7 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '&' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.ILLEGAL, l.ch)
 		}
@@ -48,7 +60,7 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '|' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.ILLEGAL, l.ch)
 		}
@@ -64,7 +76,7 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '+' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.INC, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.INC, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.PLUS, l.ch)
 		}
@@ -76,28 +88,39 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '-' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.DEC, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.DEC, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.MINUS, l.ch)
 		}
 	case '\'':
 		tok.Literal, tok.Type = l.readRune()
-		tok.Metadata = token.TokenMetadata{Line: l.line, Column: l.column}
+	case '.':
+		tok = l.newToken(token.DOT, l.ch)
 	case ':':
 		tok = l.newToken(token.COLON, l.ch)
 	case '/':
 		if l.peekChar() == '/' {
-			l.skipComment()
+			comment := l.readComment()
+			if l.comments {
+				return token.Token{Type: token.COMMENT, Literal: comment, Metadata: start, End: l.pos()}
+			}
 			return l.NextToken()
 		}
 		tok = l.newToken(token.SLASH, l.ch)
 	case '*':
-		tok = l.newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: "**"}
+		} else {
+			tok = l.newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = l.newToken(token.PERCENT, l.ch)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.BANG, l.ch)
 		}
@@ -105,7 +128,7 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.LTE, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.LTE, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.LT, l.ch)
 		}
@@ -113,52 +136,58 @@ func (l *lexer) NextToken() token.Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = token.Token{Type: token.GTE, Literal: string(ch) + string(l.ch), Metadata: token.TokenMetadata{Line: l.line, Column: l.column}}
+			tok = token.Token{Type: token.GTE, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = l.newToken(token.GT, l.ch)
 		}
 	case '"':
 		tok.Literal, tok.Type = l.readString()
-		tok.Metadata = token.TokenMetadata{Line: l.line, Column: l.column}
 	case '[':
 		tok = l.newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = l.newToken(token.RBRACKET, l.ch)
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		return token.Token{Type: token.EOF, Literal: "", Metadata: start, End: start}
 	default:
 		if isLetter(l.ch) { // TODO: isLetter() to support unicode
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Metadata, tok.End = start, l.pos()
 			return tok
 		}
 
 		if isDigit(l.ch) { // TODO: isDigit() to support unicode
 			tok.Literal, tok.Type = l.readNumber()
+			tok.Metadata, tok.End = start, l.pos()
 			return tok
 		}
 
 		tok = l.newToken(token.ILLEGAL, l.ch)
 	}
 
+	tok.Metadata = start
 	l.readChar()
+	tok.End = l.pos()
 	return tok
 }
 
-func (l *lexer) skipComment() {
+// pos is the position of the current character.
+func (l *lexer) pos() token.TokenMetadata {
+	return token.TokenMetadata{Line: l.line, Column: l.column}
+}
+
+// readComment reads a comment up to the end of its line.
+func (l *lexer) readComment() string {
+	position := l.position
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	return string(l.input[position:l.position])
 }
 
 func (l *lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		if l.ch == '\n' {
-			l.line += 1
-			l.column = 0
-		}
-		l.readChar() // TODO: handle \r\n
+		l.readChar()
 	}
 }
 
@@ -266,6 +295,12 @@ func (l *lexer) readRune() (string, token.TokenType) {
 }
 
 func (l *lexer) readChar() {
+	// lines are counted here, so strings spanning lines are counted too
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -276,3 +311,35 @@ func (l *lexer) readChar() {
 	l.readPosition += 1
 	l.column += 1
 }
+
+// Unescape resolves the escape sequences kept verbatim in a STRING token
+// literal (e.g. \n, \t, \r, \" and \\). Unknown sequences are left as is.
+func Unescape(literal string) string {
+	var out []rune
+
+	input := []rune(literal)
+	for i := 0; i < len(input); i++ {
+		if input[i] != '\\' || i+1 >= len(input) {
+			out = append(out, input[i])
+			continue
+		}
+
+		switch input[i+1] {
+		case 'n':
+			out = append(out, '\n')
+		case 't':
+			out = append(out, '\t')
+		case 'r':
+			out = append(out, '\r')
+		case '"':
+			out = append(out, '"')
+		case '\\':
+			out = append(out, '\\')
+		default:
+			out = append(out, input[i], input[i+1])
+		}
+		i++
+	}
+
+	return string(out)
+}
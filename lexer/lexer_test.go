@@ -34,6 +34,9 @@ if (5 < 10) {
 {"foo": "bar"}
 magic(x, y) { x + y; };
 true && false || true;
+import "lib.sl" as lib;
+lib.x
+7 % 2 ** 3 * 4
 `
 
 	tests := []struct {
@@ -145,6 +148,21 @@ true && false || true;
 		{token.OR, "||"},
 		{token.TRUE, "true"},
 		{token.SEMICOLON, ";"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib.sl"},
+		{token.IDENT, "as"},
+		{token.IDENT, "lib"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "lib"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "7"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "3"},
+		{token.ASTERISK, "*"},
+		{token.INT, "4"},
 		{token.EOF, ""},
 	}
 
@@ -164,3 +182,95 @@ true && false || true;
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let ab = 10 == x;
+  "two
+lines" + 'c'`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"ab", 1, 5},
+		{"=", 1, 8},
+		{"10", 1, 10},
+		{"==", 1, 13},
+		{"x", 1, 16},
+		{";", 1, 17},
+		{"two\nlines", 2, 3},
+		{"+", 3, 8},
+		{"c", 3, 10},
+		{"", 3, 13},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Metadata.Line != tt.expectedLine || tok.Metadata.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - %q at wrong position. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn,
+				tok.Metadata.Line, tok.Metadata.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// first
+let x = 1; // second
+x / 2 //`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.COMMENT, "// first", 1, 1},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "x", 2, 5},
+		{token.ASSIGN, "=", 2, 7},
+		{token.INT, "1", 2, 9},
+		{token.SEMICOLON, ";", 2, 10},
+		{token.COMMENT, "// second", 2, 12},
+		{token.IDENT, "x", 3, 1},
+		{token.SLASH, "/", 3, 3},
+		{token.INT, "2", 3, 5},
+		{token.COMMENT, "//", 3, 7},
+		{token.EOF, "", 3, 9},
+	}
+
+	l := NewWithComments(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %q, got=%s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Metadata.Line != tt.expectedLine || tok.Metadata.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - %q at wrong position. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn,
+				tok.Metadata.Line, tok.Metadata.Column)
+		}
+	}
+
+	// the usual lexer skips them
+	l = New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			t.Fatalf("unexpected comment %q", tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package lsp
+
+import (
+	"compiler-book/ast"
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"compiler-book/token"
+	"fmt"
+	"strings"
+)
+
+type definitionKind int
+
+const (
+	VARIABLE definitionKind = iota
+	FUNCTION
+	PARAMETER
+	MODULE
+)
+
+// occurrence is a name written in the source, located by its first
+// character. Columns count runes, like the lexer does.
+type occurrence struct {
+	name  string
+	start token.TokenMetadata
+	end   int // the column after the last character
+}
+
+func newOccurrence(name string, start token.TokenMetadata, length int) occurrence {
+	return occurrence{name: name, start: start, end: start.Column + length}
+}
+
+// contains reports whether the cursor at line and column touches the
+// occurrence, which includes standing right after it.
+func (o occurrence) contains(line, column int) bool {
+	return o.start.Line == line && o.start.Column <= column && column <= o.end
+}
+
+// definition is a name bound by let, a function parameter, a catch clause
+// or an import.
+type definition struct {
+	occurrence
+	kind     definitionKind
+	detail   string        // how the name is shown on hover
+	children []*definition // the definitions inside a function bound by let
+}
+
+// reference is a name used by the program, which resolves to a definition
+// or a builtin.
+type reference struct {
+	occurrence
+	scope      *scope
+	definition *definition
+	builtin    bool
+}
+
+type scope struct {
+	parent *scope
+	names  map[string][]*definition // in the order they are defined
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, names: make(map[string][]*definition)}
+}
+
+// lookup finds the definition of name for a use at start: the latest one
+// before the use in the nearest scope that defines the name. Later ones
+// count too, since functions may use names defined after them.
+func (s *scope) lookup(name string, start token.TokenMetadata) *definition {
+	for ; s != nil; s = s.parent {
+		definitions := s.names[name]
+		if len(definitions) == 0 {
+			continue
+		}
+
+		found := definitions[0]
+		for _, definition := range definitions {
+			if before(definition.start, start) {
+				found = definition
+			}
+		}
+		return found
+	}
+
+	return nil
+}
+
+func before(a, b token.TokenMetadata) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// analysis is what the server knows about a document.
+type analysis struct {
+	program     *ast.Program
+	errors      []*parser.ParseError
+	definitions []*definition
+	references  []*reference
+	symbols     []*definition // the definitions shown in the outline
+
+	scope *scope
+	owner *definition // the function bound by let being analysed
+}
+
+var builtins = map[string]bool{}
+
+func init() {
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+}
+
+// analyze parses text and resolves its names. Broken input still yields
+// the parts that could be parsed.
+func analyze(text string) (a *analysis) {
+	a = &analysis{scope: newScope(nil)}
+
+	defer func() {
+		if r := recover(); r != nil {
+			a.errors = append(a.errors, &parser.ParseError{
+				Message: fmt.Sprintf("internal error: %v", r), Line: 1, Column: 1,
+			})
+		}
+	}()
+
+	p := parser.New(lexer.New(text))
+	a.program = p.ParseProgram()
+	a.errors = p.Errors()
+
+	a.resolve(a.program)
+
+	for _, reference := range a.references {
+		reference.definition = reference.scope.lookup(reference.name, reference.start)
+		reference.builtin = reference.definition == nil && builtins[reference.name]
+	}
+
+	return a
+}
+
+// resolve records the definitions and references of node and its children.
+func (a *analysis) resolve(node ast.Node) {
+	ast.Walk(node, a.visit)
+}
+
+func (a *analysis) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		a.use(node.Value, node.Token.Metadata)
+	case *ast.PostfixExpression:
+		a.use(node.Token.Literal, node.Token.Metadata)
+	case *ast.MemberExpression:
+		// properties are looked up in the object, not in scope
+		a.resolve(node.Object)
+	case *ast.LetStatement:
+		kind, detail := VARIABLE, "let "+node.Name.Value
+		function, isFunction := node.Value.(*ast.FunctionLiteral)
+		if isFunction && function != nil {
+			kind, detail = FUNCTION, signature(node.Name.Value, function.Parameters)
+		}
+
+		definition := a.define(node.Name.Value, node.Name.Token.Metadata, kind, detail)
+
+		// the value sees the name, so functions can call themselves
+		if kind == FUNCTION {
+			owner := a.owner
+			a.owner = definition
+			a.resolve(node.Value)
+			a.owner = owner
+		} else {
+			a.resolve(node.Value)
+		}
+	case *ast.ImportStatement:
+		if node.Alias != nil {
+			a.define(node.Alias.Value, node.Alias.Token.Metadata, MODULE, node.String())
+		} else if node.Path != nil {
+			// the path is the name, quotes included
+			start := node.Path.Token.Metadata
+			definition := a.define(node.Name(), start, MODULE, node.String())
+			definition.end = start.Column + len([]rune(node.Path.Value)) + 2
+		}
+	case *ast.FunctionLiteral:
+		a.function(node.Name, node.Parameters, node.Body)
+	case *ast.MacroLiteral:
+		a.function("", node.Parameters, node.Body)
+	case *ast.BlockStatement:
+		a.enter()
+		for _, statement := range node.Statements {
+			a.resolve(statement)
+		}
+		a.leave()
+	case *ast.ForExpression:
+		a.enter()
+		a.resolve(node.Init)
+		a.resolve(node.Condition)
+		a.resolve(node.Post)
+		a.resolve(node.Body)
+		a.leave()
+	case *ast.WhileExpression:
+		a.resolve(node.Condition)
+		a.resolve(node.Body)
+	case *ast.ForInExpression:
+		a.resolve(node.Iterable)
+		a.enter()
+		for _, variable := range []*ast.Identifier{node.Key, node.Value} {
+			if variable != nil {
+				a.define(variable.Value, variable.Token.Metadata, VARIABLE, "(loop variable) "+variable.Value)
+			}
+		}
+		a.resolve(node.Body)
+		a.leave()
+	case *ast.TryExpression:
+		a.resolve(node.Body)
+		a.enter()
+		if node.Parameter != nil {
+			a.define(node.Parameter.Value, node.Parameter.Token.Metadata, PARAMETER, "(error) "+node.Parameter.Value)
+		}
+		a.resolve(node.Catch)
+		a.leave()
+		a.resolve(node.Finally)
+	default:
+		return true
+	}
+
+	return false
+}
+
+func (a *analysis) function(name string, parameters []*ast.Identifier, body *ast.BlockStatement) {
+	a.enter()
+	defer a.leave()
+
+	owner := "fn"
+	if name != "" {
+		owner = "fn " + name
+	}
+
+	for _, parameter := range parameters {
+		a.define(parameter.Value, parameter.Token.Metadata, PARAMETER,
+			fmt.Sprintf("(parameter) %s of %s", parameter.Value, owner))
+	}
+
+	if body != nil {
+		for _, statement := range body.Statements {
+			a.resolve(statement)
+		}
+	}
+}
+
+func (a *analysis) enter() {
+	a.scope = newScope(a.scope)
+}
+
+func (a *analysis) leave() {
+	a.scope = a.scope.parent
+}
+
+func (a *analysis) define(name string, start token.TokenMetadata, kind definitionKind, detail string) *definition {
+	definition := &definition{
+		occurrence: newOccurrence(name, start, len([]rune(name))),
+		kind:       kind,
+		detail:     detail,
+	}
+
+	a.scope.names[name] = append(a.scope.names[name], definition)
+	a.definitions = append(a.definitions, definition)
+
+	if kind != PARAMETER {
+		if a.owner != nil {
+			a.owner.children = append(a.owner.children, definition)
+		} else if a.scope.parent == nil {
+			a.symbols = append(a.symbols, definition)
+		}
+	}
+
+	return definition
+}
+
+func (a *analysis) use(name string, start token.TokenMetadata) {
+	a.references = append(a.references, &reference{
+		occurrence: newOccurrence(name, start, len([]rune(name))),
+		scope:      a.scope,
+	})
+}
+
+// at finds the name under the cursor, with the definition it refers to. The
+// definition is nil for builtins and unknown names.
+func (a *analysis) at(line, column int) (*occurrence, *definition, bool) {
+	for _, definition := range a.definitions {
+		if definition.contains(line, column) {
+			return &definition.occurrence, definition, true
+		}
+	}
+
+	for _, reference := range a.references {
+		if reference.contains(line, column) {
+			return &reference.occurrence, reference.definition, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func signature(name string, parameters []*ast.Identifier) string {
+	names := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		names[i] = parameter.Value
+	}
+
+	return fmt.Sprintf("fn %s(%s)", name, strings.Join(names, ", "))
+}
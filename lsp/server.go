@@ -0,0 +1,384 @@
+package lsp
+
+import (
+	"bufio"
+	"compiler-book/evaluator"
+	"compiler-book/framing"
+	"compiler-book/token"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Server is a language server for Slang. It keeps the documents the editor
+// has open and answers questions about them.
+type Server struct {
+	reader *bufio.Reader
+	out    io.Writer
+
+	documents map[string]*document
+}
+
+// document is an open file, analysed on every change.
+type document struct {
+	uri      string
+	lines    []string
+	analysis *analysis
+}
+
+// NewServer creates a server that reads messages from in and writes
+// responses and notifications to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+	}
+}
+
+// Serve answers messages until the client sends exit or closes the input.
+func (s *Server) Serve() error {
+	for {
+		content, err := framing.ReadMessage(s.reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(&msg)
+		if msg.ID == nil {
+			// notifications have no response
+			continue
+		}
+
+		if err != nil {
+			var responseErr *ResponseError
+			if !errors.As(err, &responseErr) {
+				responseErr = &ResponseError{Code: INTERNAL_ERROR, Message: err.Error()}
+			}
+			framing.WriteMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: responseErr})
+			continue
+		}
+
+		framing.WriteMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+}
+
+// handle answers a message. A panic caused by unexpected input fails the
+// message, not the server.
+func (s *Server) handle(msg *message) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // the full text on every change
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]any{},
+			},
+			"serverInfo": map[string]string{"name": "slang"},
+		}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(params)
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params)
+	default:
+		if strings.HasPrefix(msg.Method, "$/") {
+			// optional notifications, like $/cancelRequest, may be ignored
+			return nil, nil
+		}
+		return nil, &ResponseError{Code: METHOD_NOT_FOUND, Message: fmt.Sprintf("unsupported method %q", msg.Method)}
+	}
+}
+
+func unmarshal(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: INVALID_PARAMS, Message: err.Error()}
+	}
+	return nil
+}
+
+// update analyses the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) {
+	doc := &document{
+		uri:      uri,
+		lines:    strings.Split(text, "\n"),
+		analysis: analyze(text),
+	}
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, err := range doc.analysis.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.wordRange(err.Line, err.Column),
+			Severity: SEVERITY_ERROR,
+			Source:   "slang",
+			Message:  err.Message,
+		})
+	}
+
+	s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: INVALID_PARAMS, Message: fmt.Sprintf("unknown document %s", uri)}
+	}
+
+	return doc, nil
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	line, column := doc.fromPosition(params.Position)
+	found, definition, ok := doc.analysis.at(line, column)
+	if !ok {
+		return nil, nil
+	}
+
+	var detail string
+	switch {
+	case definition != nil:
+		detail = definition.detail
+	case builtins[found.name]:
+		detail = fmt.Sprintf("(builtin) fn %s", found.name)
+	default:
+		return nil, nil
+	}
+
+	r := doc.occurrenceRange(*found)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```slang\n" + detail + "\n```"},
+		Range:    &r,
+	}, nil
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	line, column := doc.fromPosition(params.Position)
+	_, definition, ok := doc.analysis.at(line, column)
+	if !ok || definition == nil {
+		return nil, nil
+	}
+
+	return &Location{URI: doc.uri, Range: doc.occurrenceRange(definition.occurrence)}, nil
+}
+
+func (s *Server) documentSymbols(params DocumentSymbolParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.symbols(doc.analysis.symbols), nil
+}
+
+func (doc *document) symbols(definitions []*definition) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, definition := range definitions {
+		kind := SYMBOL_VARIABLE
+		switch definition.kind {
+		case FUNCTION:
+			kind = SYMBOL_FUNCTION
+		case MODULE:
+			kind = SYMBOL_MODULE
+		}
+
+		r := doc.occurrenceRange(definition.occurrence)
+		symbols = append(symbols, DocumentSymbol{
+			Name:           definition.name,
+			Detail:         definition.detail,
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: r,
+			Children:       doc.symbols(definition.children),
+		})
+	}
+
+	return symbols
+}
+
+// completion offers the builtins, the keywords and the names the document
+// defines.
+func (s *Server) completion(params TextDocumentPositionParams) (any, error) {
+	items := []CompletionItem{}
+	for _, name := range evaluator.BuiltinNames() {
+		items = append(items, CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: "builtin"})
+	}
+
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: COMPLETION_KEYWORD})
+	}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return items, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, definition := range doc.analysis.definitions {
+		if seen[definition.name] {
+			continue
+		}
+		seen[definition.name] = true
+
+		kind := COMPLETION_VARIABLE
+		switch definition.kind {
+		case FUNCTION:
+			kind = COMPLETION_FUNCTION
+		case MODULE:
+			kind = COMPLETION_MODULE
+		}
+
+		items = append(items, CompletionItem{Label: definition.name, Kind: kind, Detail: definition.detail})
+	}
+
+	return items, nil
+}
+
+func (s *Server) notify(method string, params any) {
+	framing.WriteMessage(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// toPosition converts a line and a column of the lexer, both one-based and
+// counting runes, to a protocol position.
+func (doc *document) toPosition(line, column int) Position {
+	if line < 1 || line > len(doc.lines) {
+		return Position{Line: max(line-1, 0)}
+	}
+
+	runes := []rune(doc.lines[line-1])
+	if column-1 > len(runes) {
+		column = len(runes) + 1
+	}
+
+	return Position{Line: line - 1, Character: len(utf16.Encode(runes[:max(column-1, 0)]))}
+}
+
+// fromPosition converts a protocol position to a line and a column of the
+// lexer.
+func (doc *document) fromPosition(position Position) (int, int) {
+	line := position.Line + 1
+	if line < 1 || line > len(doc.lines) {
+		return line, 1
+	}
+
+	units := 0
+	for i, r := range []rune(doc.lines[line-1]) {
+		if units >= position.Character {
+			return line, i + 1
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+
+	return line, len([]rune(doc.lines[line-1])) + 1
+}
+
+func (doc *document) occurrenceRange(o occurrence) Range {
+	return Range{
+		Start: doc.toPosition(o.start.Line, o.start.Column),
+		End:   doc.toPosition(o.start.Line, o.end),
+	}
+}
+
+// wordRange is the range of the word starting at line and column, or of
+// the single character there.
+func (doc *document) wordRange(line, column int) Range {
+	end := column + 1
+	if line >= 1 && line <= len(doc.lines) {
+		runes := []rune(doc.lines[line-1])
+		for end-1 < len(runes) && column-1 < len(runes) && isWordRune(runes[end-1]) && isWordRune(runes[column-1]) {
+			end++
+		}
+	}
+
+	return Range{Start: doc.toPosition(line, column), End: doc.toPosition(line, end)}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
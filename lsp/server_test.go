@@ -0,0 +1,398 @@
+package lsp
+
+import (
+	"bufio"
+	"compiler-book/framing"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const URI = "file:///main.sl"
+
+const program = `import "lib.sl" as lib;
+let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let total = add(1, len([2]));
+let fact = fn(n) {
+  if (n < 2) { return 1; }
+  let n = n - 1;
+  n * fact(n)
+};
+for (let i = 0; i < 3; i++) {
+  total = lib.twice(i);
+}`
+
+// client drives a server through the protocol, like an editor would.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	id       int
+	messages chan map[string]any
+	served   chan error
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, in := io.Pipe()
+	out, serverOut := io.Pipe()
+
+	c := &client{t: t, in: in, messages: make(chan map[string]any, 100), served: make(chan error, 1)}
+
+	go func() {
+		c.served <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.messages)
+
+		reader := bufio.NewReader(out)
+		for {
+			content, err := framing.ReadMessage(reader)
+			if err != nil {
+				return
+			}
+
+			var message map[string]any
+			if err := json.Unmarshal(content, &message); err != nil {
+				t.Errorf("invalid message %s: %s", content, err)
+				return
+			}
+			c.messages <- message
+		}
+	}()
+
+	t.Cleanup(func() { in.Close() })
+
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+
+	if err := framing.WriteMessage(c.in, map[string]any{"jsonrpc": "2.0", "method": method, "params": params}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// request sends a request and returns its response, failing the test if
+// it was not successful.
+func (c *client) request(method string, params any) any {
+	c.t.Helper()
+
+	response := c.send(method, params)
+	if response["error"] != nil {
+		c.t.Fatalf("%s failed: %v", method, response["error"])
+	}
+
+	return response["result"]
+}
+
+func (c *client) send(method string, params any) map[string]any {
+	c.t.Helper()
+
+	c.id++
+	if err := framing.WriteMessage(c.in, map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		message := c.next()
+		if message["id"] == float64(c.id) {
+			return message
+		}
+	}
+}
+
+func (c *client) next() map[string]any {
+	c.t.Helper()
+
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("the server closed")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+
+	return nil
+}
+
+// diagnostics waits for the diagnostics published for URI.
+func (c *client) diagnostics() []any {
+	c.t.Helper()
+
+	for {
+		message := c.next()
+		if message["method"] == "textDocument/publishDiagnostics" {
+			params := message["params"].(map[string]any)
+			if params["uri"] == URI {
+				return params["diagnostics"].([]any)
+			}
+		}
+	}
+}
+
+func (c *client) open(text string) []any {
+	c.t.Helper()
+
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": URI, "languageId": "slang", "version": 1, "text": text},
+	})
+	return c.diagnostics()
+}
+
+func position(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]string{"uri": URI},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	diagnostics := c.open("let x = 1;\nlet = 2;")
+	if len(diagnostics) == 0 {
+		t.Fatalf("wrong number of diagnostics. got=%v", diagnostics)
+	}
+
+	diagnostic := diagnostics[0].(map[string]any)
+	if diagnostic["message"] != "expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong message. got=%v", diagnostic["message"])
+	}
+
+	start := diagnostic["range"].(map[string]any)["start"].(map[string]any)
+	if start["line"] != float64(1) || start["character"] != float64(4) {
+		t.Errorf("wrong position. got=%v", start)
+	}
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": URI, "version": 2},
+		"contentChanges": []any{map[string]string{"text": "let x = 1;\nlet y = 2;"}},
+	})
+	if diagnostics := c.diagnostics(); len(diagnostics) != 0 {
+		t.Errorf("diagnostics were not cleared. got=%v", diagnostics)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{1, 5, "fn add(a, b)"},
+		{2, 12, "(parameter) a of fn add"},
+		{3, 3, "let sum"},
+		{5, 20, "(builtin) fn len"},
+		{5, 13, "fn add(a, b)"},
+		{9, 2, "let n"},
+		{0, 20, `import "lib.sl" as lib;`},
+		{12, 11, `import "lib.sl" as lib;`},
+		{11, 23, "let i"}, // i++
+	}
+
+	for _, tt := range tests {
+		result := c.request("textDocument/hover", position(tt.line, tt.character))
+		if result == nil {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+			continue
+		}
+
+		value := result.(map[string]any)["contents"].(map[string]any)["value"]
+		if value != "```slang\n"+tt.expected+"\n```" {
+			t.Errorf("wrong hover at %d:%d. want=%q, got=%q", tt.line, tt.character, tt.expected, value)
+		}
+	}
+
+	// no hover on keywords, literals and properties
+	for _, p := range [][2]int{{1, 1}, {5, 24}, {12, 15}} {
+		if result := c.request("textDocument/hover", position(p[0], p[1])); result != nil {
+			t.Errorf("unexpected hover at %v. got=%v", p, result)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	tests := []struct {
+		line, character int
+		expected        [2]int // the start of the definition
+	}{
+		{3, 2, [2]int{2, 6}},    // sum
+		{2, 16, [2]int{1, 16}},  // b
+		{5, 12, [2]int{1, 4}},   // add
+		{9, 7, [2]int{6, 4}},    // fact inside itself
+		{7, 6, [2]int{6, 14}},   // the parameter n
+		{9, 2, [2]int{8, 6}},    // the n that shadows it
+		{12, 2, [2]int{5, 4}},   // total
+		{12, 10, [2]int{0, 19}}, // lib
+		{11, 16, [2]int{11, 9}}, // i
+	}
+
+	for _, tt := range tests {
+		result := c.request("textDocument/definition", position(tt.line, tt.character))
+		if result == nil {
+			t.Errorf("no definition at %d:%d", tt.line, tt.character)
+			continue
+		}
+
+		location := result.(map[string]any)
+		start := location["range"].(map[string]any)["start"].(map[string]any)
+		if location["uri"] != URI || start["line"] != float64(tt.expected[0]) || start["character"] != float64(tt.expected[1]) {
+			t.Errorf("wrong definition for %d:%d. want=%v, got=%v", tt.line, tt.character, tt.expected, start)
+		}
+	}
+
+	// builtins have no definition in the document
+	if result := c.request("textDocument/definition", position(5, 20)); result != nil {
+		t.Errorf("unexpected definition of a builtin. got=%v", result)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	result := c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]string{"uri": URI}})
+
+	var describe func(symbols any) string
+	describe = func(symbols any) string {
+		var names []string
+		for _, symbol := range symbols.([]any) {
+			symbol := symbol.(map[string]any)
+			name := symbol["name"].(string)
+			if children, ok := symbol["children"]; ok {
+				name += "(" + describe(children) + ")"
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, " ")
+	}
+
+	if got := describe(result); got != "lib add(sum) total fact(n)" {
+		t.Errorf("wrong symbols. got=%s", got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(program)
+
+	labels := make(map[string]bool)
+	for _, item := range c.request("textDocument/completion", position(13, 0)).([]any) {
+		labels[item.(map[string]any)["label"].(string)] = true
+	}
+
+	for _, expected := range []string{"len", "print", "push", "let", "return", "add", "total", "lib"} {
+		if !labels[expected] {
+			t.Errorf("%s was not offered", expected)
+		}
+	}
+}
+
+func TestPositionsCountUTF16(t *testing.T) {
+	c := newClient(t)
+	c.open("let s = \"😀é\"; let t = s;")
+
+	// the emoji takes two code units, é one
+	result := c.request("textDocument/definition", position(0, 24))
+	if result == nil {
+		t.Fatalf("no definition")
+	}
+
+	r := result.(map[string]any)["range"].(map[string]any)
+	if start := r["start"].(map[string]any); start["character"] != float64(4) {
+		t.Errorf("wrong start. got=%v", start)
+	}
+
+	result = c.request("textDocument/hover", position(0, 20))
+	if result == nil || !strings.Contains(result.(map[string]any)["contents"].(map[string]any)["value"].(string), "let t") {
+		t.Errorf("wrong hover after the emoji. got=%v", result)
+	}
+}
+
+func TestBrokenInput(t *testing.T) {
+	c := newClient(t)
+
+	inputs := []string{
+		"let",
+		"let x = ;",
+		"fn(",
+		"let f = fn(a, { a }",
+		"if (x) { let y = ",
+		"''",
+		"'",
+		"\"unterminated",
+		"import",
+		"import \"a-b.sl\";",
+		"try { 1 }",
+		"try { 1 } catch (",
+		"x.",
+		"[1, 2",
+		"{1: }",
+		"a++ +",
+		"for (let i = 0; i < 3; i++",
+		"magic(",
+		"}}}",
+		"let x = 1; x = ",
+	}
+
+	for _, input := range inputs {
+		diagnostics := c.open(input)
+		if len(diagnostics) == 0 {
+			t.Errorf("no diagnostics for %q", input)
+		}
+
+		for character := 0; character <= len(input)+1; character++ {
+			for _, method := range []string{"textDocument/hover", "textDocument/definition", "textDocument/completion"} {
+				if response := c.send(method, position(0, character)); response["error"] != nil {
+					t.Errorf("%s failed on %q at %d: %v", method, input, character, response["error"])
+				}
+			}
+		}
+
+		c.request("textDocument/documentSymbol", map[string]any{"textDocument": map[string]string{"uri": URI}})
+	}
+
+	// it still works after all that
+	c.open("let x = 1; x")
+	if result := c.request("textDocument/hover", position(0, 11)); result == nil {
+		t.Errorf("no hover after broken input")
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+
+	response := c.send("textDocument/hover", position(0, 0))
+	if response["error"] == nil {
+		t.Errorf("expected an error for an unknown document")
+	}
+
+	response = c.send("workspace/symbol", map[string]any{})
+	if code := response["error"].(map[string]any)["code"]; code != float64(METHOD_NOT_FOUND) {
+		t.Errorf("wrong error code. got=%v", code)
+	}
+
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("serve failed: %s", err)
+	}
+}
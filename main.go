@@ -1,20 +1,98 @@
 package main
 
 import (
+	"compiler-book/checker"
+	"compiler-book/dap"
+	"compiler-book/debugger"
+	"compiler-book/evaluator"
+	"compiler-book/format"
+	"compiler-book/lsp"
 	"compiler-book/repl"
+	"compiler-book/vet"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
 )
 
+// list is a flag that can be given many times, with values separated by
+// commas.
+type list []string
+
+func (l *list) String() string { return strings.Join(*l, ",") }
+
+func (l *list) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
 func main() {
-	// args := os.Args
-	
-	if len(os.Args) == 2 {
-		repl.StartFile(os.Args[1])
+	engine := flag.String("engine", string(repl.EngineEval),
+		"how to run programs: eval (tree-walking evaluator) or vm (bytecode virtual machine)")
+
+	var permissions evaluator.Permissions
+	flag.Var((*list)(&permissions.Read), "allow-read", "let programs read the files in these paths, * for all")
+	flag.Var((*list)(&permissions.Write), "allow-write", "let programs write the files in these paths, * for all")
+	flag.Var((*list)(&permissions.Env), "allow-env", "let programs read these environment variables, * for all")
+	allowAll := flag.Bool("allow-all", false, "let programs read and write every file and environment variable")
+	flag.Parse()
+
+	if *allowAll {
+		permissions = evaluator.AllPermissions()
+	}
+	// the programs run are the user's own, so they import modules from
+	// anywhere, like ../lib.sl in a subdirectory; the flags guard file I/O
+	permissions.Import = []string{evaluator.Everything}
+
+	if *engine != string(repl.EngineEval) && *engine != string(repl.EngineVM) {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, want eval or vm\n", *engine)
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 2 && flag.Arg(0) == "debug" {
+		if *engine != string(repl.EngineEval) {
+			fmt.Fprintln(os.Stderr, "the debugger only supports the eval engine")
+			os.Exit(2)
+		}
+
+		debugger.Start(flag.Arg(1), os.Stdin, os.Stdout)
 		return
 	}
 
+	if flag.NArg() == 1 && flag.Arg(0) == "dap" {
+		if err := dap.Start(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "fmt" {
+		os.Exit(format.Run(flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "check" {
+		os.Exit(checker.Run(flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "vet" {
+		os.Exit(vet.Run(flag.Args()[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
+	if flag.NArg() == 1 && flag.Arg(0) == "lsp" {
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() >= 1 {
+		system := repl.System{Args: flag.Args()[1:], Permissions: permissions}
+		os.Exit(repl.StartFile(flag.Arg(0), repl.Engine(*engine), system, os.Stderr))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -22,5 +100,5 @@ func main() {
 	fmt.Printf("Hello %s! This is the Slang programming language!\n",
 		user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, repl.Engine(*engine), repl.System{Permissions: permissions})
 }
@@ -0,0 +1,39 @@
+package object
+
+import "math"
+
+// IntPow raises base to a non-negative exp by squaring, and reports whether
+// the result fits in an integer. Both engines evaluate ** on integers with
+// it, and raise an OverflowError when it does not.
+func IntPow(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for exp > 0 {
+		var ok bool
+		if exp&1 == 1 {
+			if result, ok = mul(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mul(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// mul multiplies a and b, and reports whether the product fits in an
+// integer.
+func mul(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+
+	product := a * b
+	return product, product/b == a
+}
@@ -0,0 +1,221 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Builtins are the builtins that need nothing from the engine running
+// them, which the evaluator starts its standard builtins from. Their
+// positions are the first OpGetBuiltin operands of the bytecode, so new
+// builtins must be appended at the end.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{"len", &Builtin{Fn: btLen}},
+	{"print", &Builtin{Fn: Print(stdout{})}},
+	{"printf", &Builtin{Fn: Printf(stdout{})}},
+	{"push", &Builtin{Fn: btPush}},
+	{"pop", &Builtin{Fn: btPop}},
+	{"first", &Builtin{Fn: btFirst}},
+	{"rest", &Builtin{Fn: btRest}},
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+func newError(kind ErrorKind, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// Builtins return nil when they have nothing to return, each engine turns
+// it into its own null value.
+
+func btLen(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		// the length of a string is in characters, like its indexes
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	default:
+		return newError(TYPE_ERROR, "argument to `len` not supported, got %s",
+			args[0].Type())
+	}
+}
+
+// stdout writes to os.Stdout, even when it is replaced after Builtins is
+// initialized.
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+// Print returns the print builtin, writing to w.
+func Print(w io.Writer) BuiltinFunction {
+	return func(args ...Object) Object {
+		var out bytes.Buffer
+
+		for _, arg := range args {
+			out.WriteString(arg.Inspect())
+			out.WriteString(" ")
+		}
+
+		fmt.Fprintln(w, out.String())
+
+		return nil
+	}
+}
+
+// Printf returns the printf builtin, writing to w.
+func Printf(w io.Writer) BuiltinFunction {
+	return func(args ...Object) Object {
+		return printf(w, args...)
+	}
+}
+
+func printf(w io.Writer, args ...Object) Object {
+	var opts []any
+
+	if len(args) < 1 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	format := args[0]
+
+	if format.Type() != STRING {
+		return newError(TYPE_ERROR, "argument to `printf` not supported, got %s",
+			format.Type())
+	}
+
+	formatValue := format.(*String).Value
+
+	for _, arg := range args[1:] {
+		switch arg.Type() {
+		case INTEGER:
+			opts = append(opts, arg.(*Integer).Value)
+		case FLOAT:
+			opts = append(opts, arg.(*Float).Value)
+		case STRING:
+			opts = append(opts, arg.(*String).Value)
+		case RUNE:
+			opts = append(opts, arg.(*Rune).Value)
+		case BOOLEAN:
+			opts = append(opts, arg.(*Boolean).Value)
+		default:
+			return newError(TYPE_ERROR, "argument to `printf` not supported, got %s",
+				arg.Type())
+		}
+	}
+
+	unescape := strings.Replace(formatValue, "\\n", "\n", -1) // FIXME: improve this
+	fmt.Fprintf(w, unescape+"\n", opts...)
+
+	return nil
+}
+
+func btPush(args ...Object) Object {
+	if len(args) != 2 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+
+	if args[0].Type() != ARRAY {
+		return newError(TYPE_ERROR, "argument to `push` not supported, got %s",
+			args[0].Type())
+	}
+
+	if args[1] == args[0] {
+		return newError(TYPE_ERROR, "argument to `push` cannot be the same array")
+	}
+
+	array := args[0].(*Array)
+	array.Elements = append(array.Elements, args[1])
+
+	return nil
+}
+
+func btPop(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	if args[0].Type() != ARRAY {
+		return newError(TYPE_ERROR, "argument to `pop` not supported, got %s",
+			args[0].Type())
+	}
+
+	array := args[0].(*Array)
+	length := len(array.Elements)
+
+	if length == 0 {
+		return nil
+	}
+
+	last := array.Elements[length-1]
+	array.Elements = array.Elements[:length-1]
+
+	return last
+}
+
+func btFirst(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	if args[0].Type() != ARRAY {
+		return newError(TYPE_ERROR, "argument to `first` not supported, got %s",
+			args[0].Type())
+	}
+
+	array := args[0].(*Array)
+	length := len(array.Elements)
+
+	if length == 0 {
+		return nil
+	}
+
+	return array.Elements[0]
+}
+
+func btRest(args ...Object) Object {
+	if len(args) != 1 {
+		return newError(ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+
+	if args[0].Type() != ARRAY {
+		return newError(TYPE_ERROR, "argument to `rest` not supported, got %s",
+			args[0].Type())
+	}
+
+	array := args[0].(*Array)
+	length := len(array.Elements)
+
+	if length == 0 {
+		return nil
+	}
+
+	newElements := make([]Object, length-1)
+	copy(newElements, array.Elements[1:length])
+
+	return &Array{Elements: newElements}
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -42,3 +44,20 @@ func (e *Environment) SetOnFound(name string, val Object) (Object, bool) {
 	e.store[name] = val
 	return val, true
 }
+
+// Outer returns the environment e is enclosed in, nil for the outermost one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the sorted names defined in e itself, not in its outer
+// environments.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
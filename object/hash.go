@@ -0,0 +1,246 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values, and remembers the order their keys were first
+// set in, which is the order of its pairs when iterated or printed. The
+// zero value is an empty hash.
+//
+// Keys are the same when they are equal values of the same type, so 1 and
+// 1.0 are different keys, as they are different values to ==. Lookups go
+// through a map from the HashKey of a key to the first pair with it, and
+// pairs whose keys have the same HashKey are chained. Deleting a pair
+// leaves a hole, and the pairs are packed again once they are mostly holes.
+type Hash struct {
+	index   map[HashKey]int // where the chain of every HashKey starts in pairs
+	pairs   []entry         // in insertion order, with a nil key for holes
+	deleted int             // the holes in pairs
+}
+
+// entry is a pair of a hash, in the chain of its HashKey.
+type entry struct {
+	HashPair
+	hashKey HashKey
+	next    int // the next pair with the same HashKey, or -1
+}
+
+// NewHash returns an empty hash with room for size pairs.
+func NewHash(size int) *Hash {
+	return &Hash{index: make(map[HashKey]int, size), pairs: make([]entry, 0, size)}
+}
+
+func (h *Hash) Type() ObjectType { return HASH }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Len returns the number of pairs of h.
+func (h *Hash) Len() int {
+	return len(h.pairs) - h.deleted
+}
+
+// find returns where the pair of a key is in pairs, or -1.
+func (h *Hash) find(key Hashable, hashKey HashKey) int {
+	i, ok := h.index[hashKey]
+	if !ok {
+		return -1
+	}
+
+	for ; i >= 0; i = h.pairs[i].next {
+		if sameKey(h.pairs[i].Key, key) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Get returns the value of a key.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i := h.find(key, key.HashKey())
+	if i < 0 {
+		return nil, false
+	}
+
+	return h.pairs[i].Value, true
+}
+
+// Set sets the value of a key. A key that is already set keeps its place.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.index == nil {
+		h.index = make(map[HashKey]int)
+	}
+
+	hashKey := key.HashKey()
+	if i := h.find(key, hashKey); i >= 0 {
+		h.pairs[i].Value = value
+		return
+	}
+
+	h.link(entry{HashPair: HashPair{Key: key, Value: value}, hashKey: hashKey})
+}
+
+// link appends a pair to pairs, at the start of the chain of its HashKey.
+func (h *Hash) link(e entry) {
+	e.next = -1
+	if i, ok := h.index[e.hashKey]; ok {
+		e.next = i
+	}
+
+	h.index[e.hashKey] = len(h.pairs)
+	h.pairs = append(h.pairs, e)
+}
+
+// Delete removes a key, and reports whether it was set.
+func (h *Hash) Delete(key Hashable) bool {
+	hashKey := key.HashKey()
+
+	i := h.find(key, hashKey)
+	if i < 0 {
+		return false
+	}
+
+	// unlink the pair from its chain
+	if first := h.index[hashKey]; first == i {
+		if h.pairs[i].next < 0 {
+			delete(h.index, hashKey)
+		} else {
+			h.index[hashKey] = h.pairs[i].next
+		}
+	} else {
+		previous := first
+		for h.pairs[previous].next != i {
+			previous = h.pairs[previous].next
+		}
+		h.pairs[previous].next = h.pairs[i].next
+	}
+
+	h.pairs[i] = entry{}
+	h.deleted++
+
+	if h.deleted > len(h.pairs)/2 {
+		h.pack()
+	}
+
+	return true
+}
+
+// pack removes the holes of pairs, and links the chains again.
+func (h *Hash) pack() {
+	entries := h.pairs
+
+	h.index = make(map[HashKey]int, h.Len())
+	h.pairs, h.deleted = make([]entry, 0, h.Len()), 0
+	for _, e := range entries {
+		if e.Key != nil {
+			h.link(e)
+		}
+	}
+}
+
+// Pairs returns the pairs of h in the order their keys were first set.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, h.Len())
+	for _, e := range h.pairs {
+		if e.Key != nil {
+			pairs = append(pairs, e.HashPair)
+		}
+	}
+
+	return pairs
+}
+
+// Key returns obj as a hash key, and reports whether it can be one. Keys
+// are copies of numbers and arrays, so changing the value a key was made
+// of does not change the key. Arrays are keys when all their elements are.
+func Key(obj Object) (Hashable, bool) {
+	return key(obj, make(map[*Array]bool))
+}
+
+// key is Key for the elements of the arrays in seen, which cannot be keys
+// since arrays containing themselves have no end.
+func key(obj Object, seen map[*Array]bool) (Hashable, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return &Integer{Value: obj.Value}, true
+	case *Float:
+		return &Float{Value: obj.Value}, true
+	case *Array:
+		if seen[obj] {
+			return nil, false
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := make([]Object, len(obj.Elements))
+		for i, element := range obj.Elements {
+			k, ok := key(element, seen)
+			if !ok {
+				return nil, false
+			}
+			elements[i] = k
+		}
+
+		return &Array{Elements: elements}, true
+	case Hashable:
+		return obj, true
+	default:
+		return nil, false
+	}
+}
+
+// sameKey reports whether a and b are the same key. Keys of other types are
+// only the same as themselves.
+func sameKey(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && (a.Value == b.Value || math.IsNaN(a.Value) && math.IsNaN(b.Value))
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Rune:
+		b, ok := b.(*Rune)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !sameKey(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
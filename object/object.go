@@ -3,8 +3,11 @@ package object
 import (
 	"bytes"
 	"compiler-book/ast"
+	"compiler-book/code"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"strings"
 )
 
@@ -20,10 +23,15 @@ const (
 	BUILTIN  ObjectType = "BUILTIN"
 	ARRAY    ObjectType = "ARRAY"
 	HASH     ObjectType = "HASH"
+	MODULE   ObjectType = "MODULE"
 
 	QUOTE ObjectType = "QUOTE"
 
+	COMPILED_FUNCTION ObjectType = "COMPILED_FUNCTION"
+
 	RETURN_VALUE ObjectType = "RETURN_VALUE"
+	BREAK        ObjectType = "BREAK"
+	CONTINUE     ObjectType = "CONTINUE"
 	ERROR        ObjectType = "ERROR"
 )
 
@@ -35,6 +43,7 @@ type Object interface {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -115,21 +124,73 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
-// TODO: improve trace
-type Trace struct {
-	Column int
-	Line   int
+// Break leaves the loop whose body is being evaluated, like ReturnValue
+// leaves a function, or the loop around it with a label.
+type Break struct {
+	Label string // empty for the innermost loop
+}
+
+func (b *Break) Type() ObjectType { return BREAK }
+func (b *Break) Inspect() string  { return strings.TrimSpace("break " + b.Label) }
+
+// Continue skips to the next iteration of the loop whose body is being
+// evaluated, or of the loop around it with a label.
+type Continue struct {
+	Label string // empty for the innermost loop
+}
+
+func (c *Continue) Type() ObjectType { return CONTINUE }
+func (c *Continue) Inspect() string  { return strings.TrimSpace("continue " + c.Label) }
+
+// Frame is a position in a function where an error was raised, or where it
+// passed through on its way up the call stack.
+type Frame struct {
+	Function string // the function name, <main> at the top level of a file
+	File     string // empty when the program is not read from a file
+	Line     int
+	Column   int
 }
 
+// ErrorKind classifies errors, so a catch block can tell them apart. The
+// names are stable and part of the language.
+type ErrorKind string
+
+const (
+	// USER_ERROR is the kind of values thrown by user code.
+	USER_ERROR          ErrorKind = "Error"
+	TYPE_ERROR          ErrorKind = "TypeError"
+	NAME_ERROR          ErrorKind = "NameError"
+	INDEX_ERROR         ErrorKind = "IndexError"
+	ARGUMENT_ERROR      ErrorKind = "ArgumentError"
+	ZERO_DIVISION_ERROR ErrorKind = "ZeroDivisionError"
+	IMPORT_ERROR        ErrorKind = "ImportError"
+	STACK_OVERFLOW      ErrorKind = "StackOverflowError"
+	OVERFLOW_ERROR      ErrorKind = "OverflowError"
+	PERMISSION_ERROR    ErrorKind = "PermissionError"
+	IO_ERROR            ErrorKind = "IOError"
+
+	// LIMIT_ERROR is the kind of errors raised when a program goes past the
+	// limits its host set. They cannot be caught.
+	LIMIT_ERROR ErrorKind = "LimitError"
+
+	// EXIT is the kind of the errors exit raises to end the program with a
+	// status. They cannot be caught either.
+	EXIT ErrorKind = "Exit"
+)
+
 type Error struct {
+	Kind    ErrorKind
 	Message string
-	Trace   Trace
+	Trace   []Frame // innermost frame first
+	Status  int     // the exit status of EXIT errors
 }
 
 func (e *Error) Type() ObjectType { return ERROR }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
+	Name       string // empty for anonymous functions
+	File       string // the file the function is defined in
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -183,31 +244,8 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
-type HashPair struct {
-	Key   Object
-	Value Object
-}
-
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (h *Hash) Type() ObjectType { return HASH }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-
-	return out.String()
-}
-
+// HashKey is a hash of a key, which keys equal to it have too. Different
+// keys may have the same HashKey, so hashes compare the keys themselves.
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -229,8 +267,22 @@ func (i *Integer) HashKey() HashKey { // could cache this
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey of a float is the one of its bits. Zero and negative zero are the
+// same key, and so are all NaNs.
 func (f *Float) HashKey() HashKey {
-	return HashKey{Type: f.Type(), Value: uint64(f.Value)}
+	value := f.Value
+	switch {
+	case value == 0:
+		value = 0
+	case math.IsNaN(value):
+		value = math.NaN()
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
+func (r *Rune) HashKey() HashKey {
+	return HashKey{Type: r.Type(), Value: uint64(r.Value)}
 }
 
 func (s *String) HashKey() HashKey {
@@ -240,6 +292,39 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// HashKey of an array combines the ones of its elements. Only arrays
+// returned by Key, whose elements are all keys, are hash keys.
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+
+	var buf [8]byte
+	for _, element := range a.Elements {
+		key := element.(Hashable).HashKey()
+
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf[:], key.Value)
+		h.Write(buf[:])
+	}
+
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
+
+// Module is the namespace an imported file is bound to. Its members are the
+// top-level definitions of the file.
+type Module struct {
+	Name string
+	Path string // the absolute path of the file
+	Env  *Environment
+}
+
+func (m *Module) Type() ObjectType { return MODULE }
+func (m *Module) Inspect() string  { return "module " + m.Name }
+
+// Member returns the top-level definition name of the module.
+func (m *Module) Member(name string) (Object, bool) {
+	return m.Env.Get(name)
+}
+
 type Quote struct {
 	Node ast.Node
 }
@@ -273,3 +358,29 @@ func (m *Macro) Inspect() string {
 
 	return out.String()
 }
+
+// CompiledFunction keeps its parameters and body around so it inspects
+// exactly like a Function from the evaluator.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Parameters    []*ast.Identifier
+	Body          *ast.BlockStatement
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION }
+func (cf *CompiledFunction) Inspect() string {
+	fn := &Function{Parameters: cf.Parameters, Body: cf.Body}
+	return fn.Inspect()
+}
+
+// Closure is the runtime value of a function literal in the virtual machine.
+// It reports itself as a FUNCTION, like its evaluator counterpart.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
     hello1 := &String{Value: "Hello World"}
@@ -20,3 +23,192 @@ func TestStringHashKey(t *testing.T) {
         t.Errorf("strings with different content have same hash keys")
     }
 }
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash(0)
+	for i := int64(0); i < 10; i++ {
+		hash.Set(&Integer{Value: i}, &Integer{Value: i * 10})
+	}
+
+	hash.Set(&Integer{Value: 3}, &String{Value: "three"})
+	for i := int64(0); i < 10; i += 2 {
+		hash.Delete(&Integer{Value: i})
+	}
+	hash.Set(&Integer{Value: 0}, &Integer{Value: 0})
+
+	for _, i := range []int64{1, 5, 7} {
+		hash.Delete(&Integer{Value: i})
+	}
+
+	if hash.Inspect() != "{3: three, 9: 90, 0: 0}" {
+		t.Errorf("wrong pairs. got=%s", hash.Inspect())
+	}
+
+	if hash.Len() != 3 {
+		t.Errorf("wrong length. got=%d", hash.Len())
+	}
+
+	// packed once most of the 11 pairs set were deleted
+	if len(hash.pairs) > 5 {
+		t.Errorf("deleted pairs were not packed. got=%d slots", len(hash.pairs))
+	}
+
+	if value, ok := hash.Get(&Integer{Value: 9}); !ok || value.Inspect() != "90" {
+		t.Errorf("wrong value of 9. got=%v", value)
+	}
+
+	if _, ok := hash.Get(&Integer{Value: 5}); ok {
+		t.Errorf("deleted key 5 is still set")
+	}
+
+	if hash.Delete(&Integer{Value: 5}) {
+		t.Errorf("deleting key 5 again reported it as set")
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	hash := NewHash(0)
+
+	set := func(obj Object, value string) {
+		key, ok := Key(obj)
+		if !ok {
+			t.Fatalf("%s is not a key", obj.Inspect())
+		}
+		hash.Set(key, &String{Value: value})
+	}
+
+	get := func(obj Object) string {
+		key, ok := Key(obj)
+		if !ok {
+			t.Fatalf("%s is not a key", obj.Inspect())
+		}
+		value, ok := hash.Get(key)
+		if !ok {
+			return "unset"
+		}
+		return value.Inspect()
+	}
+
+	set(&Float{Value: 1.2}, "1.2")
+	set(&Float{Value: 1.9}, "1.9")
+	set(&Float{Value: math.Copysign(0, -1)}, "zero")
+	set(&Float{Value: math.NaN()}, "nan")
+	set(&Integer{Value: 1}, "int")
+	set(&Rune{Value: 'a'}, "rune")
+	set(&String{Value: "a"}, "string")
+	set(&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&Rune{Value: 'a'}}}}}, "array")
+
+	tests := []struct {
+		key      Object
+		expected string
+	}{
+		{&Float{Value: 1.2}, "1.2"},
+		{&Float{Value: 1.9}, "1.9"},
+		{&Float{Value: 0}, "zero"},
+		{&Float{Value: math.NaN()}, "nan"},
+		{&Integer{Value: 1}, "int"},
+		{&Float{Value: 1}, "unset"},
+		{&Rune{Value: 'a'}, "rune"},
+		{&String{Value: "a"}, "string"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&Rune{Value: 'a'}}}}}, "array"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}}, "unset"},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, "unset"},
+	}
+
+	for _, tt := range tests {
+		if got := get(tt.key); got != tt.expected {
+			t.Errorf("wrong value of %s. want=%s, got=%s", tt.key.Inspect(), tt.expected, got)
+		}
+	}
+
+	if hash.Len() != 8 {
+		t.Errorf("wrong length. got=%d", hash.Len())
+	}
+}
+
+func TestKeyCopiesArrays(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	key, _ := Key(array)
+
+	array.Elements[0].(*Integer).Value = 2
+	array.Elements = append(array.Elements, &Integer{Value: 3})
+
+	if key.Inspect() != "[1]" {
+		t.Errorf("the key changed with its array. got=%s", key.Inspect())
+	}
+
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+	if _, ok := Key(cyclic); ok {
+		t.Errorf("an array containing itself is a key")
+	}
+
+	if _, ok := Key(&Array{Elements: []Object{&Hash{}}}); ok {
+		t.Errorf("an array containing a hash is a key")
+	}
+}
+
+// collidingKey is a key whose HashKey is the same as every other's.
+type collidingKey struct{ String }
+
+func (k *collidingKey) HashKey() HashKey { return HashKey{Type: STRING} }
+
+func TestHashCollisions(t *testing.T) {
+	hash := NewHash(0)
+	keys := make([]*collidingKey, 5)
+	for i := range keys {
+		keys[i] = &collidingKey{String{Value: string(rune('a' + i))}}
+		hash.Set(keys[i], &Integer{Value: int64(i)})
+	}
+
+	hash.Delete(keys[2])
+	hash.Delete(keys[4])
+	hash.Set(keys[0], &Integer{Value: 10})
+
+	if hash.Inspect() != "{a: 10, b: 1, d: 3}" {
+		t.Errorf("wrong pairs. got=%s", hash.Inspect())
+	}
+
+	for i, expected := range []string{"10", "1", "", "3", ""} {
+		value, ok := hash.Get(keys[i])
+		if ok != (expected != "") || ok && value.Inspect() != expected {
+			t.Errorf("wrong value of key %d. want=%q, got=%v", i, expected, value)
+		}
+	}
+
+	// packing links the chains again
+	hash.Delete(keys[1])
+	if value, ok := hash.Get(keys[3]); !ok || value.Inspect() != "3" {
+		t.Errorf("wrong value of key 3 after packing. got=%v", value)
+	}
+}
+
+func TestIntPow(t *testing.T) {
+	tests := []struct {
+		base, exp, expected int64
+		ok                  bool
+	}{
+		{2, 0, 1, true},
+		{2, 10, 1024, true},
+		{-2, 3, -8, true},
+		{0, 0, 1, true},
+		{0, 100, 0, true},
+		{1, math.MaxInt64, 1, true},
+		{-1, math.MaxInt64, -1, true},
+		{3, 39, 4052555153018976267, true},
+		{2, 62, 1 << 62, true},
+		{-2, 63, math.MinInt64, true},
+		{2, 63, 0, false},
+		{2, 64, 0, false},
+		{3, 40, 0, false},
+		{-3, 41, 0, false},
+		{1 << 32, 2, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := IntPow(tt.base, tt.exp)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("IntPow(%d, %d): want=%d, %t, got=%d, %t", tt.base, tt.exp, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package object
+
+// The slicing of arrays and strings is shared by the evaluator and the
+// virtual machine, so s[low:high] gives the same results on both engines.
+
+// SliceLength returns the length of an array or a string in runes, and
+// whether obj can be sliced at all.
+func SliceLength(obj Object) (int64, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return int64(len(obj.Elements)), true
+	case *String:
+		return int64(len([]rune(obj.Value))), true
+	default:
+		return 0, false
+	}
+}
+
+// SliceBound returns the bound of a slice of something of length given by
+// value, which counts from the end when it is negative. The bound is
+// otherwise when value is nil, because it is left out.
+func SliceBound(value Object, otherwise, length int64) (int64, *Error) {
+	if value == nil {
+		return otherwise, nil
+	}
+
+	integer, ok := value.(*Integer)
+	if !ok {
+		return 0, newError(TYPE_ERROR, "slice index must be an integer, got %s", value.Type())
+	}
+
+	if integer.Value < 0 {
+		return length + integer.Value, nil
+	}
+	return integer.Value, nil
+}
+
+// Slice returns the elements of an array or the runes of a string from low
+// up to high, bounds that are already counted from the start.
+func Slice(obj Object, low, high int64) Object {
+	length, _ := SliceLength(obj)
+	if low < 0 || high > length || low > high {
+		return newError(INDEX_ERROR, "slice bounds out of range: [%d:%d] with length %d",
+			low, high, length)
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, high-low)
+		copy(elements, obj.Elements[low:high])
+		return &Array{Elements: elements}
+	default:
+		runes := []rune(obj.(*String).Value)
+		return &String{Value: string(runes[low:high])}
+	}
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"compiler-book/ast"
+	"compiler-book/token"
+)
+
+// comment is a comment read by the parser, with the tokens around it.
+type comment struct {
+	token.Token
+	previous token.Token // the token before, or the one before its comma
+	line     int         // the line the token before ends on, 0 if none
+	next     token.Token // the token after
+}
+
+// attachComments gives each comment to the node it belongs to:
+//
+//   - a comment after code on its line trails the outermost node ending
+//     with that code
+//   - a comment on a line of its own leads the outermost node starting
+//     right after it
+//   - any other comment is inner to the innermost node around it, like the
+//     comments at the end of a block
+func attachComments(program *ast.Program, comments []*comment) {
+	if len(comments) == 0 {
+		return
+	}
+
+	// parents come before their children
+	var nodes []ast.Node
+	ast.Walk(program, func(node ast.Node) bool {
+		if node != ast.Node(program) {
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+
+	for _, c := range comments {
+		tok := c.Token
+
+		if c.line == tok.Metadata.Line {
+			if node := outermost(nodes, func(s *ast.Syntax) bool { return s.End == c.previous.End }); node != nil {
+				node.Source().Trailing = &tok
+				continue
+			}
+		} else {
+			if node := outermost(nodes, func(s *ast.Syntax) bool { return s.Start == c.next.Metadata }); node != nil {
+				node.Source().Leading = append(node.Source().Leading, tok)
+				continue
+			}
+		}
+
+		var inner ast.Node = program
+		for _, node := range nodes {
+			s := node.Source()
+			if !before(tok.Metadata, s.Start) && before(tok.Metadata, s.End) {
+				inner = node
+			}
+		}
+		inner.Source().Inner = append(inner.Source().Inner, tok)
+	}
+}
+
+func outermost(nodes []ast.Node, matches func(*ast.Syntax) bool) ast.Node {
+	for _, node := range nodes {
+		if matches(node.Source()) {
+			return node
+		}
+	}
+	return nil
+}
+
+func before(a, b token.TokenMetadata) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
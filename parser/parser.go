@@ -18,6 +18,7 @@ const (
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
+	POWER       // **, binds tighter than prefix operators, so -2 ** 2 is -4
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 	ASSIGN      // =
@@ -32,13 +33,26 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 	token.ASSIGN:   ASSIGN,
 	token.AND:      AND,
 	token.OR:       OR,
 }
 
+// Precedence returns how tightly the infix operator t binds, LOWEST if it is
+// not an infix operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
+}
+
 type (
 	prefixParseFn  func() ast.Expression
 	infixParseFn   func(ast.Expression) ast.Expression
@@ -63,6 +77,18 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
+	depth     int  // the braces open before curToken
+	parens    int  // the parentheses open before curToken
+	recovered int  // the errors the parser has recovered from
+	panicking bool // the current statement has an error
+
+	loops []string        // the labels of the loops around curToken in its function, "" for loops without one
+	label *ast.Identifier // the label of the loop about to be parsed
+
+	comments []*comment  // the comments read, attached once the program is parsed
+	previous token.Token // the last token read
+	before   token.Token // the token read before previous
+
 	prefixParseFns  map[token.TokenType]prefixParseFn
 	infixParseFns   map[token.TokenType]infixParseFn
 	postfixParseFns map[token.TokenType]postfixParseFn
@@ -84,6 +110,17 @@ func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// addError records a syntax error at pos. Once a statement has an error,
+// the ones that follow from it in the same statement are not recorded.
+func (p *Parser) addError(msg string, pos token.TokenMetadata) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.errors = append(p.errors, &ParseError{Message: msg, Column: pos.Column, Line: pos.Line})
+}
+
 func New(l lexer.Lexer) *Parser {
 	p := &Parser{l: l}
 
@@ -98,6 +135,8 @@ func New(l lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.RUNE, p.parseRuneLiteral)
@@ -110,12 +149,15 @@ func New(l lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
@@ -132,19 +174,11 @@ func New(l lexer.Lexer) *Parser {
 }
 
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
-	}
-
-	return LOWEST
+	return Precedence(p.peekToken.Type)
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
@@ -175,41 +209,74 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		key := p.parseExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
-			return nil
+			return &ast.BadExpression{Token: hash.Token}
 		}
 
 		p.nextToken()
 
 		value := p.parseExpression(LOWEST)
 
+		hash.Keys = append(hash.Keys, key)
 		hash.Pairs[key] = value
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+			return &ast.BadExpression{Token: hash.Token}
 		}
 
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return &ast.BadExpression{Token: hash.Token}
 	}
 
 	return hash
 }
 
+// BNF: <expression>[<expression>] or <expression>[<expression>? : <expression>?]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		exp.Index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		slice := &ast.SliceExpression{Token: exp.Token, Left: left, Low: exp.Index}
+
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			slice.High = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.RBRACKET) {
+			return &ast.BadExpression{Token: slice.Token}
+		}
+
+		return slice
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		return &ast.BadExpression{Token: exp.Token}
 	}
 
 	return exp
 }
 
+// BNF: <expression>.<identifier>
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return &ast.BadExpression{Token: exp.Token}
+	}
+
+	exp.Property = p.newIdentifier()
+
+	return exp
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -251,16 +318,26 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+
+		lit.ReturnType = p.parseType()
+		if lit.ReturnType == nil {
+			return &ast.BadExpression{Token: lit.Token}
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseLoopFreeBlock()
 
 	return lit
 }
@@ -269,16 +346,16 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 	lit := &ast.MacroLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseLoopFreeBlock()
 
 	return lit
 }
@@ -293,13 +370,20 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := p.parseDeclaration()
+	if ident == nil {
+		return nil
+	}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		ident := p.parseDeclaration()
+		if ident == nil {
+			return nil
+		}
 		identifiers = append(identifiers, ident)
 	}
 
@@ -310,25 +394,116 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseDeclaration parses a name being declared with its optional
+// annotation, like a parameter. It returns nil if the annotation is wrong.
+//
+// BNF: <identifier> [: <type>]
+func (p *Parser) parseDeclaration() *ast.Identifier {
+	ident := p.newIdentifier()
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+
+		if ident.Type = p.parseType(); ident.Type == nil {
+			return nil
+		}
+	}
+
+	return ident
+}
+
+// parseType parses a type annotation, or returns nil after recording an
+// error.
+//
+// BNF: <identifier> | [<type>] | {<type>: <type>} | fn(<comma separated types>) [: <type>]
+func (p *Parser) parseType() ast.Type {
+	start := p.curToken
+
+	var t ast.Type
+	switch p.curToken.Type {
+	case token.IDENT:
+		t = &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		array := &ast.ArrayType{Token: p.curToken}
+
+		p.nextToken()
+		if array.Element = p.parseType(); array.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+
+		t = array
+	case token.LBRACE:
+		hash := &ast.HashType{Token: p.curToken}
+
+		p.nextToken()
+		if hash.Key = p.parseType(); hash.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		if hash.Value = p.parseType(); hash.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+
+		t = hash
+	case token.FUNCTION:
+		function := &ast.FunctionType{Token: p.curToken, Parameters: []ast.Type{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(function.Parameters) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			function.Parameters = append(function.Parameters, param)
+		}
+		p.nextToken()
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if function.Return = p.parseType(); function.Return == nil {
+				return nil
+			}
+		}
+
+		t = function
+	default:
+		p.addError(fmt.Sprintf("expected a type, got %s instead", p.curToken.Type), p.curToken.Metadata)
+		return nil
+	}
+
+	p.span(t, start)
+	return t
+}
+
 func (p *Parser) parseForExpression() ast.Expression {
-	expression := &ast.ForExpression{Token: p.curToken}
+	expression := &ast.ForExpression{Token: p.curToken, Label: p.takeLabel()}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: expression.Token}
 	}
 
+	parens := p.parens
 	p.nextToken()
 
-	expression.Init = &ast.ExpressionStatement{
-		Token:      p.curToken,
-		Expression: p.parseExpression(LOWEST),
+	if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		return p.parseForInExpression(expression.Token, expression.Label, parens)
 	}
 
-	p.nextToken()
+	expression.Init = p.parseStatement()
 
 	if !p.curTokenIs(token.SEMICOLON) {
-		// add error
-		return nil
+		p.peekError(token.SEMICOLON)
+		return p.badForExpression(expression.Token, parens)
 	}
 
 	p.nextToken()
@@ -336,7 +511,7 @@ func (p *Parser) parseForExpression() ast.Expression {
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.SEMICOLON) {
-		return nil
+		return p.badForExpression(expression.Token, parens)
 	}
 
 	p.nextToken()
@@ -344,15 +519,182 @@ func (p *Parser) parseForExpression() ast.Expression {
 	expression.Post = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badForExpression(expression.Token, parens)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badForExpression(expression.Token, parens)
+	}
+
+	expression.Body = p.parseLoopBody(expression.Label)
+
+	return expression
+}
+
+// BNF: for (<identifier> [, <identifier>] in <expression>) <block>
+func (p *Parser) parseForInExpression(tok token.Token, label *ast.Identifier, parens int) ast.Expression {
+	expression := &ast.ForInExpression{Token: tok, Label: label}
+
+	expression.Value = p.newIdentifier()
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return p.badForExpression(tok, parens)
+		}
+
+		expression.Key = expression.Value
+		expression.Value = p.newIdentifier()
+	}
+
+	if !p.expectPeek(token.IN) {
+		return p.badForExpression(tok, parens)
+	}
+
+	p.nextToken()
+
+	expression.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return p.badForExpression(tok, parens)
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return p.badForExpression(tok, parens)
+	}
+
+	expression.Body = p.parseLoopBody(expression.Label)
+
+	return expression
+}
+
+// BNF: while (<expression>) <block>
+func (p *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: p.curToken, Label: p.takeLabel()}
+
+	if !p.expectPeek(token.LPAREN) {
+		return &ast.BadExpression{Token: expression.Token}
+	}
+
+	p.nextToken()
+
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return &ast.BadExpression{Token: expression.Token}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return &ast.BadExpression{Token: expression.Token}
+	}
+
+	expression.Body = p.parseLoopBody(expression.Label)
+
+	return expression
+}
+
+// takeLabel returns the label of the loop being parsed, if it has one.
+func (p *Parser) takeLabel() *ast.Identifier {
+	label := p.label
+	p.label = nil
+
+	return label
+}
+
+// parseLoopBody parses the body of a loop, where break and continue may be
+// used.
+func (p *Parser) parseLoopBody(label *ast.Identifier) *ast.BlockStatement {
+	name := ""
+	if label != nil {
+		name = label.Value
+	}
+
+	p.loops = append(p.loops, name)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+
+	return p.parseBlockStatement()
+}
+
+// parseLoopFreeBlock parses the body of a function or a macro, which cannot
+// break or continue the loops around it.
+func (p *Parser) parseLoopFreeBlock() *ast.BlockStatement {
+	loops := p.loops
+	p.loops = nil
+	defer func() { p.loops = loops }()
+
+	return p.parseBlockStatement()
+}
+
+// BNF: try <block> [catch (<identifier>) <block>] [finally <block>]
+// badForExpression skips the rest of a for loop with a syntax error in its
+// header, whose parenthesis was opened parens deep, so the semicolons of the
+// header don't end the statement early.
+func (p *Parser) badForExpression(tok token.Token, parens int) ast.Expression {
+	for !p.curTokenIs(token.EOF) && !(p.curTokenIs(token.RPAREN) && p.parens == parens+1) {
+		if startsStatement(p.peekToken.Type) {
+			return &ast.BadExpression{Token: tok}
+		}
+		p.nextToken()
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		p.parseBlockStatement()
+	}
+
+	return &ast.BadExpression{Token: tok}
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return &ast.BadExpression{Token: expression.Token}
 	}
 
 	expression.Body = p.parseBlockStatement()
 
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return &ast.BadExpression{Token: expression.Token}
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return &ast.BadExpression{Token: expression.Token}
+		}
+
+		expression.Parameter = p.newIdentifier()
+
+		if !p.expectPeek(token.RPAREN) {
+			return &ast.BadExpression{Token: expression.Token}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return &ast.BadExpression{Token: expression.Token}
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return &ast.BadExpression{Token: expression.Token}
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		msg := "expected catch or finally after try block"
+		p.addError(msg, p.peekToken.Metadata)
+		return &ast.BadExpression{Token: expression.Token}
+	}
+
 	return expression
 }
 
@@ -360,7 +702,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: expression.Token}
 	}
 
 	p.nextToken()
@@ -368,11 +710,11 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: expression.Token}
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return &ast.BadExpression{Token: expression.Token}
 	}
 
 	expression.Consequence = p.parseBlockStatement()
@@ -381,7 +723,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		p.nextToken()
 
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return &ast.BadExpression{Token: expression.Token}
 		}
 
 		expression.Alternative = p.parseBlockStatement()
@@ -398,17 +740,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt, advanced := p.parseStatementOrRecover()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
-		p.nextToken()
+
+		if !advanced {
+			p.nextToken()
+		}
 	}
 
 	if p.curTokenIs(token.EOF) {
-		p.errors = append(p.errors, &ParseError{Message: "unterminated block", Column: p.curToken.Metadata.Column, Line: p.curToken.Metadata.Line})
+		p.addError("unterminated block", p.curToken.Metadata)
 	}
 
+	p.span(block, block.Token)
 	return block
 }
 
@@ -417,12 +763,13 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	start := p.curToken
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{Token: start}
 	}
 
 	return exp
@@ -449,6 +796,11 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.curPrecedence()
 	p.nextToken()
 
+	// ** is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if expression.Token.Type == token.POWER {
+		precedence--
+	}
+
 	expression.Right = p.parseExpression(precedence)
 
 	return expression
@@ -467,6 +819,14 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+// newIdentifier makes the current token an identifier outside of an
+// expression, like the name of a parameter.
+func (p *Parser) newIdentifier() *ast.Identifier {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.span(ident, p.curToken)
+	return ident
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	postfix := p.postfixParseFns[p.peekToken.Type]
 	if postfix != nil {
@@ -477,35 +837,195 @@ func (p *Parser) parseIdentifier() ast.Expression {
 }
 
 func (p *Parser) nextToken() {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		p.depth--
+	case token.LPAREN:
+		p.parens++
+	case token.RPAREN:
+		p.parens--
+	}
+
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.readToken()
 }
 
+// readToken reads the next token. Comments are set aside, to be attached to
+// the tree once it is parsed.
+func (p *Parser) readToken() token.Token {
+	pending := len(p.comments)
+
+	tok := p.l.NextToken()
+	for tok.Type == token.COMMENT {
+		c := &comment{Token: tok, previous: p.previous, line: p.previous.End.Line}
+		if p.previous.Type == token.COMMA {
+			// a comment after a comma is about the element before it
+			c.previous = p.before
+		}
+		p.comments = append(p.comments, c)
+
+		tok = p.l.NextToken()
+	}
+
+	for _, c := range p.comments[pending:] {
+		c.next = tok
+	}
+
+	p.before, p.previous = p.previous, tok
+	return tok
+}
+
+// span records that node was parsed from start up to the current token.
+func (p *Parser) span(node ast.Node, start token.Token) {
+	syntax := node.Source()
+	syntax.Start, syntax.End = start.Metadata, p.curToken.End
+}
+
+// ParseProgram parses the whole input. Every node records its span, and if
+// the lexer returns comments, see lexer.NewWithComments, they are attached to
+// the nodes they belong to.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
+	program.Start = token.TokenMetadata{Line: 1, Column: 1}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
+		stmt, advanced := p.parseStatementOrRecover()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 
-		p.nextToken()
+		if !advanced {
+			p.nextToken()
+		}
 	}
 
+	program.End = p.curToken.End
+	attachComments(program, p.comments)
+
 	return program
 }
 
+// parseStatementOrRecover parses a statement. After a syntax error, the rest
+// of the statement is skipped, so the next one is parsed from its start and
+// the error is reported only once. It reports whether the parser already
+// stands on the first token of the next statement.
+func (p *Parser) parseStatementOrRecover() (ast.Statement, bool) {
+	start, depth, parens := p.curToken, p.depth, p.parens
+
+	// a statement nested in one with an error has errors of its own
+	panicking := p.panicking
+	p.panicking = false
+	defer func() { p.panicking = panicking }()
+
+	// errors nested statements recover from need no recovery here
+	p.recovered = len(p.errors)
+
+	stmt := p.parseStatement()
+	if len(p.errors) == p.recovered {
+		return stmt, false
+	}
+
+	advanced := p.synchronize(start, depth, parens)
+	p.recovered = len(p.errors)
+
+	return stmt, advanced
+}
+
+// synchronize skips tokens up to the end of the statement that started
+// with start, depth braces and parens parentheses deep: its semicolon, the
+// next statement or the end of the enclosing block. Blocks opened on the
+// way are skipped whole. The next statement is one that only a statement
+// can begin with, or a line that begins outside of the parentheses of the
+// broken statement, or with a keyword like if that begins statements of
+// its own. It reports whether the parser ran into the next statement or
+// the end of the block, which then is the current token.
+func (p *Parser) synchronize(start token.Token, depth, parens int) bool {
+	if start.Type == token.RBRACE {
+		// a stray brace is skipped on its own
+		return false
+	}
+
+	// the line of the token before the current one, once one is skipped
+	line := 0
+
+	for !p.curTokenIs(token.EOF) {
+		if p.curToken != start {
+			if p.curTokenIs(token.RBRACE) && p.depth <= depth {
+				return true
+			}
+
+			if p.depth == depth && startsStatement(p.curToken.Type) {
+				return true
+			}
+
+			newLine := line != 0 && p.curToken.Metadata.Line > line
+			if p.depth == depth && newLine && (p.parens <= parens || startsLine(p.curToken.Type)) {
+				return true
+			}
+		}
+
+		if p.depth == depth && p.curTokenIs(token.SEMICOLON) {
+			return false
+		}
+
+		line = p.curToken.End.Line
+		p.nextToken()
+	}
+
+	return true
+}
+
+// startsStatement reports whether t can only begin a statement.
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.IMPORT, token.THROW, token.BREAK, token.CONTINUE:
+		return true
+	default:
+		return false
+	}
+}
+
+// startsLine reports whether t usually begins an expression statement of its
+// own line, even when the line before has parentheses left open.
+func startsLine(t token.TokenType) bool {
+	switch t {
+	case token.IF, token.FOR, token.WHILE, token.TRY:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.IMPORT:
+		stmt = p.parseImportStatement()
+	case token.THROW:
+		stmt = p.parseThrowStatement()
+	case token.BREAK:
+		stmt = p.parseBreakStatement()
+	case token.CONTINUE:
+		stmt = p.parseContinueStatement()
 	default:
-		return p.parseExpressionStatement()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+			stmt = p.parseLabeledStatement()
+		} else {
+			stmt = p.parseExpressionStatement()
+		}
 	}
+
+	p.span(stmt, start)
+	return stmt
 }
 
 // BNF: <expression>;
@@ -523,16 +1043,23 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 func (p *Parser) noPrefixParseFnError(t token.Token) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t.Literal)
-	p.errors = append(p.errors, &ParseError{Message: msg, Column: t.Metadata.Column, Line: t.Metadata.Line})
+	p.addError(msg, t.Metadata)
 }
 
+// parseExpression parses an expression, recording its span. The span of an
+// expression in parentheses includes them.
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	start := p.curToken
+
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken)
-		return nil
+		bad := &ast.BadExpression{Token: p.curToken}
+		p.span(bad, start)
+		return bad
 	}
 	leftExp := prefix()
+	p.span(leftExp, start)
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		// a++ + b++ + c => (a++ + b++) + c
@@ -546,6 +1073,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 
 		leftExp = infix(leftExp)
+		p.span(leftExp, start)
 	}
 
 	return leftExp
@@ -565,24 +1093,156 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-// BNF: let <identifier> = <expression>;
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+// BNF: throw <expression>;
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// BNF: <identifier>: <loop>
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := p.newIdentifier()
+	p.nextToken()
+
+	if !p.peekTokenIs(token.FOR) && !p.peekTokenIs(token.WHILE) {
+		msg := fmt.Sprintf("expected a loop after the label %s, got %s instead", label.Value, p.peekToken.Type)
+		p.addError(msg, p.peekToken.Metadata)
+		return &ast.BadStatement{Token: label.Token}
+	}
+
+	p.nextToken()
+	p.label = label
+
+	return p.parseExpressionStatement()
+}
+
+// BNF: break [<identifier>];
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	stmt.Label = p.parseJumpLabel()
+
+	return stmt
+}
+
+// BNF: continue [<identifier>];
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	stmt.Label = p.parseJumpLabel()
+
+	return stmt
+}
+
+// parseJumpLabel parses the rest of a break or a continue statement: the
+// label of the loop it jumps out of, if one follows on the same line. The
+// statement must be in the body of a loop, with that label if any.
+func (p *Parser) parseJumpLabel() *ast.Identifier {
+	keyword := p.curToken
+
+	var label *ast.Identifier
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Metadata.Line == keyword.Metadata.Line {
+		p.nextToken()
+		label = p.newIdentifier()
+	}
+
+	switch {
+	case len(p.loops) == 0:
+		p.addError(fmt.Sprintf("%s is not in a loop", keyword.Literal), keyword.Metadata)
+	case label != nil && !p.inLoop(label.Value):
+		p.addError(fmt.Sprintf("%s is not in a loop labeled %s", keyword.Literal, label.Value), label.Token.Metadata)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return label
+}
+
+// inLoop reports whether the statement being parsed is in the body of a
+// loop with a label.
+func (p *Parser) inLoop(label string) bool {
+	for _, loop := range p.loops {
+		if loop == label {
+			return true
+		}
+	}
+	return false
+}
+
+// BNF: import <string> [as <identifier>];
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return &ast.BadStatement{Token: stmt.Token}
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	p.span(stmt.Path, p.curToken)
+
+	// 'as' is only special here, so it is not a keyword
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "as" {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return &ast.BadStatement{Token: stmt.Token}
+		}
+
+		stmt.Alias = p.newIdentifier()
+	}
+
+	if name := stmt.Name(); !isIdentifier(name) {
+		msg := fmt.Sprintf("cannot bind module %q to %q, use 'as' to name it", stmt.Path.Value, name)
+		p.addError(msg, stmt.Token.Metadata)
+		return &ast.BadStatement{Token: stmt.Token}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// isIdentifier reports whether name lexes as a single identifier.
+func isIdentifier(name string) bool {
+	tok := lexer.New(name).NextToken()
+	return tok.Type == token.IDENT && tok.Literal == name
+}
+
+// BNF: let <identifier> [: <type>] = <expression>;
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
-		return nil
+		return &ast.BadStatement{Token: stmt.Token}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if stmt.Name = p.parseDeclaration(); stmt.Name == nil {
+		return &ast.BadStatement{Token: stmt.Token}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
-		return nil
+		return &ast.BadStatement{Token: stmt.Token}
 	}
 
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -596,8 +1256,8 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, &ParseError{Message: msg, Column: p.curToken.Metadata.Column, Line: p.curToken.Metadata.Line})
-		return nil
+		p.addError(msg, p.curToken.Metadata)
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
 	lit.Value = value
@@ -611,8 +1271,8 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, &ParseError{Message: msg, Column: p.curToken.Metadata.Column, Line: p.curToken.Metadata.Line})
-		return nil
+		p.addError(msg, p.curToken.Metadata)
+		return &ast.BadExpression{Token: lit.Token}
 	}
 
 	lit.Value = value
@@ -634,7 +1294,7 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 		return true
 	}
 
-	p.peekError(token.IDENT)
+	p.peekError(t)
 	return false
 
 }
@@ -642,5 +1302,5 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, &ParseError{Message: msg, Column: p.peekToken.Metadata.Column, Line: p.peekToken.Metadata.Line})
+	p.addError(msg, p.peekToken.Metadata)
 }
@@ -3,7 +3,9 @@ package parser
 import (
 	"compiler-book/ast"
 	"compiler-book/lexer"
+	"compiler-book/token"
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -648,6 +650,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b ** c",
+			"(a ** (b ** c))",
+		},
+		{
+			"-a ** b",
+			"(-(a ** b))",
+		},
+		{
+			"a ** -b",
+			"(a ** (-b))",
+		},
+		{
+			"a ** b[0] ** f(c)",
+			"(a ** ((b[0]) ** f(c)))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -861,6 +887,68 @@ func TestForExpressionParsing(t *testing.T) {
 	}
 }
 
+func TestForInExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (x in xs) { x }`, "for (x in xs) { x }"},
+		{`for (k, v in h) { k + v }`, "for (k, v in h) { (k + v) }"},
+		{`for (ch in "abc") { break; }`, "for (ch in abc) { break; }"},
+		{`for (x in f(y)) { if (x) { continue } }`, "for (x in f(y)) { ifx continue; }"},
+		{`for (let i = 0; i < 3; i++) { break }`, "for (let i = 0;(i < 3);(i++))  { break; }"},
+		{`while (x < 3) { x++ }`, "while ((x < 3)) { (x++) }"},
+		{`outer: for (x in xs) { while (true) { break outer; continue outer } }`,
+			"outer: for (x in xs) { while (true) { break outer;continue outer; } }"},
+		{"loop: while (a) { break\nb }", "loop: while (a) { break;b }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestForInExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`for (k, 1 in h) { k }`, "expected next token to be IDENT, got INT instead"},
+		{`for (k, v, w in h) { k }`, "expected next token to be IN, got , instead"},
+		{`for (x in xs { x }`, "expected next token to be ), got { instead"},
+		{`break;`, "break is not in a loop"},
+		{`if (x) { continue }`, "continue is not in a loop"},
+		{`for (x in xs) { fn() { break } }`, "break is not in a loop"},
+		{`while (true) { break outer }`, "break is not in a loop labeled outer"},
+		{`outer: for (x in xs) {}; for (y in ys) { continue outer }`, "continue is not in a loop labeled outer"},
+		{`outer: x + 1`, "expected a loop after the label outer, got IDENT instead"},
+		{`while x { 1 }`, "expected next token to be (, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("%s: expected a parser error", tt.input)
+		}
+
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input,
+				tt.expectedMessage, errors[0].Message)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -908,6 +996,30 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -1086,6 +1198,427 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:n + 1]", "(a[:(n + 1)])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"f(a)[-1:][0]", "((f(a)[(-1):])[0])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong slice. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestParsingMemberExpressions(t *testing.T) {
+	input := "lib.math.add(1, 2)"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if stmt.String() != "((lib.math).add)(1, 2)" {
+		t.Errorf("wrong expression. got=%q", stmt.String())
+	}
+
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	member, ok := call.Function.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("function not *ast.MemberExpression. got=%T", call.Function)
+	}
+
+	if member.Property.Value != "add" {
+		t.Errorf("member.Property not %q. got=%q", "add", member.Property.Value)
+	}
+}
+
+func TestTryExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { x } catch (e) { y }`, "try x catch (e) y"},
+		{`try { x } finally { z }`, "try x finally z"},
+		{`try { x } catch (err) { y } finally { z }`, "try x catch (err) y finally z"},
+		{`let a = try { x } catch (e) { 1 };`, "let a = try x catch (e) 1;"},
+		{`throw "boom";`, "throw boom;"},
+		{`throw {"message": m}`, "throw {message:m};"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`try { x }`, "expected catch or finally after try block"},
+		{`try { x } catch { y }`, "expected next token to be (, got { instead"},
+		{`try { x } catch (1) { y }`, "expected next token to be IDENT, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("%s: expected a parser error", tt.input)
+		}
+
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input,
+				tt.expectedMessage, errors[0].Message)
+		}
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedPath string
+		expectedName string
+	}{
+		{`import "functions.sl";`, "functions.sl", "functions"},
+		{`import "lib/strings.sl"`, "lib/strings.sl", "strings"},
+		{`import "../my-lib.sl" as lib;`, "../my-lib.sl", "lib"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("stmt.Path not %q. got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+
+		if stmt.Name() != tt.expectedName {
+			t.Errorf("stmt.Name() not %q. got=%q", tt.expectedName, stmt.Name())
+		}
+	}
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`import "my-lib.sl"`, `cannot bind module "my-lib.sl" to "my-lib", use 'as' to name it`},
+		{`import lib`, "expected next token to be STRING, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("%s: expected a parser error", tt.input)
+		}
+
+		if errors[0].Message != tt.expectedMessage {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input,
+				tt.expectedMessage, errors[0].Message)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedErrors  []string
+		expectedProgram string
+	}{
+		{
+			"let = 1;\nlet y = 2;\nlet = 3;\ny",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"3:5: expected next token to be IDENT, got = instead",
+			},
+			"<bad statement>let y = 2;<bad statement>y",
+		},
+		{
+			"let x = \nlet y = 1;",
+			[]string{"2:1: no prefix parse function for let found"},
+			"let x = <bad expression>;let y = 1;",
+		},
+		{
+			"let f = fn(a) {\n  let = a;\n  a +;\n};\nf(1)",
+			[]string{
+				"2:7: expected next token to be IDENT, got = instead",
+				"3:6: no prefix parse function for ; found",
+			},
+			"let f = fn(a) <bad statement>(a + <bad expression>);f(1)",
+		},
+		{
+			"{1: }; let z = 1;",
+			[]string{"1:5: no prefix parse function for } found"},
+			"<bad expression>let z = 1;",
+		},
+		{
+			"for (let i = 0 i < 3; i++) { i }\nlet w = 1;",
+			[]string{"1:16: expected next token to be ;, got IDENT instead"},
+			"<bad expression>let w = 1;",
+		},
+		{
+			"import lib;\nlet b = 1;",
+			[]string{"1:8: expected next token to be STRING, got IDENT instead"},
+			"<bad statement>let b = 1;",
+		},
+		{
+			"let f = fn(a, { a }\nif (x > ) { print(1) }",
+			[]string{
+				"1:17: expected next token to be ), got IDENT instead",
+				"2:9: no prefix parse function for ) found",
+			},
+			"let f = <bad expression>;<bad expression>",
+		},
+		{
+			"let a = 1 +;\nprint(a)\nfoo(,)",
+			[]string{
+				"1:12: no prefix parse function for ; found",
+				"3:5: no prefix parse function for , found",
+			},
+			"let a = (1 + <bad expression>);print(a)foo(<bad expression>)",
+		},
+		{
+			"let x = ]\nx\ntry { 1 } catch (e) { e }",
+			[]string{"1:9: no prefix parse function for ] found"},
+			"let x = <bad expression>;xtry 1 catch (e) e",
+		},
+		{
+			// a line inside the parentheses of the broken statement is part of it
+			"let x = foo(1 +,\n  2)\nlet y = 1",
+			[]string{"1:16: no prefix parse function for , found"},
+			"let x = foo();let y = 1;",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q: wrong number of errors. want=%d, got=%d (%v)", tt.input,
+				len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+
+		for i, err := range errors {
+			got := fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Message)
+			if got != tt.expectedErrors[i] {
+				t.Errorf("%q: wrong error %d. want=%q, got=%q", tt.input, i,
+					tt.expectedErrors[i], got)
+			}
+		}
+
+		for _, stmt := range program.Statements {
+			if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+				t.Fatalf("%q: program has a nil statement", tt.input)
+			}
+		}
+
+		if program.String() != tt.expectedProgram {
+			t.Errorf("%q: wrong program. want=%q, got=%q", tt.input,
+				tt.expectedProgram, program.String())
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"fn(a: int, b) { a }", "fn(a: int, b) a"},
+		{"fn(x): float { x }", "fn(x): float x"},
+		{"fn(f: fn(int, string): bool, g: fn()): fn(): {int: any} { f }", "fn(f: fn(int, string): bool, g: fn()): fn(): {int: any} f"},
+		{"let x = {a: 1}", "let x = {a:1};"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 1;", "1:8: expected a type, got = instead"},
+		{"fn(a: 1) { a }", "1:7: expected a type, got INT instead"},
+		{"let h: {string} = {};", "1:15: expected next token to be :, got } instead"},
+		{"fn(): [int { 1 }", "1:12: expected next token to be ], got { instead"},
+	}
+
+	for _, tt := range errors {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Errorf("%q: wrong number of errors. got=%v", tt.input, p.Errors())
+			continue
+		}
+
+		err := p.Errors()[0]
+		if got := fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Message); got != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSourceSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+  (a + b) * 2
+};
+add(1, "x")`
+
+	expected := []string{
+		"*ast.Program 1:1-4:12",
+		"*ast.LetStatement 1:1-3:3",
+		"*ast.Identifier 1:5-1:8",
+		"*ast.FunctionLiteral 1:11-3:2",
+		"*ast.Identifier 1:14-1:15",
+		"*ast.Identifier 1:17-1:18",
+		"*ast.BlockStatement 1:20-3:2",
+		"*ast.ExpressionStatement 2:3-2:14",
+		"*ast.InfixExpression 2:3-2:14",
+		"*ast.InfixExpression 2:3-2:10",
+		"*ast.Identifier 2:4-2:5",
+		"*ast.Identifier 2:8-2:9",
+		"*ast.IntegerLiteral 2:13-2:14",
+		"*ast.ExpressionStatement 4:1-4:12",
+		"*ast.CallExpression 4:1-4:12",
+		"*ast.Identifier 4:1-4:4",
+		"*ast.IntegerLiteral 4:5-4:6",
+		"*ast.StringLiteral 4:8-4:11",
+	}
+
+	var spans []string
+	ast.Walk(New(lexer.New(input)).ParseProgram(), func(node ast.Node) bool {
+		s := node.Source()
+		spans = append(spans, fmt.Sprintf("%T %d:%d-%d:%d", node,
+			s.Start.Line, s.Start.Column, s.End.Line, s.End.Column))
+		return true
+	})
+
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("wrong spans.\nwant=%q\ngot=%q", expected, spans)
+	}
+}
+
+func TestCommentAttachment(t *testing.T) {
+	input := `// about x
+// more about x
+let x = 1; // after x
+
+let f = fn(a) { // opening
+  // about a
+  a // after a
+  // closing
+};
+[1, // after 1
+ 2]
+// the end`
+
+	expected := []string{
+		"*ast.Program inner=[// the end]",
+		"*ast.LetStatement leading=[// about x // more about x] trailing=// after x",
+		"*ast.BlockStatement inner=[// opening // closing]",
+		"*ast.ExpressionStatement leading=[// about a] trailing=// after a",
+		"*ast.IntegerLiteral trailing=// after 1",
+	}
+
+	var attached []string
+	ast.Walk(New(lexer.NewWithComments(input)).ParseProgram(), func(node ast.Node) bool {
+		s := node.Source()
+		description := fmt.Sprintf("%T", node)
+		if len(s.Leading) > 0 {
+			description += fmt.Sprintf(" leading=%v", literals(s.Leading))
+		}
+		if s.Trailing != nil {
+			description += " trailing=" + s.Trailing.Literal
+		}
+		if len(s.Inner) > 0 {
+			description += fmt.Sprintf(" inner=%v", literals(s.Inner))
+		}
+
+		if description != fmt.Sprintf("%T", node) {
+			attached = append(attached, description)
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(attached, expected) {
+		t.Errorf("wrong comments.\nwant=%q\ngot=%q", expected, attached)
+	}
+}
+
+func literals(tokens []token.Token) []string {
+	var literals []string
+	for _, tok := range tokens {
+		literals = append(literals, tok.Literal)
+	}
+	return literals
+}
+
 func testCallExpression(t *testing.T, exp ast.Expression, functionName string,
 	args []string) bool {
 
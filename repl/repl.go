@@ -3,10 +3,12 @@ package repl
 import (
 	"bufio"
 	"compiler-book/ast"
+	"compiler-book/compiler"
 	"compiler-book/evaluator"
 	"compiler-book/lexer"
 	"compiler-book/object"
 	"compiler-book/parser"
+	"compiler-book/vm"
 	"fmt"
 	"io"
 	"os"
@@ -14,10 +16,27 @@ import (
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// Engine selects how programs are executed.
+type Engine string
+
+const (
+	// EngineEval walks the AST with the evaluator.
+	EngineEval Engine = "eval"
+	// EngineVM compiles to bytecode and runs it on the virtual machine.
+	EngineVM Engine = "vm"
+)
+
+// System is what the programs run by the REPL get from the system they run
+// on, through builtins.
+type System struct {
+	Args        []string // the arguments of the program
+	Permissions evaluator.Permissions
+}
+
+func Start(in io.Reader, out io.Writer, engine Engine, system System) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
 	macroEnv := object.NewEnvironment()
+	run := newRunner(engine, "", system)
 
 	for {
 		fmt.Fprint(out, PROMPT)
@@ -44,7 +63,17 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(expanded, env)
+		evaluated, err := run(expanded)
+		if err != nil {
+			io.WriteString(out, formatColor(red, err.Error()))
+			io.WriteString(out, "\n")
+			continue
+		}
+
+		if err, ok := evaluated.(*object.Error); ok && err.Kind == object.EXIT {
+			os.Exit(err.Status)
+		}
+
 		if evaluated != nil {
 			color := yellow
 			if evaluated.Type() == object.NULL {
@@ -57,25 +86,82 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func StartFile(filename string) {
-	env := object.NewEnvironment()
+// StartFile runs the program in filename and returns the exit status of
+// the process: the one given to exit, or 1 when the program cannot be read,
+// has syntax errors or fails, whose errors are written to stderr.
+func StartFile(filename string, engine Engine, system System, stderr io.Writer) int {
 	macroEnv := object.NewEnvironment()
-	program := parseFile(filename)
+	program := parseFile(filename, stderr)
 
 	if program == nil {
-		return
+		return 1
 	}
 
 	evaluator.DefineMacros(program, macroEnv)
 	expanded := evaluator.ExpandMacros(program, macroEnv)
 
 	if expanded == nil {
-		return
+		return 0
+	}
+
+	result, err := newRunner(engine, filename, system)(expanded)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
 	}
 
-	err := evaluator.Eval(expanded, env)
-	if isError(err) {
-		fmt.Println(err.Inspect())
+	if err, ok := result.(*object.Error); ok {
+		if err.Kind == object.EXIT {
+			return err.Status
+		}
+		printTraceback(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runner executes a program, keeping the definitions of previous runs.
+type runner func(program ast.Node) (object.Object, error)
+
+// newRunner returns a runner for programs read from filename, which is
+// empty for the REPL.
+func newRunner(engine Engine, filename string, system System) runner {
+	eval := evaluator.New()
+	if filename != "" {
+		eval.SetFilename(filename)
+	}
+	eval.SetArgs(system.Args)
+	eval.SetPermissions(system.Permissions)
+
+	if engine == EngineVM {
+		constants := []object.Object{}
+		globals := vm.NewGlobals()
+		symbolTable := compiler.NewSymbolTableWithBuiltins()
+
+		return func(program ast.Node) (object.Object, error) {
+			comp := compiler.NewWithState(symbolTable, constants)
+			if err := comp.Compile(program); err != nil {
+				return nil, fmt.Errorf("compilation failed: %s", err)
+			}
+
+			bytecode := comp.Bytecode()
+			constants = bytecode.Constants
+
+			machine := vm.NewWithGlobalsState(bytecode, globals)
+			machine.UseBuiltins(eval)
+			if err := machine.Run(); err != nil {
+				return nil, fmt.Errorf("executing bytecode failed: %s", err)
+			}
+
+			return machine.Result(), nil
+		}
+	}
+
+	env := object.NewEnvironment()
+
+	return func(program ast.Node) (object.Object, error) {
+		return eval.Eval(program, env), nil
 	}
 }
 
@@ -86,12 +172,12 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func parseFile(filename string) *ast.Program {
+func parseFile(filename string, stderr io.Writer) *ast.Program {
 	// read file to string
 
 	file, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(stderr, err)
 		return nil
 	}
 
@@ -101,7 +187,7 @@ func parseFile(filename string) *ast.Program {
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
-		printParserErrors(os.Stdout, p.Errors())
+		printParserErrors(stderr, p.Errors())
 		return nil
 	}
 
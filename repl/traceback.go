@@ -0,0 +1,96 @@
+package repl
+
+import (
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/token"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printTraceback prints err with the frames of its trace, outermost first,
+// showing the source line of every frame with the failing token underlined.
+func printTraceback(out io.Writer, err *object.Error) {
+	if len(err.Trace) > 0 {
+		fmt.Fprintln(out, "Traceback (most recent call last):")
+	}
+
+	sources := make(map[string][]string)
+
+	for i := len(err.Trace) - 1; i >= 0; i-- {
+		frame := err.Trace[i]
+
+		fmt.Fprintf(out, "  %s:%d:%d, in %s\n",
+			displayPath(frame.File), frame.Line, frame.Column, frame.Function)
+
+		lines, ok := sources[frame.File]
+		if !ok && frame.File != "" {
+			if source, err := os.ReadFile(frame.File); err == nil {
+				lines = strings.Split(string(source), "\n")
+			}
+			sources[frame.File] = lines
+		}
+
+		if frame.Line < 1 || frame.Line > len(lines) {
+			continue
+		}
+
+		line, column := trimIndent([]rune(lines[frame.Line-1]), frame.Column)
+		if column < 1 || column > len(line) {
+			continue
+		}
+
+		fmt.Fprintf(out, "    %s\n", string(line))
+		fmt.Fprintf(out, "    %s%s\n",
+			strings.Repeat(" ", column-1), strings.Repeat("^", tokenWidth(line[column-1:])))
+	}
+
+	if err.Kind != "" {
+		fmt.Fprintf(out, "%s: %s\n", err.Kind, err.Message)
+	} else {
+		fmt.Fprintln(out, err.Inspect())
+	}
+}
+
+// trimIndent removes the indentation of line, moving column along with it.
+func trimIndent(line []rune, column int) ([]rune, int) {
+	indent := 0
+	for indent < len(line) && (line[indent] == ' ' || line[indent] == '\t') {
+		indent++
+	}
+
+	return []rune(strings.TrimRight(string(line[indent:]), "\r")), column - indent
+}
+
+// tokenWidth is the number of characters of the token source starts with.
+func tokenWidth(source []rune) int {
+	tok := lexer.New(string(source)).NextToken()
+
+	width := len([]rune(tok.Literal))
+	if tok.Type == token.STRING || tok.Type == token.RUNE {
+		width += 2 // the quotes
+	}
+
+	if width < 1 {
+		return 1
+	}
+
+	return width
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<input>"
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+
+	return path
+}
@@ -0,0 +1,96 @@
+package slang
+
+import (
+	"compiler-book/object"
+	"fmt"
+	"reflect"
+)
+
+// newBuiltin wraps the Go function fn in a builtin.
+func newBuiltin(fn interface{}) (*object.Builtin, error) {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return fn, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Fn: fn}, nil
+	case func(args ...object.Object) object.Object:
+		return &object.Builtin{Fn: fn}, nil
+	}
+
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("not a function: %T", fn)
+	}
+
+	t := v.Type()
+	results := t.NumOut()
+	fails := results > 0 && t.Out(results-1) == errorType
+	if fails {
+		results--
+	}
+
+	if results > 1 {
+		return nil, fmt.Errorf("functions may return one value and an error, %s returns %d values", t, t.NumOut())
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		in, err := arguments(t, args)
+		if err != nil {
+			return err
+		}
+
+		out := v.Call(in)
+
+		if fails && !out[len(out)-1].IsNil() {
+			err := out[len(out)-1].Interface().(error)
+			return &object.Error{Kind: object.USER_ERROR, Message: err.Error()}
+		}
+
+		if results == 0 {
+			return nil
+		}
+
+		result, convErr := toObject(out[0])
+		if convErr != nil {
+			return &object.Error{Kind: object.TYPE_ERROR, Message: convErr.Error()}
+		}
+		return result
+	}}, nil
+}
+
+// arguments converts args to the parameter types of the function type t.
+func arguments(t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	params := t.NumIn()
+	if t.IsVariadic() && len(args) < params-1 || !t.IsVariadic() && len(args) != params {
+		want := fmt.Sprint(params)
+		if t.IsVariadic() {
+			want = fmt.Sprintf("at least %d", params-1)
+		}
+
+		return nil, &object.Error{
+			Kind:    object.ARGUMENT_ERROR,
+			Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", len(args), want),
+		}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var param reflect.Type
+		if t.IsVariadic() && i >= params-1 {
+			param = t.In(params - 1).Elem()
+		} else {
+			param = t.In(i)
+		}
+
+		value, err := fromObject(arg, param)
+		if err != nil {
+			return nil, &object.Error{
+				Kind:    object.TYPE_ERROR,
+				Message: fmt.Sprintf("argument %d: %s", i+1, err),
+			}
+		}
+		in[i] = value
+	}
+
+	return in, nil
+}
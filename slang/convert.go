@@ -0,0 +1,486 @@
+package slang
+
+import (
+	"compiler-book/evaluator"
+	"compiler-book/object"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	anyType    = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Slang value:
+//
+//   - nil is null; booleans, integers, floats and strings are themselves,
+//     all integers become int and all floats float
+//   - slices and arrays are arrays, maps are hashes with their keys sorted
+//   - structs are hashes of their exported fields, named by the field name
+//     or by a `slang:"name"` tag; fields tagged `slang:"-"` are left out
+//   - pointers and interfaces are the value they point to, and values
+//     that contain themselves cannot be converted
+//   - functions are builtins, whose arguments are converted to the
+//     parameter types; a last result of type error raises it
+//   - object.Object values are kept as they are
+func ToObject(value interface{}) (object.Object, error) {
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	c := &converter{seen: make(map[visit]bool)}
+	return c.toObject(v)
+}
+
+// converter converts Go values to Slang values, remembering the pointers,
+// maps and slices it is inside of to stop at the values that contain
+// themselves.
+type converter struct {
+	seen map[visit]bool
+}
+
+// visit is a pointer, a map or a slice being converted. Slices can share
+// their pointer with the slices they start, so their length tells them
+// apart.
+type visit struct {
+	ptr    uintptr
+	typ    reflect.Type
+	length int
+}
+
+// enter marks v as being converted, which it must not be already.
+func (c *converter) enter(v reflect.Value) (visit, error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.length = v.Len()
+	}
+
+	if c.seen[key] {
+		return key, fmt.Errorf("cannot convert a %s that contains itself", v.Type())
+	}
+
+	c.seen[key] = true
+	return key, nil
+}
+
+func (c *converter) toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return nativeBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			visited, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(c.seen, visited)
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := c.toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+
+		visited, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c.seen, visited)
+
+		// Go maps have no order, so the keys are sorted to give hashes one
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+		hash := object.NewHash(len(keys))
+		for _, k := range keys {
+			key, err := c.toObject(k)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := c.toObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+
+			if err := setPair(hash, key, value); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &object.Hash{}
+		for _, field := range fields(v.Type()) {
+			value, err := c.toObject(v.FieldByIndex(field.index))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.name, err)
+			}
+
+			setPair(hash, &object.String{Value: field.name}, value)
+		}
+		return hash, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+
+		if v.Kind() == reflect.Pointer {
+			visited, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(c.seen, visited)
+		}
+		return c.toObject(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return newBuiltin(v.Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Slang value", v.Type())
+}
+
+// lessKey orders the keys of a Go map: numbers and strings by value, false
+// before true, and other keys by how they print.
+func lessKey(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Interface {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface {
+		b = b.Elem()
+	}
+
+	if a.Kind() != b.Kind() {
+		return a.Kind() < b.Kind()
+	}
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	default:
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
+// ToValue converts a Slang value to a Go value: null is nil, int is int64,
+// float is float64, string is string, rune is rune and bool is bool.
+// Arrays are []interface{}, hashes are map[string]interface{} when all
+// their keys are strings and map[interface{}]interface{} otherwise, where
+// array keys are Go arrays of type [n]interface{}. Other values, like
+// functions, are returned as they are.
+func ToValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Rune:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = ToValue(element)
+		}
+		return elements
+	case *object.Hash:
+		pairs := obj.Pairs()
+
+		strings := make(map[string]interface{}, len(pairs))
+		for _, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				break
+			}
+			strings[key.Value] = ToValue(pair.Value)
+		}
+
+		if len(strings) == len(pairs) {
+			return strings
+		}
+
+		values := make(map[interface{}]interface{}, len(pairs))
+		for _, pair := range pairs {
+			values[toKey(pair.Key)] = ToValue(pair.Value)
+		}
+		return values
+	default:
+		return obj
+	}
+}
+
+// toKey converts a hash key to a Go value that can be a map key. Slices
+// cannot, so arrays are Go arrays.
+func toKey(obj object.Object) interface{} {
+	array, ok := obj.(*object.Array)
+	if !ok {
+		return ToValue(obj)
+	}
+
+	key := reflect.New(reflect.ArrayOf(len(array.Elements), anyType)).Elem()
+	for i, element := range array.Elements {
+		key.Index(i).Set(reflect.ValueOf(toKey(element)))
+	}
+
+	return key.Interface()
+}
+
+// fromKey converts a hash key to a Go value of type t, the key type of a
+// map. Like in ToValue, arrays are Go arrays when t is interface{}.
+func fromKey(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() != reflect.Interface || t.NumMethod() != 0 {
+		return fromObject(obj, t)
+	}
+
+	key := toKey(obj)
+	if key == nil {
+		return reflect.Zero(t), nil
+	}
+	return reflect.ValueOf(key), nil
+}
+
+// fromObject converts a Slang value to a Go value of type t.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+	}
+
+	// interface{} gets the Go value, other interfaces like object.Object
+	// the Slang one
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		value := ToValue(obj)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(value), nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj).Convert(t), nil
+	}
+
+	if _, ok := obj.(*object.Null); ok {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(t), nil
+		}
+		return mismatch()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(t), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var value int64
+		switch obj := obj.(type) {
+		case *object.Integer:
+			value = obj.Value
+		case *object.Rune:
+			value = int64(obj.Value)
+		default:
+			return mismatch()
+		}
+
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", value, t)
+		}
+		v.SetInt(value)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+
+		v := reflect.New(t).Elem()
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		switch obj := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(obj.Value)).Convert(t), nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(t), nil
+		}
+	case reflect.Slice:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+
+		v := reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		for i, element := range array.Elements {
+			value, err := fromObject(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(value)
+		}
+		return v, nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		v := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			key, err := fromKey(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+
+			value, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value of %s: %w", pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(key, value)
+		}
+		return v, nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+
+		v := reflect.New(t).Elem()
+		for _, field := range fields(t) {
+			found, ok := hash.Get(&object.String{Value: field.name})
+			if !ok {
+				continue
+			}
+
+			value, err := fromObject(found, t.FieldByIndex(field.index).Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %w", field.name, err)
+			}
+			v.FieldByIndex(field.index).Set(value)
+		}
+		return v, nil
+	case reflect.Pointer:
+		value, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		v := reflect.New(t.Elem())
+		v.Elem().Set(value)
+		return v, nil
+	}
+
+	return mismatch()
+}
+
+type field struct {
+	name  string
+	index []int
+}
+
+// fields returns the exported fields of a struct type, with their names in
+// Slang.
+func fields(t reflect.Type) []field {
+	var fields []field
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("slang"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+
+		fields = append(fields, field{name: name, index: f.Index})
+	}
+
+	return fields
+}
+
+func setPair(hash *object.Hash, key, value object.Object) error {
+	hashable, ok := object.Key(key)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+
+	hash.Set(hashable, value)
+	return nil
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
@@ -0,0 +1,202 @@
+// Package slang embeds the Slang interpreter in Go programs.
+//
+// An Interpreter keeps the definitions of the programs it runs, so a host
+// can run a script once and then call its functions, read its globals or
+// give it new ones. Values cross between Go and Slang as described by
+// ToObject and ToValue.
+package slang
+
+import (
+	"compiler-book/evaluator"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Interpreter runs Slang programs in one global environment.
+type Interpreter struct {
+	evaluator *evaluator.Evaluator
+	env       *object.Environment
+	macros    *object.Environment
+	limits    evaluator.Limits
+}
+
+func New() *Interpreter {
+	return &Interpreter{
+		evaluator: evaluator.New(),
+		env:       object.NewEnvironment(),
+		macros:    object.NewEnvironment(),
+	}
+}
+
+// SyntaxError is returned for a program that does not parse.
+type SyntaxError struct {
+	Errors []*parser.ParseError
+}
+
+func (e *SyntaxError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is an error a program raised and did not catch.
+type RuntimeError struct {
+	Kind    object.ErrorKind
+	Message string
+	Trace   []object.Frame // innermost frame first
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// ExitError is returned for a program that called exit.
+type ExitError struct {
+	Status int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Status)
+}
+
+// Run evaluates src and returns the value of its last statement, converted
+// with ToValue.
+func (in *Interpreter) Run(src string) (interface{}, error) {
+	return in.RunContext(context.Background(), src)
+}
+
+// RunContext is Run, stopped with a RuntimeError of kind LimitError once ctx
+// is done or the program goes past the limits of the interpreter.
+func (in *Interpreter) RunContext(ctx context.Context, src string) (interface{}, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &SyntaxError{Errors: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, in.macros)
+	expanded := evaluator.ExpandMacros(program, in.macros)
+
+	return result(in.evaluator.EvalContext(ctx, expanded, in.env, in.limits))
+}
+
+// Call calls the global function name with args, converted with ToObject,
+// and returns its result converted with ToValue.
+func (in *Interpreter) Call(name string, args ...interface{}) (interface{}, error) {
+	return in.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is Call, stopped like RunContext.
+func (in *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (interface{}, error) {
+	fn, ok := in.env.Get(name)
+	if !ok {
+		builtin, isBuiltin := in.evaluator.Builtins()[name]
+		if !isBuiltin {
+			return nil, fmt.Errorf("undefined: %s", name)
+		}
+		fn = builtin
+	}
+
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("%s is not a function: %s", name, fn.Type())
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of %s: %w", i+1, name, err)
+		}
+		objects[i] = obj
+	}
+
+	return result(in.evaluator.ApplyContext(ctx, fn, objects, in.limits))
+}
+
+// SetGlobal binds name to value, converted with ToObject, for the programs
+// run afterwards.
+func (in *Interpreter) SetGlobal(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// GetGlobal returns the value bound to name, converted with ToValue.
+func (in *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+
+	return ToValue(obj), true
+}
+
+// Register makes the Go function fn a builtin of the interpreter named
+// name, replacing the builtin already named so. See ToObject for how its
+// arguments and results are converted.
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := newBuiltin(fn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	in.evaluator.DefineBuiltin(name, builtin)
+	return nil
+}
+
+// Unregister removes the builtin name from the interpreter, so the programs
+// it runs cannot call it.
+func (in *Interpreter) Unregister(name string) {
+	builtins := in.evaluator.Builtins()
+	delete(builtins, name)
+	in.evaluator.SetBuiltins(builtins)
+}
+
+// SetStreams sets the standard streams of the programs the interpreter
+// runs, which are the ones of the process by default.
+func (in *Interpreter) SetStreams(streams evaluator.Streams) {
+	in.evaluator.SetStreams(streams)
+}
+
+// SetPermissions sets what the programs the interpreter runs may do with
+// the files and the environment of the process. They may do nothing by
+// default.
+func (in *Interpreter) SetPermissions(permissions evaluator.Permissions) {
+	in.evaluator.SetPermissions(permissions)
+}
+
+// SetLimits bounds what the programs the interpreter runs may take, in
+// steps, call depth and size. They are unlimited by default.
+func (in *Interpreter) SetLimits(limits evaluator.Limits) {
+	in.limits = limits
+}
+
+// SetArgs sets the arguments the programs the interpreter runs get from
+// args().
+func (in *Interpreter) SetArgs(args []string) {
+	in.evaluator.SetArgs(args)
+}
+
+func result(obj object.Object) (interface{}, error) {
+	if err, ok := obj.(*object.Error); ok {
+		if err.Kind == object.EXIT {
+			return nil, &ExitError{Status: err.Status}
+		}
+		return nil, &RuntimeError{Kind: err.Kind, Message: err.Message, Trace: err.Trace}
+	}
+
+	return ToValue(obj), nil
+}
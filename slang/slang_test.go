@@ -0,0 +1,411 @@
+package slang
+
+import (
+	"compiler-book/evaluator"
+	"compiler-book/object"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{"1.5 * 2.0", 3.0},
+		{`"a" + "b"`, "ab"},
+		{"'x'", 'x'},
+		{"1 < 2", true},
+		{"let a = 1;", nil},
+		{`[1, "two", [3]]`, []interface{}{int64(1), "two", []interface{}{int64(3)}}},
+		{`{"a": 1, "b": [true]}`, map[string]interface{}{"a": int64(1), "b": []interface{}{true}}},
+		{`{1: "one", "two": 2}`, map[interface{}]interface{}{int64(1): "one", "two": int64(2)}},
+		{`{[1, [2]]: "x", []: "y"}`, map[interface{}]interface{}{
+			[2]interface{}{int64(1), [1]interface{}{int64(2)}}: "x",
+			[0]interface{}{}: "y",
+		}},
+		{"let unless = magic(c, a, b) { quote(if (!(unquote(c))) { unquote(a); } else { unquote(b); }) }; unless(false, 1, 2)",
+			int64(1)},
+	}
+
+	for _, tt := range tests {
+		value, err := New().Run(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("%q: wrong value. want=%#v, got=%#v", tt.input, tt.expected, value)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := New().Run("let = 1;")
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || len(syntaxErr.Errors) == 0 {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+
+	_, err = New().Run("1 + true")
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a runtime error, got %v", err)
+	}
+
+	if runtimeErr.Kind != object.TYPE_ERROR || err.Error() != "TypeError: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error: %s", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New()
+	in.SetLimits(evaluator.Limits{Steps: 1000})
+
+	if _, err := in.Run(`let loop = fn() { for (let i = 0; true; i++) {} };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err := in.Run(`loop()`)
+	if err == nil || err.Error() != "LimitError: step limit of 1000 exceeded" {
+		t.Errorf("wrong error of Run. got=%v", err)
+	}
+
+	_, err = in.Call("loop")
+	if err == nil || err.Error() != "LimitError: step limit of 1000 exceeded" {
+		t.Errorf("wrong error of Call. got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	in.SetLimits(evaluator.Limits{})
+	if _, err := in.RunContext(ctx, `1`); err == nil || err.Error() != "LimitError: evaluation stopped: context canceled" {
+		t.Errorf("wrong error of RunContext. got=%v", err)
+	}
+	if _, err := in.CallContext(ctx, "loop"); err == nil || err.Error() != "LimitError: evaluation stopped: context canceled" {
+		t.Errorf("wrong error of CallContext. got=%v", err)
+	}
+
+	_, err = New().Run(`let f = fn(n) { 1 + f(n) }; f(0)`)
+	if err == nil || err.Error() != "StackOverflowError: stack overflow" {
+		t.Errorf("wrong error of unbounded recursion. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+
+	_, err := in.Run(`
+let add = fn(a, b) { a + b };
+let names = fn(users) {
+  let result = [];
+  for (let i = 0; i < len(users); i++) { push(result, users[i]["Name"]) }
+  result
+};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := in.Call("add", 1, 2)
+	if err != nil || sum != int64(3) {
+		t.Errorf("add(1, 2) = %v, %v", sum, err)
+	}
+
+	type user struct {
+		Name string
+		Age  int
+	}
+
+	users := []user{{"ada", 36}, {"alan", 41}}
+	names, err := in.Call("names", users)
+	if err != nil || !reflect.DeepEqual(names, []interface{}{"ada", "alan"}) {
+		t.Errorf("names(users) = %v, %v", names, err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []interface{}
+		message string
+	}{
+		{"missing", nil, "undefined: missing"},
+		{"users", nil, "undefined: users"},
+		{"add", []interface{}{1}, "ArgumentError: wrong number of arguments: want=2, got=1"},
+		{"add", []interface{}{1, make(chan int)}, "argument 2 of add: cannot convert chan int to a Slang value"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.Call(tt.name, tt.args...)
+		if err == nil || err.Error() != tt.message {
+			t.Errorf("%s%v: wrong error. want=%q, got=%v", tt.name, tt.args, tt.message, err)
+		}
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	in := New()
+
+	type point struct {
+		X, Y   float64
+		Label  string `slang:"label"`
+		hidden int
+		Skip   bool `slang:"-"`
+	}
+
+	if err := in.SetGlobal("origin", &point{X: 1, Y: 2, Label: "o"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.SetGlobal("limits", map[string]int{"max": 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := in.Run(`let label = origin["label"] + "!"; [origin["X"] + origin["Y"], limits["max"], origin["Skip"]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{3.0, int64(10), nil}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("wrong value. want=%#v, got=%#v", expected, value)
+	}
+
+	label, ok := in.GetGlobal("label")
+	if !ok || label != "o!" {
+		t.Errorf("label = %v, %v", label, ok)
+	}
+
+	if _, ok := in.GetGlobal("missing"); ok {
+		t.Errorf("missing is defined")
+	}
+
+	if err := in.SetGlobal("c", make(chan int)); err == nil {
+		t.Errorf("expected an error setting a channel")
+	}
+
+	if err := in.SetGlobal("big", uint64(math.MaxUint64)); err == nil || err.Error() != "big: 18446744073709551615 overflows int" {
+		t.Errorf("wrong error setting a too large uint64. got=%v", err)
+	}
+	if err := in.SetGlobal("max", uint64(math.MaxInt64)); err != nil {
+		t.Errorf("unexpected error setting the largest int as uint64: %s", err)
+	}
+}
+
+func TestToObjectSortsMapKeys(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{map[string]int{"d": 4, "b": 2, "a": 1, "c": 3, "e": 5}, "{a: 1, b: 2, c: 3, d: 4, e: 5}"},
+		{map[int]bool{3: true, -1: false, 2: true}, "{-1: false, 2: true, 3: true}"},
+		{map[interface{}]int{"b": 2, int64(1): 1, "a": 3, 0.5: 4}, "{1: 1, 0.500000: 4, a: 3, b: 2}"},
+	}
+
+	for _, tt := range tests {
+		// maps are iterated in a different order every time
+		for i := 0; i < 5; i++ {
+			obj, err := ToObject(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if obj.Inspect() != tt.expected {
+				t.Fatalf("wrong hash. want=%s, got=%s", tt.expected, obj.Inspect())
+			}
+		}
+	}
+}
+
+func TestToObjectCycles(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+
+	loop := &node{Value: 1}
+	loop.Next = loop
+
+	shared := &node{Value: 2}
+	twice := []*node{shared, shared}
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	s := make([]interface{}, 1)
+	s[0] = s
+
+	tests := []struct {
+		input    interface{}
+		expected string // the value, or the error
+	}{
+		{loop, "field Next: cannot convert a *slang.node that contains itself"},
+		{m, "cannot convert a map[string]interface {} that contains itself"},
+		{s, "cannot convert a []interface {} that contains itself"},
+		{twice, "[{Value: 2, Next: null}, {Value: 2, Next: null}]"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = obj.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New()
+
+	type user struct {
+		Name string
+		Tags []string
+	}
+
+	register := map[string]interface{}{
+		"upper": strings.ToUpper,
+		"sum": func(xs ...float64) float64 {
+			total := 0.0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"greet": func(u user) string { return "hi " + u.Name + " " + strings.Join(u.Tags, ",") },
+		"parse": func(s string) (int, error) {
+			var n int
+			if _, err := fmt.Sscan(s, &n); err != nil {
+				return 0, fmt.Errorf("not a number: %s", s)
+			}
+			return n, nil
+		},
+		"raw": func(args ...object.Object) object.Object { return &object.Integer{Value: int64(len(args))} },
+		"log": func(string) {},
+		"count": func(m map[interface{}]int) int {
+			total := 0
+			for key, n := range m {
+				if _, ok := key.([2]interface{}); ok {
+					total += n
+				}
+			}
+			return total
+		},
+	}
+
+	for name, fn := range register {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`upper("abc")`, "ABC"},
+		{`sum()`, 0.0},
+		{`sum(1, 2.5, 3)`, 6.5},
+		{`greet({"Name": "ada", "Tags": ["a", "b"]})`, "hi ada a,b"},
+		{`parse("42")`, int64(42)},
+		{`try { parse("x") } catch (e) { e["kind"] + ": " + e["message"] }`, "Error: not a number: x"},
+		{`raw(1, "a", [])`, int64(3)},
+		{`log("x")`, nil},
+		{`count({[1, 2]: 3, [3, [4]]: 4, 1: 5})`, int64(7)},
+		{`try { upper(1) } catch (e) { e["message"] }`, "argument 1: cannot use INTEGER as string"},
+		{`try { upper() } catch (e) { e["message"] }`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		value, err := in.Run(tt.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, tt.expected) {
+			t.Errorf("%q: wrong value. want=%#v, got=%#v", tt.input, tt.expected, value)
+		}
+	}
+
+	if err := in.Register("bad", 1); err == nil {
+		t.Errorf("expected an error registering a number")
+	}
+
+	if err := in.Register("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected an error registering a function with two results")
+	}
+}
+
+func TestStreams(t *testing.T) {
+	var out strings.Builder
+	in := New()
+	in.SetStreams(evaluator.Streams{Stdout: &out})
+
+	if err := in.Register("twice", func(n int) int { return n * 2 }); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Run(`print(twice(21))`); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "42 \n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	if value, err := in.Call("twice", 2); err != nil || value != int64(4) {
+		t.Errorf("twice(2) = %v, %v", value, err)
+	}
+
+	in.Unregister("print")
+	if _, err := in.Run(`print(1)`); err == nil || err.Error() != "NameError: identifier not found: print" {
+		t.Errorf("print is still defined. got=%v", err)
+	}
+
+	if _, err := New().Run(`twice(1)`); err == nil {
+		t.Errorf("twice is defined in a new interpreter")
+	}
+}
+
+func TestSystem(t *testing.T) {
+	in := New()
+	in.SetArgs([]string{"report"})
+
+	if _, err := in.Run(`readFile("slang.go")`); err == nil || err.Error() != "PermissionError: read access to slang.go is not allowed" {
+		t.Errorf("an interpreter can read files by default. got=%v", err)
+	}
+
+	in.SetPermissions(evaluator.Permissions{Read: []string{"."}})
+	if value, err := in.Run(`contains(readFile("slang.go"), "package slang")`); err != nil || value != true {
+		t.Errorf("wrong result of reading a permitted file: %v, %v", value, err)
+	}
+
+	secret := filepath.Join(t.TempDir(), "secret.sl")
+	if err := os.WriteFile(secret, []byte(`let secret = "hunter2";`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New().Run(`import "` + secret + `"; secret.secret`); err == nil ||
+		err.Error() != "PermissionError: read access to "+secret+" is not allowed" {
+		t.Errorf("an interpreter can import files by default. got=%v", err)
+	}
+
+	_, err := in.Run(`exit(len(args()) + 1); 0`)
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Status != 2 {
+		t.Errorf("wrong error of exit. got=%v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 const (
 	ILLEGAL TokenType = "ILLEGAL"
 	EOF     TokenType = "EOF"
@@ -30,7 +32,9 @@ const (
 	LT TokenType = "<"
 	GT TokenType = ">"
 
-	SLASH TokenType = "/"
+	SLASH   TokenType = "/"
+	PERCENT TokenType = "%"
+	POWER   TokenType = "**"
 
 	// Delimiters
 	COMMA     TokenType = ","
@@ -38,6 +42,7 @@ const (
 	DQUOTE    TokenType = "\""
 	SQUOTE    TokenType = "'"
 	COLON     TokenType = ":"
+	DOT       TokenType = "."
 
 	LPAREN   TokenType = "("
 	RPAREN   TokenType = ")"
@@ -55,6 +60,15 @@ const (
 	IF       TokenType = "IF"
 	ELSE     TokenType = "ELSE"
 	FOR      TokenType = "FOR"
+	WHILE    TokenType = "WHILE"
+	IN       TokenType = "IN"
+	BREAK    TokenType = "BREAK"
+	CONTINUE TokenType = "CONTINUE"
+	IMPORT   TokenType = "IMPORT"
+	TRY      TokenType = "TRY"
+	CATCH    TokenType = "CATCH"
+	FINALLY  TokenType = "FINALLY"
+	THROW    TokenType = "THROW"
 
 	// Macros
 	MAGIC TokenType = "MAGIC"
@@ -70,19 +84,40 @@ type TokenMetadata struct {
 type Token struct {
 	Type     TokenType
 	Literal  string
-	Metadata TokenMetadata
+	Metadata TokenMetadata // where the token starts
+	End      TokenMetadata // just after the token's last character
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"return": RETURN,
-	"if":     IF,
-	"else":   ELSE,
-	"for":    FOR,
-	"magic":  MAGIC,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"return":   RETURN,
+	"if":       IF,
+	"else":     ELSE,
+	"for":      FOR,
+	"while":    WHILE,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"magic":    MAGIC,
+}
+
+// Keywords returns the reserved words of the language, sorted.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
 }
 
 func LookupIdent(ident string) TokenType {
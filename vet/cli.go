@@ -0,0 +1,129 @@
+package vet
+
+import (
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const usage = `usage: slang vet [--json] [--<rule>=false ...] [path ...]
+
+Reports likely mistakes in Slang programs. Directories are searched for
+.sl files. Without paths, the program is read from the standard input.
+Every rule is enabled unless it is turned off with its flag.
+`
+
+var descriptions = map[Rule]string{
+	UNUSED:      "report local bindings that are never read",
+	UNREACHABLE: "report statements after return or throw",
+	SHADOW:      "report names that hide a name of an outer scope",
+	UNDEFINED:   "report names that are used but never defined",
+	ASSIGN:      "report assignments to names that are not declared",
+	DISCARDED:   "report expressions whose value is thrown away",
+}
+
+// Report is a diagnostic of a file, as printed with --json. Syntax errors
+// are reported with the rule "syntax".
+type Report struct {
+	File string `json:"file"`
+	*Diagnostic
+}
+
+// Run runs slang vet with args and returns its exit code: 1 if a program
+// has syntax errors or mistakes, 2 for wrong arguments.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	asJSON := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	enabled := make(map[Rule]*bool)
+	for _, rule := range Rules {
+		enabled[rule] = flags.Bool(string(rule), true, descriptions[rule])
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	rules := make(map[Rule]bool)
+	for rule, on := range enabled {
+		rules[rule] = *on
+	}
+
+	reports := []Report{}
+	vet := func(name string, src []byte) {
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			for _, e := range p.Errors() {
+				d := &Diagnostic{Rule: "syntax", Message: e.Message, Line: e.Line, Column: e.Column}
+				reports = append(reports, Report{File: name, Diagnostic: d})
+			}
+			return
+		}
+
+		for _, d := range Vet(program, rules) {
+			reports = append(reports, Report{File: name, Diagnostic: d})
+		}
+	}
+
+	code := 0
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		vet("<stdin>", src)
+	}
+
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// the files given are checked whatever their extension
+			if entry.IsDir() || path != root && filepath.Ext(path) != ".sl" {
+				return nil
+			}
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			vet(path, src)
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		}
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(reports, "", "  ")
+		fmt.Fprintln(stdout, string(out))
+	} else {
+		for _, r := range reports {
+			fmt.Fprintf(stdout, "%s:%s\n", r.File, r.Diagnostic)
+		}
+	}
+
+	if len(reports) != 0 {
+		code = 1
+	}
+	return code
+}
@@ -0,0 +1,370 @@
+// Package vet finds likely mistakes in Slang programs that are not syntax or
+// type errors, like names that are never used or values that are thrown
+// away.
+package vet
+
+import (
+	"compiler-book/ast"
+	"compiler-book/evaluator"
+	"compiler-book/token"
+	"fmt"
+	"sort"
+)
+
+// Rule is a kind of mistake vet looks for.
+type Rule string
+
+const (
+	UNUSED      Rule = "unused"      // local bindings that are never read
+	UNREACHABLE Rule = "unreachable" // statements after return, throw, break or continue
+	SHADOW      Rule = "shadow"      // names that hide a name of an outer scope
+	UNDEFINED   Rule = "undefined"   // names used but never defined
+	ASSIGN      Rule = "assign"      // assignments to names that are not declared
+	DISCARDED   Rule = "discarded"   // expressions whose value is thrown away
+)
+
+// Rules are all the rules, in the order they are documented.
+var Rules = []Rule{UNUSED, UNREACHABLE, SHADOW, UNDEFINED, ASSIGN, DISCARDED}
+
+// Diagnostic is a mistake found at a position of the source.
+type Diagnostic struct {
+	Rule    Rule   `json:"rule"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// Vet returns what the enabled rules find in program, in the order of the
+// source. All rules are enabled when rules is nil.
+func Vet(program *ast.Program, rules map[Rule]bool) []*Diagnostic {
+	if rules == nil {
+		rules = make(map[Rule]bool)
+		for _, rule := range Rules {
+			rules[rule] = true
+		}
+	}
+
+	l := &linter{rules: rules, scope: newScope(nil)}
+	for _, name := range evaluator.BuiltinNames() {
+		l.scope.names[name] = &definition{name: name, builtin: true}
+	}
+
+	// the top level is what a module exports, so it is never unused
+	l.scope = newScope(l.scope)
+	l.statements(program.Statements, false)
+
+	// functions may use names defined after them, so their bodies are
+	// checked once every name is known
+	for len(l.functions) > 0 {
+		function := l.functions[0]
+		l.functions = l.functions[1:]
+		function()
+	}
+
+	for _, d := range l.locals {
+		if !d.used {
+			l.report(UNUSED, d.pos, "%s is declared but never used", d.name)
+		}
+	}
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return l.diagnostics
+}
+
+// definition is a name bound by let, a parameter, a catch clause or an
+// import, or a builtin.
+type definition struct {
+	name    string
+	pos     token.TokenMetadata
+	builtin bool
+	used    bool
+}
+
+type scope struct {
+	outer *scope
+	names map[string]*definition
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, names: make(map[string]*definition)}
+}
+
+func (s *scope) lookup(name string) *definition {
+	for ; s != nil; s = s.outer {
+		if d, ok := s.names[name]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+type linter struct {
+	rules       map[Rule]bool
+	diagnostics []*Diagnostic
+
+	scope     *scope
+	functions []func()      // the function bodies left to check
+	locals    []*definition // the bindings that must be used
+}
+
+func (l *linter) report(rule Rule, pos token.TokenMetadata, format string, a ...interface{}) {
+	if !l.rules[rule] {
+		return
+	}
+
+	l.diagnostics = append(l.diagnostics, &Diagnostic{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+		Line:    pos.Line,
+		Column:  pos.Column,
+	})
+}
+
+func (l *linter) open() {
+	l.scope = newScope(l.scope)
+}
+
+func (l *linter) close() {
+	l.scope = l.scope.outer
+}
+
+// define binds the name of ident in the current scope. Bindings made with
+// let or import inside a function or a block are local, they must be used.
+func (l *linter) define(ident *ast.Identifier, local bool) {
+	d := &definition{name: ident.Value, pos: ident.Pos()}
+
+	// binding a name again in the same scope replaces it
+	if _, ok := l.scope.names[ident.Value]; !ok {
+		if outer := l.scope.outer.lookup(ident.Value); outer != nil {
+			if outer.builtin {
+				l.report(SHADOW, d.pos, "%s shadows the builtin %s", d.name, d.name)
+			} else {
+				l.report(SHADOW, d.pos, "%s shadows the %s declared at %d:%d",
+					d.name, d.name, outer.pos.Line, outer.pos.Column)
+			}
+		}
+	}
+
+	l.scope.names[ident.Value] = d
+	if local {
+		l.locals = append(l.locals, d)
+	}
+}
+
+// use resolves a name read by the program.
+func (l *linter) use(ident *ast.Identifier) {
+	if d := l.scope.lookup(ident.Value); d != nil {
+		d.used = true
+		return
+	}
+
+	l.report(UNDEFINED, ident.Pos(), "undefined: %s", ident.Value)
+}
+
+// local reports whether the current scope is inside a function or a block.
+func (l *linter) local() bool {
+	return l.scope.outer.outer != nil
+}
+
+// statements checks a list of statements. The value of the last one is
+// the value of the list, which is used or not.
+func (l *linter) statements(statements []ast.Statement, used bool) {
+	reachable := true
+
+	for i, statement := range statements {
+		last := i == len(statements)-1
+
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			l.expression(statement.Value, true)
+			l.define(statement.Name, l.local())
+		case *ast.ReturnStatement:
+			l.expression(statement.ReturnValue, true)
+		case *ast.ThrowStatement:
+			l.expression(statement.Value, true)
+		case *ast.ImportStatement:
+			name := statement.Alias
+			if name == nil {
+				name = &ast.Identifier{Token: statement.Path.Token, Value: statement.Name()}
+			}
+			l.define(name, l.local())
+		case *ast.ExpressionStatement:
+			l.expression(statement.Expression, used && last)
+		}
+
+		if reachable && !last {
+			switch statement.(type) {
+			case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BreakStatement, *ast.ContinueStatement:
+				l.report(UNREACHABLE, statements[i+1].Source().Start, "unreachable code after %s",
+					statement.TokenLiteral())
+				reachable = false
+			}
+		}
+	}
+}
+
+// expression checks an expression, whose value is used or not.
+func (l *linter) expression(e ast.Expression, used bool) {
+	if !used && pure(e) {
+		l.report(DISCARDED, e.Source().Start, "the value of %s is discarded", e)
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		l.use(e)
+	case *ast.PostfixExpression:
+		l.use(&ast.Identifier{Token: e.Token, Value: e.Token.Literal})
+	case *ast.PrefixExpression:
+		l.expression(e.Right, true)
+	case *ast.InfixExpression:
+		l.expression(e.Left, true)
+		l.expression(e.Right, true)
+	case *ast.AssignExpression:
+		l.expression(e.Value, true)
+		if ident, ok := e.Left.(*ast.Identifier); ok {
+			if l.scope.lookup(ident.Value) == nil {
+				l.report(ASSIGN, ident.Pos(), "assignment to undeclared %s", ident.Value)
+			}
+		} else {
+			l.expression(e.Left, true)
+		}
+	case *ast.IndexExpression:
+		l.expression(e.Left, true)
+		l.expression(e.Index, true)
+	case *ast.SliceExpression:
+		l.expression(e.Left, true)
+		if e.Low != nil {
+			l.expression(e.Low, true)
+		}
+		if e.High != nil {
+			l.expression(e.High, true)
+		}
+	case *ast.MemberExpression:
+		l.expression(e.Object, true)
+	case *ast.ArrayLiteral:
+		for _, element := range e.Elements {
+			l.expression(element, true)
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			l.expression(key, true)
+			l.expression(e.Pairs[key], true)
+		}
+	case *ast.CallExpression:
+		// the argument of quote is not evaluated
+		if e.Function.TokenLiteral() == "quote" {
+			return
+		}
+
+		l.expression(e.Function, true)
+		for _, arg := range e.Arguments {
+			l.expression(arg, true)
+		}
+	case *ast.FunctionLiteral:
+		scope := l.scope
+		l.functions = append(l.functions, func() {
+			outer := l.scope
+			l.scope = newScope(scope)
+			defer func() { l.scope = outer }()
+
+			for _, param := range e.Parameters {
+				l.define(param, false)
+			}
+			l.statements(e.Body.Statements, true)
+		})
+	case *ast.IfExpression:
+		l.open()
+		defer l.close()
+
+		l.expression(e.Condition, true)
+		l.block(e.Consequence, used)
+		l.block(e.Alternative, used)
+	case *ast.ForExpression:
+		l.open()
+		defer l.close()
+
+		l.statements([]ast.Statement{e.Init}, true)
+		l.expression(e.Condition, true)
+		l.block(e.Body, used)
+		l.expression(e.Post, true)
+	case *ast.WhileExpression:
+		l.expression(e.Condition, true)
+		l.block(e.Body, used)
+	case *ast.ForInExpression:
+		l.expression(e.Iterable, true)
+
+		l.open()
+		defer l.close()
+
+		if e.Key != nil {
+			l.define(e.Key, false)
+		}
+		l.define(e.Value, false)
+		l.block(e.Body, used)
+	case *ast.TryExpression:
+		l.open()
+		l.block(e.Body, used)
+		l.close()
+
+		if e.Catch != nil {
+			l.open()
+			l.define(e.Parameter, false)
+			l.block(e.Catch, used)
+			l.close()
+		}
+
+		l.open()
+		l.block(e.Finally, false)
+		l.close()
+	}
+}
+
+func (l *linter) block(block *ast.BlockStatement, used bool) {
+	if block != nil {
+		l.statements(block.Statements, used)
+	}
+}
+
+// pure reports whether evaluating e does nothing but produce its value.
+// Macro literals are left out, since macros are defined by let.
+func pure(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral,
+		*ast.RuneLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return pure(e.Right)
+	case *ast.InfixExpression:
+		return pure(e.Left) && pure(e.Right)
+	case *ast.IndexExpression:
+		return pure(e.Left) && pure(e.Index)
+	case *ast.SliceExpression:
+		return pure(e.Left) && (e.Low == nil || pure(e.Low)) && (e.High == nil || pure(e.High))
+	case *ast.MemberExpression:
+		return pure(e.Object)
+	case *ast.ArrayLiteral:
+		for _, element := range e.Elements {
+			if !pure(element) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			if !pure(key) || !pure(e.Pairs[key]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
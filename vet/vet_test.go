@@ -0,0 +1,171 @@
+package vet
+
+import (
+	"bytes"
+	"compiler-book/ast"
+	"compiler-book/lexer"
+	"compiler-book/parser"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(a) { let b = 1; let c = 2; a + c }; f(1);", []string{
+			"1:21: b is declared but never used (unused)",
+		}},
+		{"let x = 1; if (x) { let y = x; y = 2 }; import \"a.sl\"", []string{
+			"1:25: y is declared but never used (unused)",
+		}},
+		{"let f = fn() { return 1; print(2); throw \"x\"; 3 }; f()", []string{
+			"1:26: unreachable code after return (unreachable)",
+		}},
+		// function bodies are checked once the names around them are all known
+		{"let x = 1; let f = fn(x) { let len = x; len }; f(1); let x = 2;", []string{
+			"1:23: x shadows the x declared at 1:58 (shadow)",
+			"1:32: len shadows the builtin len (shadow)",
+		}},
+		{"print(y); let f = fn() { g() }; let g = fn() { 1 }; if (true) { let z = 1; z }; z", []string{
+			"1:7: undefined: y (undefined)",
+			"1:76: the value of z is discarded (discarded)",
+			"1:81: the value of z is discarded (discarded)",
+			"1:81: undefined: z (undefined)",
+		}},
+		{"let a = 1; a = 2; b = 3; let h = {}; h.c = 4; d[0] = 5", []string{
+			"1:19: assignment to undeclared b (assign)",
+			"1:47: undefined: d (undefined)",
+		}},
+		{"let f = fn(a) { a + 1; if (a) { a } else { [a] }; a }; f(1); fn() { 1 }; 1 + f(2); -f", []string{
+			"1:17: the value of (a + 1) is discarded (discarded)",
+			"1:33: the value of a is discarded (discarded)",
+			"1:44: the value of [a] is discarded (discarded)",
+			"1:62: the value of fn() 1 is discarded (discarded)",
+			"1:84: the value of (-f) is discarded (discarded)",
+		}},
+		{"let f = fn(a) { if (a) { a } else { 0 } }; f(1); let g = fn() { for (let i = 0; i < 3; i++) { i } }; g()", nil},
+		{"try { throw \"x\" } catch (e) { print(e.message) } finally { 1 }", []string{
+			"1:60: the value of 1 is discarded (discarded)",
+		}},
+		{"let m = magic(a) { quote(unquote(a) + b) }; m(1)", nil},
+		{"for (i, x in [1]) { if (x) { continue; print(i) } let y = 1; break; print(y) }; print(i)", []string{
+			"1:40: unreachable code after continue (unreachable)",
+			"1:69: unreachable code after break (unreachable)",
+			"1:87: undefined: i (undefined)",
+		}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, d := range Vet(parse(t, tt.input), nil) {
+			got = append(got, d.String())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nwant:\n%s\ngot:\n%s", tt.input,
+				strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	program := parse(t, "let f = fn() { let x = 1; return y; x }; z = 1")
+
+	for _, rule := range Rules {
+		diagnostics := Vet(program, map[Rule]bool{rule: true})
+		for _, d := range diagnostics {
+			if d.Rule != rule {
+				t.Errorf("rule %s is disabled, got %s", d.Rule, d)
+			}
+		}
+	}
+
+	if diagnostics := Vet(program, map[Rule]bool{}); len(diagnostics) != 0 {
+		t.Errorf("no rule is enabled, got %v", diagnostics)
+	}
+}
+
+// the samples have the mistakes vet was written for
+func TestSamples(t *testing.T) {
+	expected := map[string]string{
+		"main.sl": "51:7: undefined: z (undefined)",
+	}
+
+	for file, diagnostic := range expected {
+		src, err := os.ReadFile(filepath.Join("../samples", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, d := range Vet(parse(t, string(src)), nil) {
+			got = append(got, d.String())
+		}
+
+		if !strings.Contains(strings.Join(got, "\n"), diagnostic) {
+			t.Errorf("%s: %q was not found. got=%q", file, diagnostic, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.sl")
+	unused := filepath.Join(dir, "unused.sl")
+
+	os.WriteFile(clean, []byte("let f = fn(x) { x };\n"), 0o644)
+	os.WriteFile(unused, []byte("let f = fn() {\n  let x = 1;\n  a = 2;\n};\n"), 0o644)
+
+	run := func(stdin string, args ...string) (int, string) {
+		var stdout, stderr bytes.Buffer
+		code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String() + stderr.String()
+	}
+
+	code, out := run("", dir)
+	want := unused + ":2:7: x is declared but never used (unused)\n" +
+		unused + ":3:3: assignment to undeclared a (assign)\n"
+	if code != 1 || out != want {
+		t.Errorf("wrong vet of the directory. got=%d %q", code, out)
+	}
+
+	if code, out := run("", "--unused=false", "--assign=false", dir); code != 0 || out != "" {
+		t.Errorf("disabled rules were reported. got=%d %q", code, out)
+	}
+
+	code, out = run("let = 1", "--json")
+	var reports []Report
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("wrong JSON %q: %s", out, err)
+	}
+
+	if code != 1 || len(reports) != 1 || reports[0].File != "<stdin>" || reports[0].Rule != "syntax" ||
+		reports[0].Line != 1 || reports[0].Column != 5 {
+		t.Errorf("wrong report of a syntax error. got=%d %q", code, out)
+	}
+
+	if code, out := run("let x = 1;", "--json"); code != 0 || out != "[]\n" {
+		t.Errorf("wrong report of a clean program. got=%d %q", code, out)
+	}
+
+	if code, _ := run("", "--unknown"); code != 2 {
+		t.Errorf("wrong code for a wrong flag. got=%d", code)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q has syntax errors: %v", input, p.Errors()[0])
+	}
+
+	return program
+}
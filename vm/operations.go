@@ -0,0 +1,419 @@
+package vm
+
+import (
+	"compiler-book/code"
+	"compiler-book/object"
+	"math"
+)
+
+// The operations below follow the semantics of the evaluator, so programs
+// give the same results on both engines.
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpMod:         "%",
+	code.OpPow:         "**",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+	code.OpAnd:         "&&",
+	code.OpOr:          "||",
+}
+
+func (vm *VM) executeInfixOperation(op code.Opcode) *object.Error {
+	// the left operand is compiled last, so it is on top of the stack
+	left := vm.pop()
+	right := vm.pop()
+
+	result := evalInfixOperation(infixOperators[op], left, right)
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+func evalInfixOperation(operator string, left, right object.Object) object.Object {
+	switch {
+	case operator == "&&":
+		return nativeBoolToBooleanObject(isTruthy(left) && isTruthy(right))
+	case operator == "||":
+		return nativeBoolToBooleanObject(isTruthy(left) || isTruthy(right))
+	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
+		return evalIntegerInfixOperation(operator, left, right)
+	case left.Type() == object.FLOAT && right.Type() == object.FLOAT:
+		return evalFloatInfixOperation(operator, left, right)
+	case left.Type() == object.STRING && right.Type() == object.STRING:
+		return evalStringInfixOperation(operator, left, right)
+	case left.Type() == object.RUNE && right.Type() == object.RUNE:
+		return evalRuneInfixOperation(operator, left, right)
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s",
+			left.Type(), operator, right.Type())
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixOperation(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
+		if operator == "%" {
+			// the remainder has the sign of the dividend, like in Go
+			return &object.Integer{Value: leftVal % rightVal}
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "**":
+		if rightVal < 0 {
+			return newError(object.ARGUMENT_ERROR, "negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		result, ok := object.IntPow(leftVal, rightVal)
+		if !ok {
+			return newError(object.OVERFLOW_ERROR, "integer overflow: %d ** %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: result}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalFloatInfixOperation(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Float).Value
+	rightVal := right.(*object.Float).Value
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/", "%":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
+		if operator == "%" {
+			return &object.Float{Value: math.Mod(leftVal, rightVal)}
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "**":
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalStringInfixOperation(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalRuneInfixOperation(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Rune).Value
+	rightVal := right.(*object.Rune).Value
+
+	switch operator {
+	case "+":
+		return &object.Rune{Value: leftVal + rightVal}
+	case "-":
+		return &object.Rune{Value: leftVal - rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func (vm *VM) executeMinusOperator() *object.Error {
+	switch operand := vm.pop().(type) {
+	case *object.Integer:
+		return vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", operand.Type())
+	}
+}
+
+func evalBangOperator(operand object.Object) object.Object {
+	switch operand {
+	case True:
+		return False
+	case False:
+		return True
+	case Null:
+		return True
+	}
+	return False
+}
+
+// executePostfixOperator updates the number on top of the stack in place,
+// so every binding sharing it observes the change, as in the evaluator.
+func (vm *VM) executePostfixOperator(op code.Opcode) *object.Error {
+	operator := "++"
+	delta := int64(1)
+	if op == code.OpDecrement {
+		operator = "--"
+		delta = -1
+	}
+
+	switch operand := vm.stack[vm.sp-1].(type) {
+	case *object.Integer:
+		operand.Value += delta
+	case *object.Float:
+		operand.Value += float64(delta)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, operand.Type())
+	}
+
+	return nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) *object.Error {
+	switch {
+	case left.Type() == object.ARRAY:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH:
+		return vm.executeHashIndex(left, index)
+	case left.Type() == object.STRING:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.MODULE && index.Type() == object.STRING:
+		return vm.executeModuleMember(left.(*object.Module), index.(*object.String).Value)
+	default:
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
+	}
+}
+
+// executeModuleMember pushes the member name of a module, like math.sqrt.
+func (vm *VM) executeModuleMember(module *object.Module, name string) *object.Error {
+	member, ok := module.Member(name)
+	if !ok {
+		return newError(object.NAME_ERROR, "module %s has no member %s", module.Name, name)
+	}
+
+	return vm.push(member)
+}
+
+// executeSliceExpression pushes left[low:high], where the bounds left out
+// are nil.
+func (vm *VM) executeSliceExpression(left, low, high object.Object) *object.Error {
+	length, ok := object.SliceLength(left)
+	if !ok {
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+
+	lowIndex, err := object.SliceBound(low, 0, length)
+	if err != nil {
+		return err
+	}
+
+	highIndex, err := object.SliceBound(high, length, length)
+	if err != nil {
+		return err
+	}
+
+	result := object.Slice(left, lowIndex, highIndex)
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+// executeStringIndex pushes the rune at index, counting runes, not bytes.
+func (vm *VM) executeStringIndex(str, index object.Object) *object.Error {
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "index must be an integer")
+	}
+
+	runes := []rune(str.(*object.String).Value)
+	idx := integer.Value
+	max := int64(len(runes) - 1)
+
+	// if index is -n, return the nth rune from the end
+	if idx < 0 {
+		idx = max + idx + 1
+	}
+
+	if idx < 0 || idx > max {
+		return newError(object.INDEX_ERROR, "index out of range: %d", idx)
+	}
+
+	return vm.push(&object.Rune{Value: runes[idx]})
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) *object.Error {
+	arrayObject := array.(*object.Array)
+
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return newError(object.TYPE_ERROR, "index must be an integer")
+	}
+
+	idx := integer.Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	// if index is -n, return the nth element from the end
+	if idx < 0 {
+		idx = max + idx + 1
+	}
+
+	if idx < 0 || idx > max {
+		return newError(object.INDEX_ERROR, "index out of range: %d", idx)
+	}
+
+	return vm.push(arrayObject.Elements[idx])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) *object.Error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := object.Key(index)
+	if !ok {
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) executeIndexAssignment(structure, index, val object.Object) *object.Error {
+	switch structure := structure.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError(object.TYPE_ERROR, "index must be an integer")
+		}
+
+		if idx.Value < 0 || idx.Value > int64(len(structure.Elements)-1) {
+			return newError(object.INDEX_ERROR, "index out of bounds")
+		}
+
+		structure.Elements[idx.Value] = val
+	case *object.Hash:
+		key, ok := object.Key(index)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+		}
+
+		structure.Set(key, val)
+	default:
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", structure.Type())
+	}
+
+	return vm.push(Null)
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null:
+		return false
+	case False:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+// executeIterator pushes an iterator over the indexes and the elements of
+// an array, the indexes and the characters of a string, or the keys and the
+// values of a hash. Alone, the variable of a loop over a hash gets the keys.
+func (vm *VM) executeIterator(iterable object.Object, withKeys bool) *object.Error {
+	var keys, values []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		values = iterable.Elements
+		keys = make([]object.Object, len(values))
+		for i := range values {
+			keys[i] = &object.Integer{Value: int64(i)}
+		}
+	case *object.String:
+		runes := []rune(iterable.Value)
+		keys = make([]object.Object, len(runes))
+		values = make([]object.Object, len(runes))
+		for i, r := range runes {
+			keys[i] = &object.Integer{Value: int64(i)}
+			values[i] = &object.Rune{Value: r}
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+		if !withKeys {
+			values = keys
+		}
+	default:
+		return newError(object.TYPE_ERROR, "cannot loop over %s", iterable.Type())
+	}
+
+	return vm.push(&iterator{keys: keys, values: values, withKeys: withKeys})
+}
@@ -0,0 +1,574 @@
+package vm
+
+import (
+	"compiler-book/code"
+	"compiler-book/compiler"
+	"compiler-book/evaluator"
+	"compiler-book/object"
+	"fmt"
+)
+
+const (
+	StackSize   = 1 << 16
+	GlobalsSize = 1 << 16
+	MaxFrames   = 1 << 14
+)
+
+// The values are the ones of the evaluator, which its builtins return.
+var (
+	// True represents the true value.
+	True = evaluator.TRUE
+	// False represents the false value.
+	False = evaluator.FALSE
+	// Null represents the null value.
+	Null = evaluator.NULL
+)
+
+type VM struct {
+	constants []object.Object
+
+	globals     []object.Object
+	globalNames []string
+
+	builtins []object.Object // by OpGetBuiltin operand, nil when missing
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	result object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, NumLocals: bytecode.NumLocals}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	vm := &VM{
+		constants: bytecode.Constants,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    bytecode.NumLocals,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+	vm.UseBuiltins(evaluator.New())
+
+	return vm
+}
+
+// UseBuiltins makes the program find the builtins of e, so they print to
+// its streams and read files with its permissions. The builtins taking a
+// function, like map, call the closures of the program on vm.
+func (vm *VM) UseBuiltins(e *evaluator.Evaluator) {
+	e.SetForeignApply(vm.call)
+
+	builtins := e.Builtins()
+	vm.builtins = make([]object.Object, len(compiler.Builtins))
+	for i, name := range compiler.Builtins {
+		vm.builtins[i] = builtins[name]
+	}
+}
+
+// NewWithGlobalsState keeps globals across runs, which is what the REPL
+// needs to remember definitions from previous lines.
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+func NewGlobals() []object.Object {
+	return make([]object.Object, GlobalsSize)
+}
+
+// Result returns the value of the program, or the error that stopped it.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run executes the bytecode. Runtime errors of the program end up in
+// Result as an *object.Error, like Eval returns them; the returned error is
+// reserved for malformed bytecode.
+func (vm *VM) Run() error {
+	result, err := vm.run(0)
+	vm.result = result
+	return err
+}
+
+// call calls fn with args for a builtin, running the program until fn
+// returns.
+func (vm *VM) call(fn object.Object, args []object.Object) object.Object {
+	depth := vm.framesIndex
+
+	if err := vm.push(fn); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return err
+		}
+	}
+
+	if err := vm.executeCall(len(args)); err != nil {
+		return err
+	}
+
+	// a builtin has returned already
+	if vm.framesIndex == depth {
+		return vm.pop()
+	}
+
+	result, err := vm.run(depth)
+	if err != nil {
+		return newError(object.TYPE_ERROR, "%s", err)
+	}
+	return result
+}
+
+// run executes instructions until the frames go back to depth, when a
+// function called by a builtin returns, or until the program ends when depth
+// is 0. It returns the value of the function or the program.
+func (vm *VM) run(depth int) (object.Object, error) {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.push(copyConstant(vm.constants[constIndex]))
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpAnd, code.OpOr:
+			err = vm.executeInfixOperation(op)
+		case code.OpBang:
+			err = vm.push(evalBangOperator(vm.pop()))
+		case code.OpMinus:
+			err = vm.executeMinusOperator()
+		case code.OpIncrement, code.OpDecrement:
+			err = vm.executePostfixOperator(op)
+		case code.OpTrue:
+			err = vm.push(True)
+		case code.OpFalse:
+			err = vm.push(False)
+		case code.OpNull:
+			err = vm.push(Null)
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			global := vm.globals[globalIndex]
+			if global == nil {
+				err = vm.identifierNotFound(int(globalIndex))
+				break
+			}
+
+			err = vm.push(global)
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if vm.globals[globalIndex] == nil {
+				err = vm.identifierNotFound(int(globalIndex))
+				break
+			}
+
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			builtin := vm.builtins[builtinIndex]
+			if builtin == nil {
+				err = newError(object.NAME_ERROR, "identifier not found: "+compiler.Builtins[builtinIndex])
+				break
+			}
+
+			err = vm.push(builtin)
+		case code.OpNewCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = &cell{}
+		case code.OpBoxLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := vm.currentFrame().basePointer + int(localIndex)
+			vm.stack[slot] = &cell{value: vm.stack[slot]}
+		case code.OpGetBoxed:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			c := vm.stack[frame.basePointer+int(localIndex)].(*cell)
+			err = vm.push(c.value)
+		case code.OpSetBoxed:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			c := vm.stack[frame.basePointer+int(localIndex)].(*cell)
+			c.value = vm.pop()
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			c := vm.currentFrame().cl.Free[freeIndex].(*cell)
+			err = vm.push(c.value)
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			c := vm.currentFrame().cl.Free[freeIndex].(*cell)
+			c.value = vm.pop()
+		case code.OpLoadFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(array)
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, hashErr := vm.buildHash(vm.sp-numElements, vm.sp)
+			if hashErr != nil {
+				err = hashErr
+				break
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.executeIndexExpression(left, index)
+		case code.OpSlice:
+			bounds := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			// the bounds left out are nil
+			var low, high object.Object
+			if bounds&2 != 0 {
+				high = vm.pop()
+			}
+			if bounds&1 != 0 {
+				low = vm.pop()
+			}
+
+			err = vm.executeSliceExpression(vm.pop(), low, high)
+		case code.OpSetIndex:
+			index := vm.pop()
+			structure := vm.pop()
+			value := vm.pop()
+
+			err = vm.executeIndexAssignment(structure, index, value)
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				return returnValue, nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if vm.framesIndex == depth {
+				return returnValue, nil
+			}
+
+			err = vm.push(returnValue)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err = vm.pushClosure(int(constIndex), int(numFree))
+		case code.OpLoop:
+			frame := vm.currentFrame()
+			frame.loops = append(frame.loops, vm.sp)
+		case code.OpEndLoop:
+			frame := vm.currentFrame()
+			frame.loops = frame.loops[:len(frame.loops)-1]
+		case code.OpUnwind:
+			out := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			frame.loops = frame.loops[:len(frame.loops)-out]
+			vm.sp = frame.loops[len(frame.loops)-1]
+			vm.stack[vm.sp-1] = Null
+		case code.OpIterator:
+			variables := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			err = vm.executeIterator(vm.pop(), variables == 2)
+		case code.OpNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			it := vm.pop().(*iterator)
+			if it.next == len(it.values) {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+
+			if it.withKeys {
+				err = vm.push(it.keys[it.next])
+			}
+			if err == nil {
+				err = vm.push(it.values[it.next])
+			}
+			it.next++
+		default:
+			return nil, fmt.Errorf("opcode %d undefined", op)
+		}
+
+		if err != nil {
+			return err, nil
+		}
+	}
+
+	return Null, nil
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return newError(object.STACK_OVERFLOW, "stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) identifierNotFound(globalIndex int) *object.Error {
+	name := "?"
+	if globalIndex < len(vm.globalNames) {
+		name = vm.globalNames[globalIndex]
+	}
+	return newError(object.NAME_ERROR, "identifier not found: "+name)
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError(object.TYPE_ERROR, "not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return newError(object.STACK_OVERFLOW, "stack overflow")
+	}
+
+	basePointer := vm.sp - numArgs
+	if basePointer+cl.Fn.NumLocals >= StackSize {
+		return newError(object.STACK_OVERFLOW, "stack overflow")
+	}
+
+	vm.pushFrame(NewFrame(cl, basePointer))
+
+	// locals which are not parameters may hold values of a previous call
+	for i := basePointer + numArgs; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		return vm.push(Null)
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return newError(object.TYPE_ERROR, "not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash((endIndex - startIndex) / 2)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.Key(key)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+// copyConstant hands out a fresh number for every load of a constant:
+// postfix ++ and -- update numbers in place, which must not leak into the
+// constant pool.
+func copyConstant(constant object.Object) object.Object {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: constant.Value}
+	case *object.Float:
+		return &object.Float{Value: constant.Value}
+	}
+	return constant
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// cell holds a local variable captured by a closure.
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string {
+	if c.value == nil {
+		return "cell()"
+	}
+	return "cell(" + c.value.Inspect() + ")"
+}
+
+// iterator goes over the items of a for-in loop.
+type iterator struct {
+	keys     []object.Object
+	values   []object.Object
+	withKeys bool // whether the loop has a variable for the keys
+	next     int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
@@ -0,0 +1,415 @@
+package vm
+
+import (
+	"compiler-book/ast"
+	"compiler-book/compiler"
+	"compiler-book/evaluator"
+	"compiler-book/evaluator/evaltest"
+	"compiler-book/lexer"
+	"compiler-book/object"
+	"compiler-book/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"2.0 ** 2.0", 4.0},
+		{"7.5 % 2.0", 1.5},
+		{"2 ** -1", "negative exponent: 2 ** -1"},
+		{"2 ** 62", 4611686018427387904},
+		{"2 ** 63", "integer overflow: 2 ** 63"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"1.0 / 0.0", "division by zero"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", Null},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { }", Null},
+		{"if (true) { let a = 1; }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringComparisons(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a" == "a"`, true},
+		{`"x" != "x"`, false},
+		{`"a" < "b"`, true},
+		{`"b" > "ab"`, true},
+		{`'a' == 'a'`, true},
+		{`'a' > 'b'`, false},
+		{`"a" == 'a'`, false},
+		{`let n = 0; for (ch in "banana") { if (ch == 'a') { n++ } }; n`, 3},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{`'a' * 'b'`, "unknown operator: RUNE * RUNE"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let a = 1; if (true) { let a = 2; }; a", 1},
+		{"let a = 1; if (true) { a = 2; }; a", 2},
+		{"if (true) { let z = 1; }; z", "identifier not found: z"},
+		{"a = 1", "identifier not found: a"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPostfixOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"let a = 1; a++; a", 2},
+		{"let a = 1.5; a--; a", 0.5},
+		{"let a = 1; let b = a; a++; b", 2},
+		{"let f = fn() { let i = 0; i++; i }; f(); f()", 1},
+		{`let s = "a"; s++`, "unknown operator: ++STRING"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let f = fn() { g() }; let g = fn() { 42 }; f()", 42},
+		{"let identity = fn(a) { a; }; identity(4);", 4},
+		{"fn(a, b) { a + b }(1)", "wrong number of arguments: want=2, got=1"},
+		{"1()", "not a function: INTEGER"},
+		{
+			`
+			let fib = fn(x) {
+				if (x == 0) { return 0; }
+				if (x == 1) { return 1; }
+				fib(x - 1) + fib(x - 2);
+			};
+			fib(15);`,
+			610,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let newAdder = fn(a, b) {
+				fn(c) { a + b + c };
+			};
+			let adder = newAdder(1, 2);
+			adder(8);`,
+			11,
+		},
+		{
+			`
+			let newCounter = fn() {
+				let count = 0;
+				fn() { count = count + 1; count };
+			};
+			let counter = newCounter();
+			counter();
+			counter();`,
+			2,
+		},
+		{
+			// closures see later updates of the variables they capture
+			`
+			let f = fn() {
+				let x = 1;
+				let get = fn() { x };
+				x = 5;
+				get();
+			};
+			f();`,
+			5,
+		},
+		{
+			`
+			let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) { return 0; }
+					countDown(x - 1);
+				};
+				countDown(1);
+			};
+			wrapper();`,
+			0,
+		},
+		{
+			`
+			let outer = fn(a) {
+				fn(b) {
+					fn(c) { a = a + 1; a + b + c };
+				};
+			};
+			let inner = outer(1)(2);
+			inner(3);
+			inner(3);`,
+			8,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"for (let i = 0; i < 10; i++) { i }", 10},
+		{"for (let i = 0; i < 0; i++) { i }", Null},
+		{"let sum = 0; for (let i = 0; i < 5; i++) { sum = sum + i; }; sum", 10},
+		{"let f = fn() { for (let i = 0; i < 5; i++) { if (i == 3) { return i } } }; f()", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 10) { i++ }", 10},
+		{"while (false) { 1 }", Null},
+		{"let n = 1; while (n < 100) { n = n * 2 }; n", 128},
+		{"let f = fn(n) { while (true) { if (n > 3) { return n } n++ } }; f(0)", 4},
+		{"let i = 0; while (i < 3) { let j = i; i = j + 1; j }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForInLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + x }; s", 6},
+		{"let s = 0; for (i, x in [10, 20, 30]) { s = s + i * x }; s", 80},
+		{`let n = 0; for (i, ch in "héllo") { n = n + i }; n`, 10},
+		{`for (k in {5: 1}) { k }`, 5},
+		{`for (k, v in {"a": 1}) { v }`, 1},
+		{"for (x in []) { x }", Null},
+		{"for (x in 1) { x }", "cannot loop over INTEGER"},
+		{"let f = fn() { let fs = []; for (x in [1, 2]) { push(fs, fn() { x }) }; fs }; f()[0]()", 1},
+		{"let f = fn(a) { for (x in a) { if (x > 1) { return x } } 0 }; f([1, 2, 3])", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBreakAndContinue(t *testing.T) {
+	tests := []vmTestCase{
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break }; s = s + x }; s", 3},
+		{"let s = 0; for (let i = 0; i < 5; i++) { if (i % 2 == 0) { continue }; s = s + i }; s", 4},
+		{"for (x in [1, 2]) { if (x == 2) { continue }; x }", Null},
+		{"let i = 0; while (true) { i++; if (i > 4) { break } }; i", 5},
+		{"let n = 0; outer: for (let i = 0; i < 3; i++) { while (true) { n++; continue outer } }; n", 3},
+		{"let f = fn() { let n = 0; a: for (x in [1, 2]) { for (y in [1, 2]) { n++; break a } }; n }; f()", 1},
+		// what the expressions around break leave on the stack is dropped
+		{"let r = for (x in [1, 2]) { [x, if (x == 2) { break } else { x }] }; r", Null},
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + if (x == 2) { continue } else { x } }; s", 4},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][3]", "index out of range: 3"},
+		{`{"a": 1}["a"]`, 1},
+		{`{"a": 1}["b"]`, Null},
+		{`let h = {}; h[1] = 2; h[1]`, 2},
+		{`let a = [1]; a[1] = 2`, "index out of bounds"},
+		{`1[0]`, "index operator not supported: INTEGER"},
+		{`"héllo"[1]`, 'é'},
+		{`"héllo"[-1]`, 'o'},
+		{`"héllo"[5]`, "index out of range: 5"},
+		{`let h = {"a": {"b": 1}}; h.a.b`, 1},
+		{`let h = {}; h.a = 2; h["a"]`, 2},
+		{`{1.2: 1, 1.9: 2}[1.9]`, 2},
+		{`{'a': 1}['a']`, 1},
+		{`{[1, [2]]: 1}[[1, [2]]]`, 1},
+		{`{1: 1}[1.0]`, Null},
+		{`let h = {}; h[[fn() {}]] = 1`, "unusable as hash key: ARRAY"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len([1, 2])`, 2},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`first([])`, Null},
+		{`let len = fn(x) { 42 }; len([])`, 42},
+		{`let a = [1]; push(a, 2); a[1]`, 2},
+		{`startsWith(upper("ab"), "AB")`, true},
+		{`math.sqrt(4.0)`, 2.0},
+		{`let upper = fn(s) { 42 }; upper("x")`, 42},
+		{`let k = 3; reduce(map([1, 2], fn(x) { x * k }), 0, fn(a, x) { a + x })`, 9},
+		{`[1, 2, 3][1:][0]`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRecursionDepth(t *testing.T) {
+	input := `
+	let count = fn(n) { if (n == 0) { return 0 } 1 + count(n - 1) };
+	count(5000);`
+
+	runVmTests(t, []vmTestCase{{input, 5000}})
+}
+
+// TestEngineParity runs the cases of the evaluator tests on both engines,
+// which must agree on every result. The cases using what only the evaluator
+// supports, like exceptions, are skipped.
+func TestEngineParity(t *testing.T) {
+	inputs := append(evaltest.Inputs(),
+		// the values of functions and the builtins the sandbox stops
+		"fn(x) { x + 2; };", `args()`, `readFile("/etc/hostname")`, `env("HOME")`)
+
+	for _, input := range inputs {
+		program := parse(input)
+
+		evaluated := evaluator.Eval(parse(input), object.NewEnvironment())
+		executed, err := run(program)
+		if err != nil && strings.Contains(err.Error(), "only supported by the eval engine") {
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: vm error: %s", input, err)
+			continue
+		}
+
+		if !sameObject(evaluated, executed) {
+			t.Errorf("%s: engines disagree. eval=%s (%T), vm=%s (%T)", input,
+				evaluated.Inspect(), evaluated, executed.Inspect(), executed)
+		}
+	}
+}
+
+func sameObject(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	hashA, ok := a.(*object.Hash)
+	if !ok {
+		return a.Inspect() == b.Inspect()
+	}
+
+	hashB := b.(*object.Hash)
+	pairsA, pairsB := hashA.Pairs(), hashB.Pairs()
+	if len(pairsA) != len(pairsB) {
+		return false
+	}
+
+	// both engines keep the order of the source
+	for i := range pairsA {
+		if !sameObject(pairsA[i].Key, pairsB[i].Key) || !sameObject(pairsA[i].Value, pairsB[i].Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func run(program *ast.Program) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, fmt.Errorf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+
+	return vm.Result(), nil
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		result, err := run(parse(tt.input))
+		if err != nil {
+			t.Fatalf("%s: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, result)
+	}
+}
+
+func testExpectedObject(
+	t *testing.T,
+	input string,
+	expected interface{},
+	actual object.Object,
+) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != int64(expected) {
+			t.Errorf("%s: wrong result. want=%d, got=%s (%T)",
+				input, expected, actual.Inspect(), actual)
+		}
+	case float64:
+		result, ok := actual.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: wrong result. want=%f, got=%s (%T)",
+				input, expected, actual.Inspect(), actual)
+		}
+	case string:
+		errObj, ok := actual.(*object.Error)
+		if !ok || errObj.Message != expected {
+			t.Errorf("%s: expected error %q, got=%s (%T)",
+				input, expected, actual.Inspect(), actual)
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%s: object is not Null. got=%s (%T)",
+				input, actual.Inspect(), actual)
+		}
+	}
+}
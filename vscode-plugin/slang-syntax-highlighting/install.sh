@@ -1,2 +1,4 @@
+mkdir -p bin
+go build -o bin/slang ../..
 mkdir -p ~/.vscode/extensions/slang-syntax-highlighting-0.0.1/
 cp -r . ~/.vscode/extensions/slang-syntax-highlighting-0.0.1/